@@ -2,7 +2,6 @@ package go_lru
 
 import (
     "fmt"
-    "sync"
     "time"
 )
 
@@ -24,192 +23,112 @@ const (
 // additional tracking overhead to the standard LRU cache, and is
 // computationally about 2x the cost, and adds some metadata over
 // head. The ARCCache is similar, but does not require setting any
-// parameters.
-type TwoQueueCache struct {
-    size       int
-    recentSize int
-
-    recent      *BASELRU
-    frequent    *BASELRU
-    recentEvict *BASELRU
-    lock        sync.RWMutex
+// parameters. It is a PolicyCache evicting with a TwoQueuePolicy.
+type TwoQueueCache[K comparable, V any] struct {
+    *PolicyCache[K, V]
 }
 
-// New2Q creates a new TwoQueueCache using the default
-// values for the parameters.
-func New2Q(size int, defaultExpiration time.Duration) (*TwoQueueCache, error) {
-    return New2QParams(size, Default2QRecentRatio, Default2QGhostEntries, defaultExpiration)
+// New2Q creates a new TwoQueueCache with string keys and untyped
+// values using the default values for the parameters.
+func New2Q(size int, defaultExpiration time.Duration) (*TwoQueueCache[string, interface{}], error) {
+    return New2QOf[string, interface{}](size, defaultExpiration)
 }
 
-// New2QParams creates a new TwoQueueCache using the provided
-// parameter values.
-func New2QParams(size int, recentRatio float64, ghostRatio float64, defaultExpiration time.Duration) (*TwoQueueCache, error) {
-    if size <= 0 {
-        return nil, fmt.Errorf("invalid size")
-    }
-    if recentRatio < 0.0 || recentRatio > 1.0 {
-        return nil, fmt.Errorf("invalid recent ratio")
-    }
-    if ghostRatio < 0.0 || ghostRatio > 1.0 {
-        return nil, fmt.Errorf("invalid ghost ratio")
-    }
-
-    // Determine the sub-sizes
-    recentSize := int(float64(size) * recentRatio)
-    evictSize := int(float64(size) * ghostRatio)
-
-    // Allocate the LRUs
-    recent, err := NewBaseLRU(size, nil, defaultExpiration)
-    if err != nil {
-        return nil, err
-    }
-    frequent, err := NewBaseLRU(size, nil, defaultExpiration)
-    if err != nil {
-        return nil, err
-    }
-    recentEvict, err := NewBaseLRU(evictSize, nil, defaultExpiration)
-    if err != nil {
-        return nil, err
-    }
-
-    // Initialize the cache
-    c := &TwoQueueCache{
-        size:        size,
-        recentSize:  recentSize,
-        recent:      recent,
-        frequent:    frequent,
-        recentEvict: recentEvict,
-    }
-    return c, nil
+// New2QParams creates a new TwoQueueCache with string keys and untyped
+// values using the provided parameter values.
+func New2QParams(size int, recentRatio float64, ghostRatio float64, defaultExpiration time.Duration) (*TwoQueueCache[string, interface{}], error) {
+    return New2QParamsOf[string, interface{}](size, recentRatio, ghostRatio, defaultExpiration)
 }
 
-func (c *TwoQueueCache) Get(key string) (interface{}, bool) {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-
-    // Check if this is a frequent value
-    if val, ok := c.frequent.Get(key); ok {
-        return val, ok
-    }
-
-    // If the value is contained in recent, then we
-    // promote it to frequent
-    if val, ok, ts := c.recent.PeekWithExpire(key); ok {
-        c.recent.Remove(key)
-        c.frequent.AddWithTimeout(key, val, ts)
-        return val, ok
-    }
-
-    // No hit
-    return nil, false
+// New2QWithCost creates a new TwoQueueCache with string keys and untyped
+// values that keeps the total cost of its entries within maxCost.
+func New2QWithCost(maxCost int64, defaultExpiration time.Duration, costFunc func(key string, value interface{}) int64) (*TwoQueueCache[string, interface{}], error) {
+    return New2QWithCostOf[string, interface{}](maxCost, defaultExpiration, costFunc)
 }
 
-func (c *TwoQueueCache) AddWithExpire(key string, value interface{}, d time.Duration) {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-
-    // Check if the value is frequently used already,
-    // and just update the value
-    if c.frequent.Contains(key) {
-        c.frequent.AddWithExpire(key, value, d)
-        return
-    }
-
-    // Check if the value is recently used, and promote
-    // the value into the frequent list
-    if c.recent.Contains(key) {
-        c.recent.Remove(key)
-        c.frequent.AddWithExpire(key, value, d)
-        return
-    }
-
-    // If the value was recently evicted, add it to the
-    // frequently used list
-    if c.recentEvict.Contains(key) {
-        c.ensureSpace(true)
-        c.recentEvict.Remove(key)
-        c.frequent.AddWithExpire(key, value, d)
-        return
-    }
+// New2QWithEvict creates a new TwoQueueCache with string keys, untyped
+// values and the given eviction callback.
+func New2QWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*TwoQueueCache[string, interface{}], error) {
+    return New2QWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
 
-    // Add to the recently seen list
-    c.ensureSpace(false)
-    c.recent.AddWithExpire(key, value, d)
-    return
+// New2QWithEvictReason creates a new TwoQueueCache with string keys and
+// untyped values whose eviction callback also learns why an entry left
+// the cache.
+func New2QWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*TwoQueueCache[string, interface{}], error) {
+    return New2QWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
 }
 
-func (c *TwoQueueCache) Add(key string, value interface{}) {
-    c.AddWithExpire(key, value, NoExpiration)
+// New2QOf creates a new TwoQueueCache for any comparable key type and
+// value type using the default values for the parameters.
+func New2QOf[K comparable, V any](size int, defaultExpiration time.Duration) (*TwoQueueCache[K, V], error) {
+    return New2QParamsOf[K, V](size, Default2QRecentRatio, Default2QGhostEntries, defaultExpiration)
 }
 
-// ensureSpace is used to ensure we have space in the cache
-func (c *TwoQueueCache) ensureSpace(recentEvict bool) {
-    // If we have space, nothing to do
-    recentLen := c.recent.Len()
-    freqLen := c.frequent.Len()
-    if recentLen+freqLen < c.size {
-        return
+// New2QParamsOf creates a new TwoQueueCache for any comparable key type
+// and value type using the provided parameter values.
+func New2QParamsOf[K comparable, V any](size int, recentRatio float64, ghostRatio float64, defaultExpiration time.Duration) (*TwoQueueCache[K, V], error) {
+    if size <= 0 {
+        return nil, fmt.Errorf("invalid size")
     }
+    return new2Q[K, V](size, 0, nil, recentRatio, ghostRatio, nil, defaultExpiration)
+}
 
-    // If the recent buffer is larger than
-    // the target, evict from there
-    if recentLen > 0 && (recentLen > c.recentSize || (recentLen == c.recentSize && !recentEvict)) {
-        k, _, _ := c.recent.RemoveOldest()
-        c.recentEvict.Add(k, nil)
-        return
+// New2QWithEvictOf creates a new TwoQueueCache using the default values
+// for the parameters with the given eviction callback. It is called once
+// for every entry dropped from the recent or frequent queues, never for
+// promotions between them or for ghost entries.
+func New2QWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*TwoQueueCache[K, V], error) {
+    if size <= 0 {
+        return nil, fmt.Errorf("invalid size")
     }
-
-    // Remove from the frequent list otherwise
-    c.frequent.RemoveOldest()
+    return new2Q[K, V](size, 0, nil, Default2QRecentRatio, Default2QGhostEntries, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration)
 }
 
-func (c *TwoQueueCache) Len() int {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
-    return c.recent.Len() + c.frequent.Len()
+// New2QWithEvictReasonOf creates a new TwoQueueCache using the default
+// values for the parameters whose eviction callback also learns why an
+// entry left the cache. Entries moving between the internal queues and
+// ghost entries never trigger the callback.
+func New2QWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*TwoQueueCache[K, V], error) {
+    if size <= 0 {
+        return nil, fmt.Errorf("invalid size")
+    }
+    return new2Q[K, V](size, 0, nil, Default2QRecentRatio, Default2QGhostEntries, onEvicted, defaultExpiration)
 }
 
-func (c *TwoQueueCache) Keys() []string {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
-    k1 := c.frequent.Keys()
-    k2 := c.recent.Keys()
-    return append(k1, k2...)
+// New2QWithCostOf creates a new TwoQueueCache that keeps the total cost
+// of its entries within maxCost, splitting the budget between recent and
+// frequent entries using the default ratios. Entries added without an
+// explicit cost are weighed by costFunc, or cost 1 if it is nil.
+func New2QWithCostOf[K comparable, V any](maxCost int64, defaultExpiration time.Duration, costFunc func(key K, value V) int64) (*TwoQueueCache[K, V], error) {
+    if maxCost <= 0 {
+        return nil, fmt.Errorf("invalid max cost")
+    }
+    return new2Q[K, V](0, maxCost, costFunc, Default2QRecentRatio, Default2QGhostEntries, nil, defaultExpiration)
 }
 
-func (c *TwoQueueCache) Remove(key string) {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-    if c.frequent.Remove(key) {
-        return
-    }
-    if c.recent.Remove(key) {
-        return
+// new2Q creates a TwoQueueCache bounded either by size entries or by
+// maxCost total cost.
+func new2Q[K comparable, V any](size int, maxCost int64, costFunc CostFunc[K, V], recentRatio float64, ghostRatio float64, onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) (*TwoQueueCache[K, V], error) {
+    capacity := int64(size)
+    if maxCost > 0 {
+        capacity = maxCost
     }
-    if c.recentEvict.Remove(key) {
-        return
+    policy, err := New2QPolicyParams[K](capacity, recentRatio, ghostRatio)
+    if err != nil {
+        return nil, err
     }
+    return &TwoQueueCache[K, V]{newPolicyCache[K, V](size, maxCost, costFunc, policy, onEvict, defaultExpiration)}, nil
 }
 
-func (c *TwoQueueCache) Purge() {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-    c.recent.Purge()
-    c.frequent.Purge()
-    c.recentEvict.Purge()
-}
-
-func (c *TwoQueueCache) Contains(key string) bool {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
-    return c.frequent.Contains(key) || c.recent.Contains(key)
-}
-
-func (c *TwoQueueCache) Peek(key string) (interface{}, bool) {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
-    if val, ok := c.frequent.Peek(key); ok {
-        return val, ok
+// twoQueueSizes returns the capacity of the recent queue and of the ghost
+// queue of a 2Q cache of the given capacity.
+func twoQueueSizes(capacity int64, recentRatio float64, ghostRatio float64) (recentSize, evictSize int64) {
+    recentSize = int64(float64(capacity) * recentRatio)
+    evictSize = int64(float64(capacity) * ghostRatio)
+    if evictSize < 1 {
+        // Small caches still need room to remember one ghost entry
+        evictSize = 1
     }
-    return c.recent.Peek(key)
+    return recentSize, evictSize
 }
@@ -7,6 +7,14 @@ import (
     "time"
 )
 
+// twoQueuePolicy returns the policy of l, to check its queues. Like
+// Stats, it first forgets the ghost of the key added last.
+func twoQueuePolicy[K comparable, V any](l *TwoQueueCache[K, V]) *TwoQueuePolicy[K] {
+    p := l.policy.(*TwoQueuePolicy[K])
+    p.settle()
+    return p
+}
+
 // Test that Peek doesn't update recent-ness
 func Test2Q_Peek(t *testing.T) {
 	l, err := New2Q(2, NoExpiration)
@@ -46,9 +54,9 @@ func Test2Q_RandomOps(t *testing.T) {
 			l.Remove(key)
 		}
 
-		if l.recent.Len()+l.frequent.Len() > size {
+		if twoQueuePolicy(l).recent.len()+twoQueuePolicy(l).frequent.len() > size {
 			t.Fatalf("bad: recent: %d freq: %d",
-				l.recent.Len(), l.frequent.Len())
+				twoQueuePolicy(l).recent.len(), twoQueuePolicy(l).frequent.len())
 		}
 	}
 }
@@ -63,10 +71,10 @@ func Test2Q_Get_RecentToFrequent(t *testing.T) {
 	for i := 0; i < 128; i++ {
 		l.Add(fmt.Sprintf("%d", i), i)
 	}
-	if n := l.recent.Len(); n != 128 {
+	if n := twoQueuePolicy(l).recent.len(); n != 128 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 0 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 0 {
 		t.Fatalf("bad: %d", n)
 	}
 
@@ -77,10 +85,11 @@ func Test2Q_Get_RecentToFrequent(t *testing.T) {
 			t.Fatalf("missing: %d", i)
 		}
 	}
-	if n := l.recent.Len(); n != 0 {
+	l.flushReads()
+	if n := twoQueuePolicy(l).recent.len(); n != 0 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 128 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 128 {
 		t.Fatalf("bad: %d", n)
 	}
 
@@ -91,10 +100,10 @@ func Test2Q_Get_RecentToFrequent(t *testing.T) {
 			t.Fatalf("missing: %d", i)
 		}
 	}
-	if n := l.recent.Len(); n != 0 {
+	if n := twoQueuePolicy(l).recent.len(); n != 0 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 128 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 128 {
 		t.Fatalf("bad: %d", n)
 	}
 }
@@ -107,28 +116,28 @@ func Test2Q_Add_RecentToFrequent(t *testing.T) {
 
 	// Add initially to recent
 	l.Add("1", 1)
-	if n := l.recent.Len(); n != 1 {
+	if n := twoQueuePolicy(l).recent.len(); n != 1 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 0 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 0 {
 		t.Fatalf("bad: %d", n)
 	}
 
 	// Add should upgrade to frequent
 	l.Add("1", 1)
-	if n := l.recent.Len(); n != 0 {
+	if n := twoQueuePolicy(l).recent.len(); n != 0 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 1 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 1 {
 		t.Fatalf("bad: %d", n)
 	}
 
 	// Add should remain in frequent
 	l.Add("1", 1)
-	if n := l.recent.Len(); n != 0 {
+	if n := twoQueuePolicy(l).recent.len(); n != 0 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 1 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 1 {
 		t.Fatalf("bad: %d", n)
 	}
 }
@@ -145,37 +154,37 @@ func Test2Q_Add_RecentEvict(t *testing.T) {
 	l.Add("3", 3)
 	l.Add("4", 4)
 	l.Add("5", 5)
-	if n := l.recent.Len(); n != 4 {
+	if n := twoQueuePolicy(l).recent.len(); n != 4 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.recentEvict.Len(); n != 1 {
+	if n := twoQueuePolicy(l).recentEvict.len(); n != 1 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 0 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 0 {
 		t.Fatalf("bad: %d", n)
 	}
 
 	// Pull in the recently evicted
 	l.Add("1", 1)
-	if n := l.recent.Len(); n != 3 {
+	if n := twoQueuePolicy(l).recent.len(); n != 3 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.recentEvict.Len(); n != 1 {
+	if n := twoQueuePolicy(l).recentEvict.len(); n != 1 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 1 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 1 {
 		t.Fatalf("bad: %d", n)
 	}
 
 	// Add 6, should cause another recent evict
 	l.Add("6", 6)
-	if n := l.recent.Len(); n != 3 {
+	if n := twoQueuePolicy(l).recent.len(); n != 3 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.recentEvict.Len(); n != 2 {
+	if n := twoQueuePolicy(l).recentEvict.len(); n != 2 {
 		t.Fatalf("bad: %d", n)
 	}
-	if n := l.frequent.Len(); n != 1 {
+	if n := twoQueuePolicy(l).frequent.len(); n != 1 {
 		t.Fatalf("bad: %d", n)
 	}
 }
@@ -258,3 +267,302 @@ func Test2Q_Contains(t *testing.T) {
 }
 
 
+
+// Test that the cache works with typed keys and values
+func Test2QOf(t *testing.T) {
+	l, err := New2QOf[int, string](128, NoExpiration)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 256; i++ {
+		l.Add(i, fmt.Sprint(i))
+	}
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+
+	for i, k := range l.Keys() {
+		if v, ok := l.Get(k); !ok || v != fmt.Sprint(k) || k != i+128 {
+			t.Fatalf("bad key: %v", k)
+		}
+	}
+	if v, ok := l.Get(0); ok || v != "" {
+		t.Fatalf("should be evicted: %v", v)
+	}
+}
+
+// Test that the recent and frequent budgets are split by cost
+func Test2Q_Cost(t *testing.T) {
+	l, err := New2QWithCostOf[string, int](100, NoExpiration, func(k string, v int) int64 {
+		return int64(v)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if twoQueuePolicy(l).recentSize != 25 {
+		t.Fatalf("bad recent size: %d", twoQueuePolicy(l).recentSize)
+	}
+
+	// Promote 60 worth of entries to frequent
+	for i := 0; i < 3; i++ {
+		l.Add(fmt.Sprint(i), 20)
+		l.Get(fmt.Sprint(i))
+	}
+	l.flushReads()
+	if n := twoQueuePolicy(l).frequent.cost; n != 60 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	// Recent entries are evicted first while recent is over budget
+	for i := 3; i < 10; i++ {
+		l.Add(fmt.Sprint(i), 10)
+		if n := l.Cost(); n > 100 {
+			t.Fatalf("over budget: %d", n)
+		}
+	}
+	if n := twoQueuePolicy(l).frequent.cost; n != 60 {
+		t.Fatalf("bad: %d", n)
+	}
+	if n := twoQueuePolicy(l).recent.cost; n != 40 {
+		t.Fatalf("bad: %d", n)
+	}
+	if n := twoQueuePolicy(l).recentEvict.cost; n != 30 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	// A recently evicted key comes back as frequent, recent is
+	// trimmed to its target before frequent gives up "0"
+	l.Add("3", 30)
+	if n := twoQueuePolicy(l).recent.cost; n != 20 {
+		t.Fatalf("bad: %d", n)
+	}
+	if n := twoQueuePolicy(l).frequent.cost; n != 70 {
+		t.Fatalf("bad: %d", n)
+	}
+	if l.Contains("0") || !l.Contains("3") {
+		t.Fatalf("should have evicted 0 for 3")
+	}
+	if n := l.Cost(); n > 100 {
+		t.Fatalf("over budget: %d", n)
+	}
+}
+
+// Test that the janitor reclaims expired entries without leaving ghosts
+func Test2Q_Janitor(t *testing.T) {
+	l, err := New2Q(128, NoExpiration)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	l.AddWithExpire("1", 1, 10*time.Millisecond)
+	l.AddWithExpire("2", 2, 10*time.Millisecond)
+	l.Get("2")
+	l.Add("3", 3)
+	l.StartJanitor(5 * time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	if l.Len() != 1 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if n := twoQueuePolicy(l).recentEvict.len(); n != 0 {
+		t.Fatalf("bad: %d", n)
+	}
+}
+
+// Test that the eviction callback learns why each entry left
+func Test2Q_EvictReason(t *testing.T) {
+	reasons := make(map[string]EvictReason)
+	onEvicted := func(k string, v int, reason EvictReason) {
+		reasons[fmt.Sprintf("%s=%d", k, v)] = reason
+	}
+	l, err := New2QWithEvictReasonOf[string, int](4, NoExpiration, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Promoting by Add replaces the value, by Get it does not
+	l.Add("a", 1)
+	l.Add("a", 2)
+	l.Add("b", 1)
+	l.Get("b")
+	if len(reasons) != 1 || reasons["a=1"] != EvictReplaced {
+		t.Fatalf("bad reasons: %v", reasons)
+	}
+
+	// Fill recent until "c" is pushed out to the ghost list
+	for _, k := range []string{"c", "d", "e"} {
+		l.Add(k, 1)
+	}
+	if reasons["c=1"] != EvictCapacity {
+		t.Fatalf("bad reasons: %v", reasons)
+	}
+
+	// Bringing back "c" from the ghost list is a plain add
+	l.Add("c", 2)
+	l.Remove("e")
+	l.AddWithExpire("f", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	l.Get("f")
+	l.Purge()
+
+	want := map[string]EvictReason{
+		"a=1": EvictReplaced,
+		"c=1": EvictCapacity,
+		"d=1": EvictCapacity,
+		"e=1": EvictRemoved,
+		"f=1": EvictExpired,
+		"a=2": EvictPurged,
+		"b=1": EvictPurged,
+		"c=2": EvictPurged,
+	}
+	if fmt.Sprint(reasons) != fmt.Sprint(want) {
+		t.Fatalf("bad reasons: %v", reasons)
+	}
+}
+
+// Test that the eviction callback fires exactly once per dropped entry
+// and never for ghost entries
+func Test2Q_Evict(t *testing.T) {
+	live := make(map[string]bool)
+	onEvicted := func(k string, v interface{}) {
+		if !live[k] {
+			t.Fatalf("evicted an entry that is not cached: %v", k)
+		}
+		delete(live, k)
+	}
+	l, err := New2QWithEvict(32, NoExpiration, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("%d", rand.Int63()%128)
+		switch rand.Int63() % 3 {
+		case 0:
+			l.Add(key, key)
+			live[key] = true
+		case 1:
+			l.Get(key)
+		case 2:
+			l.Remove(key)
+			if live[key] {
+				t.Fatalf("removed entry was not evicted: %v", key)
+			}
+		}
+
+		if l.Len() != len(live) {
+			t.Fatalf("bad len: %d live: %d", l.Len(), len(live))
+		}
+	}
+	for _, k := range l.Keys() {
+		if !live[k] {
+			t.Fatalf("cached entry was evicted: %v", k)
+		}
+	}
+
+	l.Purge()
+	if len(live) != 0 {
+		t.Fatalf("purged entries were not evicted: %v", live)
+	}
+}
+
+func Test2Q_Sliding(t *testing.T) {
+    l, err := New2Q(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithSliding("a", 1, 50*time.Millisecond, NoExpiration)
+    for i := 0; i < 3; i++ {
+        time.Sleep(30 * time.Millisecond)
+        if _, ok := l.Get("a"); !ok {
+            t.Fatalf("sliding entry should still be cached")
+        }
+    }
+
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Peek("a"); ok {
+        t.Fatalf("sliding entry should have expired")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+}
+
+func Test2Q_TTL(t *testing.T) {
+    l, err := New2Q(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Recent and frequent entries alike
+    l.AddWithExpire("a", 1, 10*time.Millisecond)
+    l.Add("b", 2)
+    l.Get("b")
+    if !l.Touch("a", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("a"); !ok || d <= 59*time.Second {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Updates honor the new duration
+    l.Add("a", 10)
+    if d, ok := l.TTL("a"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    l.AddWithDeadline("c", 3, time.Now().Add(10*time.Millisecond))
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("b"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if n := l.RemoveExpired(); n != 2 {
+        t.Fatalf("bad removed: %d", n)
+    }
+}
+
+func Test2Q_Resize(t *testing.T) {
+    var evicted []string
+    l, err := New2QWithEvictOf[string, int](8, NoExpiration, func(k string, v int) {
+        evicted = append(evicted, k)
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 8; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    for i := 0; i < 4; i++ {
+        l.Get(fmt.Sprint(i))
+    }
+
+    // The recent entries go first down to their new share, the ghosts
+    // being trimmed to theirs
+    if n := l.Resize(4); n != 4 {
+        t.Fatalf("bad evicted: %v", n)
+    }
+    if fmt.Sprint(evicted) != "[4 5 6 0]" || fmt.Sprint(l.Keys()) != "[7 1 2 3]" {
+        t.Fatalf("bad evicted: %v keys: %v", evicted, l.Keys())
+    }
+    if twoQueuePolicy(l).recentSize != 1 || fmt.Sprint(twoQueuePolicy(l).recentEvict.keys()) != "[5 6]" {
+        t.Fatalf("bad recent size: %v ghosts: %v", twoQueuePolicy(l).recentSize, twoQueuePolicy(l).recentEvict.keys())
+    }
+
+    // Growing recomputes the shares from the original ratios
+    if n := l.Resize(16); n != 0 {
+        t.Fatalf("bad evicted: %v", n)
+    }
+    if twoQueuePolicy(l).recentSize != 4 {
+        t.Fatalf("bad recent size: %v", twoQueuePolicy(l).recentSize)
+    }
+    for i := 8; i < 20; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    if l.Len() != 16 || twoQueuePolicy(l).recent.len() != 13 {
+        t.Fatalf("bad len: %v recent: %v", l.Len(), twoQueuePolicy(l).recent.len())
+    }
+}
@@ -1,7 +1,7 @@
 package go_lru
 
 import (
-    "sync"
+    "errors"
     "time"
 )
 
@@ -13,248 +13,95 @@ import (
 // it is roughly 2x the cost, and the extra memory overhead is linear
 // with the size of the cache. ARC has been patented by IBM, but is
 // similar to the TwoQueueCache (2Q) which requires setting parameters.
-type ARCCache struct {
-    size int // Size is the total capacity of the cache
-    p    int // P is the dynamic preference towards T1 or T2
-
-    t1 *BASELRU // T1 is the LRU for recently accessed items
-    b1 *BASELRU // B1 is the LRU for evictions from t1
-
-    t2 *BASELRU // T2 is the LRU for frequently accessed items
-    b2 *BASELRU // B2 is the LRU for evictions from t2
-
-    lock sync.RWMutex
+// It is a PolicyCache evicting with an ARCPolicy.
+type ARCCache[K comparable, V any] struct {
+    *PolicyCache[K, V]
 }
 
-// NewARC creates an ARC of the given size
-func NewARC(size int, defaultExpiration time.Duration) (*ARCCache, error) {
-    // Create the sub LRUs
-    b1, err := NewBaseLRU(size, nil, defaultExpiration)
-    if err != nil {
-        return nil, err
-    }
-    b2, err := NewBaseLRU(size, nil, defaultExpiration)
-    if err != nil {
-        return nil, err
-    }
-    t1, err := NewBaseLRU(size, nil, defaultExpiration)
-    if err != nil {
-        return nil, err
-    }
-    t2, err := NewBaseLRU(size, nil, defaultExpiration)
-    if err != nil {
-        return nil, err
-    }
-
-    // Initialize the ARC
-    c := &ARCCache{
-        size: size,
-        p:    0,
-        t1:   t1,
-        b1:   b1,
-        t2:   t2,
-        b2:   b2,
-    }
-    return c, nil
+// NewARC creates an ARC of the given size with string keys and untyped
+// values.
+func NewARC(size int, defaultExpiration time.Duration) (*ARCCache[string, interface{}], error) {
+    return NewARCOf[string, interface{}](size, defaultExpiration)
 }
 
-// Get looks up a key's value from the cache.
-func (c *ARCCache) Get(key string) (interface{}, bool) {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-
-    // Ff the value is contained in T1 (recent), then
-    // promote it to T2 (frequent)
-    if val, ok, ts := c.t1.PeekWithExpire(key); ok {
-        c.t1.Remove(key)
-        c.t2.AddWithTimeout(key, val, ts)
-        return val, ok
-    }
-
-    // Check if the value is contained in T2 (frequent)
-    if val, ok := c.t2.Get(key); ok {
-        return val, ok
-    }
-
-    // No hit
-    return nil, false
+// NewARCWithCost creates an ARC with string keys and untyped values that
+// keeps the total cost of its entries within maxCost.
+func NewARCWithCost(maxCost int64, defaultExpiration time.Duration, costFunc func(key string, value interface{}) int64) (*ARCCache[string, interface{}], error) {
+    return NewARCWithCostOf[string, interface{}](maxCost, defaultExpiration, costFunc)
 }
 
-func (c *ARCCache) AddWithExpire(key string, value interface{}, d time.Duration) {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-
-    // Check if the value is contained in T1 (recent), and potentially
-    // promote it to frequent T2
-    if c.t1.Contains(key) {
-        c.t1.Remove(key)
-        c.t2.AddWithExpire(key, value, d)
-        return
-    }
-
-    // Check if the value is already in T2 (frequent) and update it
-    if c.t2.Contains(key) {
-        c.t2.AddWithExpire(key, value, d)
-        return
-    }
-
-    // Check if this value was recently evicted as part of the
-    // recently used list
-    if c.b1.Contains(key) {
-        // T1 set is too small, increase P appropriately
-        delta := 1
-        b1Len := c.b1.Len()
-        b2Len := c.b2.Len()
-        if b2Len > b1Len {
-            delta = b2Len / b1Len
-        }
-        if c.p+delta >= c.size {
-            c.p = c.size
-        } else {
-            c.p += delta
-        }
-
-        // Potentially need to make room in the cache
-        if c.t1.Len()+c.t2.Len() >= c.size {
-            c.replace(false)
-        }
-
-        // Remove from B1
-        c.b1.Remove(key)
-
-        // Add the key to the frequently used list
-        c.t2.AddWithExpire(key, value, d)
-        return
-    }
-
-    // Check if this value was recently evicted as part of the
-    // frequently used list
-    if c.b2.Contains(key) {
-        // T2 set is too small, decrease P appropriately
-        delta := 1
-        b1Len := c.b1.Len()
-        b2Len := c.b2.Len()
-        if b1Len > b2Len {
-            delta = b1Len / b2Len
-        }
-        if delta >= c.p {
-            c.p = 0
-        } else {
-            c.p -= delta
-        }
-
-        // Potentially need to make room in the cache
-        if c.t1.Len()+c.t2.Len() >= c.size {
-            c.replace(true)
-        }
-
-        // Remove from B2
-        c.b2.Remove(key)
-
-        // Add the key to the frequntly used list
-        c.t2.AddWithExpire(key, value, d)
-        return
-    }
-
-    // Potentially need to make room in the cache
-    if c.t1.Len()+c.t2.Len() >= c.size {
-        c.replace(false)
-    }
-
-    // Keep the size of the ghost buffers trim
-    if c.b1.Len() > c.size-c.p {
-        c.b1.RemoveOldest()
-    }
-    if c.b2.Len() > c.p {
-        c.b2.RemoveOldest()
-    }
-
-    // Add to the recently seen list
-    c.t1.AddWithExpire(key, value, d)
-    return
+// NewARCWithEvict creates an ARC of the given size with string keys,
+// untyped values and the given eviction callback.
+func NewARCWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*ARCCache[string, interface{}], error) {
+    return NewARCWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
 }
 
-// Add adds a value to the cache.
-func (c *ARCCache) Add(key string, value interface{}) {
-    c.AddWithExpire(key, value, NoExpiration)
+// NewARCWithEvictReason creates an ARC of the given size with string keys
+// and untyped values whose eviction callback also learns why an entry
+// left the cache.
+func NewARCWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*ARCCache[string, interface{}], error) {
+    return NewARCWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
 }
 
-// replace is used to adaptively evict from either T1 or T2
-// based on the current learned value of P
-func (c *ARCCache) replace(b2ContainsKey bool) {
-    t1Len := c.t1.Len()
-    if t1Len > 0 && (t1Len > c.p || (t1Len == c.p && b2ContainsKey)) {
-        k, _, ok := c.t1.RemoveOldest()
-        if ok {
-            c.b1.Add(k, nil)
-        }
-    } else {
-        k, _, ok := c.t2.RemoveOldest()
-        if ok {
-            c.b2.Add(k, nil)
-        }
+// NewARCOf creates an ARC of the given size for any comparable key type
+// and value type.
+func NewARCOf[K comparable, V any](size int, defaultExpiration time.Duration) (*ARCCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
     }
+    return newARC[K, V](size, 0, nil, nil, defaultExpiration), nil
 }
 
-// Len returns the number of cached entries
-func (c *ARCCache) Len() int {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
-    return c.t1.Len() + c.t2.Len()
-}
-
-// Keys returns all the cached keys
-func (c *ARCCache) Keys() []string {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
-    k1 := c.t1.Keys()
-    k2 := c.t2.Keys()
-    return append(k1, k2...)
+// NewARCWithEvictOf creates an ARC of the given size with the given
+// eviction callback. It is called once for every entry dropped from T1
+// or T2, never for promotions from T1 to T2 or for ghost entries.
+func NewARCWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*ARCCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return newARC[K, V](size, 0, nil, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration), nil
 }
 
-// Remove is used to purge a key from the cache
-func (c *ARCCache) Remove(key string) {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-    if c.t1.Remove(key) {
-        return
-    }
-    if c.t2.Remove(key) {
-        return
-    }
-    if c.b1.Remove(key) {
-        return
-    }
-    if c.b2.Remove(key) {
-        return
+// NewARCWithEvictReasonOf creates an ARC of the given size whose eviction
+// callback also learns why an entry left the cache. Entries moving from
+// T1 to T2 and ghost entries never trigger the callback.
+func NewARCWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*ARCCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
     }
+    return newARC[K, V](size, 0, nil, onEvicted, defaultExpiration), nil
 }
 
-// Purge is used to clear the cache
-func (c *ARCCache) Purge() {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-    c.t1.Purge()
-    c.t2.Purge()
-    c.b1.Purge()
-    c.b2.Purge()
+// NewARCWithCostOf creates an ARC that keeps the total cost of its
+// entries within maxCost, P then becomes a cost target for T1. Entries
+// added without an explicit cost are weighed by costFunc, or cost 1 if
+// it is nil.
+func NewARCWithCostOf[K comparable, V any](maxCost int64, defaultExpiration time.Duration, costFunc func(key K, value V) int64) (*ARCCache[K, V], error) {
+    if maxCost <= 0 {
+        return nil, errors.New("Must provide a positive max cost")
+    }
+    return newARC[K, V](0, maxCost, costFunc, nil, defaultExpiration), nil
 }
 
-// Contains is used to check if the cache contains a key
-// without updating recency or frequency.
-func (c *ARCCache) Contains(key string) bool {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
-    return c.t1.Contains(key) || c.t2.Contains(key)
+// newARC creates an ARC bounded either by size entries or by maxCost
+// total cost.
+func newARC[K comparable, V any](size int, maxCost int64, costFunc CostFunc[K, V], onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) *ARCCache[K, V] {
+    capacity := int64(size)
+    if maxCost > 0 {
+        capacity = maxCost
+    }
+    return &ARCCache[K, V]{newPolicyCache[K, V](size, maxCost, costFunc, NewARCPolicy[K](capacity), onEvict, defaultExpiration)}
 }
 
-// Peek is used to inspect the cache value of a key
-// without updating recency or frequency.
-func (c *ARCCache) Peek(key string) (interface{}, bool) {
-    c.lock.RLock()
-    defer c.lock.RUnlock()
-    if val, ok := c.t1.Peek(key); ok {
-        return val, ok
+// arcDelta is how far P moves on a ghost hit: the cost of the ghost entry,
+// scaled by how much larger the other ghost list is than its own.
+func arcDelta(cost, otherCost, ownCost int64) int64 {
+    delta := cost
+    if otherCost > ownCost {
+        delta = cost * otherCost / ownCost
+    }
+    if delta < 1 {
+        delta = 1
     }
-    return c.t2.Peek(key)
+    return delta
 }
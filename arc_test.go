@@ -11,6 +11,11 @@ func init() {
     rand.Seed(time.Now().Unix())
 }
 
+// arcPolicy returns the policy of l, to check its lists.
+func arcPolicy[K comparable, V any](l *ARCCache[K, V]) *ARCPolicy[K] {
+    return l.policy.(*ARCPolicy[K])
+}
+
 func TestARC_RandomOps(t *testing.T) {
     size := 128
     l, err := NewARC(128, NoExpiration)
@@ -31,13 +36,13 @@ func TestARC_RandomOps(t *testing.T) {
             l.Remove(key)
         }
 
-        if l.t1.Len()+l.t2.Len() > size {
+        if arcPolicy(l).t1.len()+arcPolicy(l).t2.len() > size {
             t.Fatalf("bad: t1: %d t2: %d b1: %d b2: %d p: %d",
-                l.t1.Len(), l.t2.Len(), l.b1.Len(), l.b2.Len(), l.p)
+                arcPolicy(l).t1.len(), arcPolicy(l).t2.len(), arcPolicy(l).b1.len(), arcPolicy(l).b2.len(), arcPolicy(l).p)
         }
-        if l.b1.Len()+l.b2.Len() > size {
+        if arcPolicy(l).b1.len()+arcPolicy(l).b2.len() > size {
             t.Fatalf("bad: t1: %d t2: %d b1: %d b2: %d p: %d",
-                l.t1.Len(), l.t2.Len(), l.b1.Len(), l.b2.Len(), l.p)
+                arcPolicy(l).t1.len(), arcPolicy(l).t2.len(), arcPolicy(l).b1.len(), arcPolicy(l).b2.len(), arcPolicy(l).p)
         }
     }
 }
@@ -52,10 +57,10 @@ func TestARC_Get_RecentToFrequent(t *testing.T) {
     for i := 0; i < 128; i++ {
         l.Add(fmt.Sprintf("%d", i), i)
     }
-    if n := l.t1.Len(); n != 128 {
+    if n := arcPolicy(l).t1.len(); n != 128 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 0 {
+    if n := arcPolicy(l).t2.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
 
@@ -66,10 +71,11 @@ func TestARC_Get_RecentToFrequent(t *testing.T) {
             t.Fatalf("missing: %d", i)
         }
     }
-    if n := l.t1.Len(); n != 0 {
+    l.flushReads()
+    if n := arcPolicy(l).t1.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 128 {
+    if n := arcPolicy(l).t2.len(); n != 128 {
         t.Fatalf("bad: %d", n)
     }
 
@@ -80,10 +86,10 @@ func TestARC_Get_RecentToFrequent(t *testing.T) {
             t.Fatalf("missing: %d", i)
         }
     }
-    if n := l.t1.Len(); n != 0 {
+    if n := arcPolicy(l).t1.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 128 {
+    if n := arcPolicy(l).t2.len(); n != 128 {
         t.Fatalf("bad: %d", n)
     }
 }
@@ -96,28 +102,28 @@ func TestARC_Add_RecentToFrequent(t *testing.T) {
 
     // Add initially to t1
     l.Add("1", 1)
-    if n := l.t1.Len(); n != 1 {
+    if n := arcPolicy(l).t1.len(); n != 1 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 0 {
+    if n := arcPolicy(l).t2.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
 
     // Add should upgrade to t2
     l.Add("1", 1)
-    if n := l.t1.Len(); n != 0 {
+    if n := arcPolicy(l).t1.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 1 {
+    if n := arcPolicy(l).t2.len(); n != 1 {
         t.Fatalf("bad: %d", n)
     }
 
     // Add should remain in t2
     l.Add("1", 1)
-    if n := l.t1.Len(); n != 0 {
+    if n := arcPolicy(l).t1.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 1 {
+    if n := arcPolicy(l).t2.len(); n != 1 {
         t.Fatalf("bad: %d", n)
     }
 }
@@ -132,20 +138,21 @@ func TestARC_Adaptive(t *testing.T) {
     for i := 0; i < 4; i++ {
         l.Add(fmt.Sprintf("%d", i), i)
     }
-    if n := l.t1.Len(); n != 4 {
+    if n := arcPolicy(l).t1.len(); n != 4 {
         t.Fatalf("bad: %d", n)
     }
 
     // Move to t2
     l.Get("0")
     l.Get("1")
-    if n := l.t2.Len(); n != 2 {
+    l.flushReads()
+    if n := arcPolicy(l).t2.len(); n != 2 {
         t.Fatalf("bad: %d", n)
     }
 
     // Evict from t1
     l.Add("4", 4)
-    if n := l.b1.Len(); n != 1 {
+    if n := arcPolicy(l).b1.len(); n != 1 {
         t.Fatalf("bad: %d", n)
     }
 
@@ -157,13 +164,13 @@ func TestARC_Adaptive(t *testing.T) {
 
     // Add 2, should cause hit on b1
     l.Add("2", 2)
-    if n := l.b1.Len(); n != 1 {
+    if n := arcPolicy(l).b1.len(); n != 1 {
         t.Fatalf("bad: %d", n)
     }
-    if l.p != 1 {
-        t.Fatalf("bad: %d", l.p)
+    if arcPolicy(l).p != 1 {
+        t.Fatalf("bad: %d", arcPolicy(l).p)
     }
-    if n := l.t2.Len(); n != 3 {
+    if n := arcPolicy(l).t2.len(); n != 3 {
         t.Fatalf("bad: %d", n)
     }
 
@@ -175,10 +182,10 @@ func TestARC_Adaptive(t *testing.T) {
 
     // Add 4, should migrate to t2
     l.Add("4", 4)
-    if n := l.t1.Len(); n != 0 {
+    if n := arcPolicy(l).t1.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 4 {
+    if n := arcPolicy(l).t2.len(); n != 4 {
         t.Fatalf("bad: %d", n)
     }
 
@@ -190,13 +197,13 @@ func TestARC_Adaptive(t *testing.T) {
 
     // Add 4, should evict to b2
     l.Add("5", 5)
-    if n := l.t1.Len(); n != 1 {
+    if n := arcPolicy(l).t1.len(); n != 1 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 3 {
+    if n := arcPolicy(l).t2.len(); n != 3 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.b2.Len(); n != 1 {
+    if n := arcPolicy(l).b2.len(); n != 1 {
         t.Fatalf("bad: %d", n)
     }
 
@@ -208,20 +215,20 @@ func TestARC_Adaptive(t *testing.T) {
 
     // Add 0, should decrease p
     l.Add("0", 0)
-    if n := l.t1.Len(); n != 0 {
+    if n := arcPolicy(l).t1.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.t2.Len(); n != 4 {
+    if n := arcPolicy(l).t2.len(); n != 4 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.b1.Len(); n != 2 {
+    if n := arcPolicy(l).b1.len(); n != 2 {
         t.Fatalf("bad: %d", n)
     }
-    if n := l.b2.Len(); n != 0 {
+    if n := arcPolicy(l).b2.len(); n != 0 {
         t.Fatalf("bad: %d", n)
     }
-    if l.p != 0 {
-        t.Fatalf("bad: %d", l.p)
+    if arcPolicy(l).p != 0 {
+        t.Fatalf("bad: %d", arcPolicy(l).p)
     }
 
     // Current state
@@ -331,3 +338,289 @@ func TestARC_Peek(t *testing.T) {
         t.Errorf("should not have updated recent-ness of 1")
     }
 }
+
+// Test that the cache works with typed keys and values
+func TestARCOf(t *testing.T) {
+    l, err := NewARCOf[int, string](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 256; i++ {
+        l.Add(i, fmt.Sprint(i))
+    }
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+
+    for i, k := range l.Keys() {
+        if v, ok := l.Get(k); !ok || v != fmt.Sprint(k) || k != i+128 {
+            t.Fatalf("bad key: %v", k)
+        }
+    }
+    if v, ok := l.Get(0); ok || v != "" {
+        t.Fatalf("should be evicted: %v", v)
+    }
+}
+
+// Test that P adapts in units of cost
+func TestARC_Cost(t *testing.T) {
+    l, err := NewARCWithCostOf[string, int](100, NoExpiration, func(k string, v int) int64 {
+        return int64(v)
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Fill t1 and move half of it to t2
+    for i := 0; i < 4; i++ {
+        l.Add(fmt.Sprint(i), 25)
+    }
+    l.Get("0")
+    l.Get("1")
+    l.flushReads()
+    if n := arcPolicy(l).t2.cost; n != 50 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // Evict "2" from t1 to b1, keeping its cost
+    l.Add("4", 20)
+    if n := arcPolicy(l).b1.cost; n != 25 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.Cost(); n > 100 {
+        t.Fatalf("over budget: %d", n)
+    }
+
+    // A hit on b1 grows P by the ghost's cost
+    l.Add("2", 25)
+    if arcPolicy(l).p != 25 {
+        t.Fatalf("bad: %d", arcPolicy(l).p)
+    }
+    if n := l.Cost(); n > 100 {
+        t.Fatalf("over budget: %d", n)
+    }
+    if v, ok := l.Get("2"); !ok || v != 25 {
+        t.Fatalf("2 should be cached")
+    }
+}
+
+// Test that the janitor reclaims expired entries without leaving ghosts
+func TestARC_Janitor(t *testing.T) {
+    l, err := NewARC(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    defer l.Close()
+
+    l.AddWithExpire("1", 1, 10*time.Millisecond)
+    l.AddWithExpire("2", 2, 10*time.Millisecond)
+    l.Get("2")
+    l.Add("3", 3)
+    l.StartJanitor(5 * time.Millisecond)
+
+    time.Sleep(50 * time.Millisecond)
+    if l.Len() != 1 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if n := arcPolicy(l).b1.len() + arcPolicy(l).b2.len(); n != 0 {
+        t.Fatalf("bad: %d", n)
+    }
+}
+
+// Test that the eviction callback learns why each entry left
+func TestARC_EvictReason(t *testing.T) {
+    reasons := make(map[string]EvictReason)
+    onEvicted := func(k string, v int, reason EvictReason) {
+        reasons[fmt.Sprintf("%s=%d", k, v)] = reason
+    }
+    l, err := NewARCWithEvictReasonOf[string, int](2, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Promoting by Add replaces the value, by Get it does not
+    l.Add("a", 1)
+    l.Add("a", 2)
+    l.Add("b", 1)
+    l.Get("b")
+    if len(reasons) != 1 || reasons["a=1"] != EvictReplaced {
+        t.Fatalf("bad reasons: %v", reasons)
+    }
+
+    // Evict to the ghost lists, then bring "a" back
+    l.Add("c", 1)
+    l.Add("a", 3)
+    l.Remove("a")
+    l.AddWithExpire("d", 1, time.Millisecond)
+    time.Sleep(5 * time.Millisecond)
+    l.Get("d")
+    l.Purge()
+
+    want := map[string]EvictReason{
+        "a=1": EvictReplaced,
+        "a=2": EvictCapacity,
+        "c=1": EvictCapacity,
+        "a=3": EvictRemoved,
+        "d=1": EvictExpired,
+        "b=1": EvictPurged,
+    }
+    if fmt.Sprint(reasons) != fmt.Sprint(want) {
+        t.Fatalf("bad reasons: %v", reasons)
+    }
+}
+
+// Test that the eviction callback fires exactly once per dropped entry
+// and never for ghost entries
+func TestARC_Evict(t *testing.T) {
+    live := make(map[string]bool)
+    onEvicted := func(k string, v interface{}) {
+        if !live[k] {
+            t.Fatalf("evicted an entry that is not cached: %v", k)
+        }
+        delete(live, k)
+    }
+    l, err := NewARCWithEvict(32, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 20000; i++ {
+        key := fmt.Sprintf("%d", rand.Int63()%128)
+        switch rand.Int63() % 3 {
+        case 0:
+            l.Add(key, key)
+            live[key] = true
+        case 1:
+            l.Get(key)
+        case 2:
+            l.Remove(key)
+            if live[key] {
+                t.Fatalf("removed entry was not evicted: %v", key)
+            }
+        }
+
+        if l.Len() != len(live) {
+            t.Fatalf("bad len: %d live: %d", l.Len(), len(live))
+        }
+    }
+    for _, k := range l.Keys() {
+        if !live[k] {
+            t.Fatalf("cached entry was evicted: %v", k)
+        }
+    }
+
+    l.Purge()
+    if len(live) != 0 {
+        t.Fatalf("purged entries were not evicted: %v", live)
+    }
+}
+
+func TestARC_Sliding(t *testing.T) {
+    l, err := NewARC(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithSliding("a", 1, 50*time.Millisecond, NoExpiration)
+    for i := 0; i < 3; i++ {
+        time.Sleep(30 * time.Millisecond)
+        if _, ok := l.Get("a"); !ok {
+            t.Fatalf("sliding entry should still be cached")
+        }
+    }
+
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Peek("a"); ok {
+        t.Fatalf("sliding entry should have expired")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+}
+
+func TestARC_TTL(t *testing.T) {
+    l, err := NewARC(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Recent and frequent entries alike
+    l.AddWithExpire("a", 1, 10*time.Millisecond)
+    l.Add("b", 2)
+    l.Get("b")
+    if !l.Touch("a", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("a"); !ok || d <= 59*time.Second {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Updates honor the new duration
+    l.Add("a", 10)
+    if d, ok := l.TTL("a"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    l.AddWithDeadline("c", 3, time.Now().Add(10*time.Millisecond))
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("b"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if n := l.RemoveExpired(); n != 2 {
+        t.Fatalf("bad removed: %d", n)
+    }
+}
+
+func TestARC_Resize(t *testing.T) {
+    var evicted []string
+    l, err := NewARCWithEvictOf[string, int](4, NoExpiration, func(k string, v int) {
+        evicted = append(evicted, k)
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // T1 [4], T2 [0 1 2], B1 [3] and P 1 after a ghost hit
+    for i := 0; i < 4; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    l.Get("0")
+    l.Get("1")
+    l.Add("4", 4)
+    l.Add("2", 2)
+    if s := l.Stats().ARC; *s != (ARCStats{P: 1, T1: 1, T2: 3, B1: 1, B2: 0}) {
+        t.Fatalf("bad ARC stats: %+v", s)
+    }
+
+    // T1 is within P, so T2 gives way, B2 being trimmed to P
+    evicted = nil
+    if n := l.Resize(2); n != 2 {
+        t.Fatalf("bad evicted: %v", n)
+    }
+    if fmt.Sprint(evicted) != "[0 1]" || fmt.Sprint(l.Keys()) != "[4 2]" {
+        t.Fatalf("bad evicted: %v keys: %v", evicted, l.Keys())
+    }
+    if s := l.Stats().ARC; *s != (ARCStats{P: 1, T1: 1, T2: 1, B1: 1, B2: 1}) {
+        t.Fatalf("bad ARC stats: %+v", s)
+    }
+
+    // P is clamped to the new size
+    arcPolicy(l).p = 2
+    if n := l.Resize(1); n != 1 {
+        t.Fatalf("bad evicted: %v", n)
+    }
+    if s := l.Stats().ARC; s.P != 1 || s.T1+s.T2 != 1 || s.B1 != 0 {
+        t.Fatalf("bad ARC stats: %+v", s)
+    }
+
+    if n := l.Resize(8); n != 0 {
+        t.Fatalf("bad evicted: %v", n)
+    }
+    for i := 10; i < 20; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    if l.Len() != 8 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+}
@@ -3,6 +3,8 @@ package go_lru
 import (
     "container/list"
     "errors"
+    "fmt"
+    "sync/atomic"
     "time"
 )
 
@@ -16,206 +18,639 @@ const (
 )
 
 // EvictCallback is used to get a callback when a cache entry is evicted
-type EvictCallback func(key string, value interface{})
+type EvictCallback[K comparable, V any] func(key K, value V)
+
+// EvictReason tells why an entry left the cache.
+type EvictReason int
+
+const (
+    // EvictCapacity is used when an entry makes room for others.
+    EvictCapacity EvictReason = iota
+    // EvictExpired is used when an expired entry is reclaimed.
+    EvictExpired
+    // EvictRemoved is used when an entry is removed explicitly.
+    EvictRemoved
+    // EvictPurged is used when the whole cache is purged.
+    EvictPurged
+    // EvictReplaced is used for the old value of an updated entry.
+    EvictReplaced
+)
+
+func (r EvictReason) String() string {
+    switch r {
+    case EvictCapacity:
+        return "capacity"
+    case EvictExpired:
+        return "expired"
+    case EvictRemoved:
+        return "removed"
+    case EvictPurged:
+        return "purged"
+    case EvictReplaced:
+        return "replaced"
+    }
+    return fmt.Sprintf("EvictReason(%d)", int(r))
+}
+
+// EvictReasonCallback is used to get a callback with the reason when a
+// cache entry is evicted or its value is replaced.
+type EvictReasonCallback[K comparable, V any] func(key K, value V, reason EvictReason)
+
+// withReason adapts an EvictCallback to the reasons it has always been
+// called for, which excludes replaced values.
+func (f EvictCallback[K, V]) withReason() EvictReasonCallback[K, V] {
+    if f == nil {
+        return nil
+    }
+    return func(key K, value V, reason EvictReason) {
+        if reason != EvictReplaced {
+            f(key, value)
+        }
+    }
+}
+
+// CostFunc computes the cost of an entry when it is added without an
+// explicit cost. The cost must not be negative.
+type CostFunc[K comparable, V any] func(key K, value V) int64
 
 // LRU implements a non-thread safe fixed size LRU cache
-type BASELRU struct {
-    size              int
+type BASELRU[K comparable, V any] struct {
+    size              int   // size caps the number of entries, 0 if unbounded
+    maxCost           int64 // maxCost caps the total cost, 0 if unbounded
+    cost              int64 // cost is the total cost of the entries
+    costFunc          CostFunc[K, V]
     evictList         *list.List
-    items             map[string]*list.Element
-    onEvict           EvictCallback
+    items             map[K]*list.Element
+    expiry            expiryHeap[K, V] // expiry indexes the expiring entries
+    onEvict           EvictReasonCallback[K, V]
     defaultExpiration time.Duration
+    stats             *counters // stats are shared with the cache, nil if not counted
 }
 
 // entry is used to hold a value in the evictList
-type entry struct {
-    key        string
-    value      interface{}
+type entry[K comparable, V any] struct {
+    key        K
+    value      V
     Expiration int64
+    cost       int64
+    index      int // index is the position in the expiry heap, -1 if absent
+
+    refreshAt  int64         // refreshAt is the soft deadline, 0 if none
+    refresh    time.Duration // refresh is the soft TTL the entry was added with
+    refreshing bool          // refreshing is set while a refresh is running
+
+    sliding       time.Duration // sliding extends Expiration on access, 0 if fixed
+    maxExpiration int64         // maxExpiration caps a sliding Expiration, 0 if none
+
+    visits uint32 // visits counts the hits seen by the FIFO policies, accessed atomically
+}
+
+// ttl describes when an added entry expires.
+type ttl struct {
+    expiration    int64         // expiration is the hard deadline, 0 if never
+    refresh       time.Duration // refresh is the soft TTL, 0 if never refreshed
+    sliding       time.Duration // sliding is the TTL renewed on access, 0 if fixed
+    maxExpiration int64         // maxExpiration caps a sliding expiration, 0 if none
+}
+
+// newEntry builds the entry for a new item, starting its soft TTL now.
+func newEntry[K comparable, V any](key K, value V, t ttl, cost int64) *entry[K, V] {
+    ent := &entry[K, V]{key: key, value: value, Expiration: t.expiration, cost: cost}
+    ent.setTTL(t)
+    return ent
+}
+
+// setTTL applies every setting of t but the expiration, which must be
+// kept in sync with the expiry heap, restarting the soft TTL now.
+func (item *entry[K, V]) setTTL(t ttl) {
+    item.sliding = t.sliding
+    item.maxExpiration = t.maxExpiration
+    item.refresh = t.refresh
+    item.refreshAt = 0
+    if t.refresh > 0 {
+        item.refreshAt = time.Now().Add(t.refresh).UnixNano()
+    }
 }
 
 // Returns true if the item has expired.
-func (item entry) Expired() bool {
+func (item entry[K, V]) Expired() bool {
     if item.Expiration == 0 {
         return false
     }
     return time.Now().UnixNano() > item.Expiration
 }
 
-// NewLRU constructs an LRU of the given size
-func NewBaseLRU(size int, onEvict EvictCallback, defaultExpiration time.Duration) (*BASELRU, error) {
+// visit counts a hit on the entry, up to max. It is safe under the
+// shared lock of a cache.
+func (item *entry[K, V]) visit(max uint32) {
+    for {
+        v := atomic.LoadUint32(&item.visits)
+        if v >= max || atomic.CompareAndSwapUint32(&item.visits, v, v+1) {
+            return
+        }
+    }
+}
+
+// NewBaseLRU constructs an LRU of the given size with string keys and
+// untyped values.
+func NewBaseLRU(size int, onEvict EvictCallback[string, interface{}], defaultExpiration time.Duration) (*BASELRU[string, interface{}], error) {
+    return NewBaseLRUOf[string, interface{}](size, onEvict, defaultExpiration)
+}
+
+// NewBaseLRUOf constructs an LRU of the given size for any comparable
+// key type and value type.
+func NewBaseLRUOf[K comparable, V any](size int, onEvict EvictCallback[K, V], defaultExpiration time.Duration) (*BASELRU[K, V], error) {
     if size <= 0 {
         return nil, errors.New("Must provide a positive size")
     }
-    c := &BASELRU{
+    return newBaseLRU[K, V](size, 0, nil, onEvict.withReason(), defaultExpiration), nil
+}
+
+// NewBaseLRUWithEvictReasonOf constructs an LRU of the given size whose
+// eviction callback also learns why an entry left the cache.
+func NewBaseLRUWithEvictReasonOf[K comparable, V any](size int, onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) (*BASELRU[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return newBaseLRU[K, V](size, 0, nil, onEvict, defaultExpiration), nil
+}
+
+// NewBaseLRUWithCostOf constructs an LRU that keeps the total cost of its
+// entries within maxCost instead of limiting their number. Entries added
+// without an explicit cost are weighed by costFunc, or cost 1 if it is nil.
+func NewBaseLRUWithCostOf[K comparable, V any](maxCost int64, costFunc CostFunc[K, V], onEvict EvictCallback[K, V], defaultExpiration time.Duration) (*BASELRU[K, V], error) {
+    if maxCost <= 0 {
+        return nil, errors.New("Must provide a positive max cost")
+    }
+    return newBaseLRU[K, V](0, maxCost, costFunc, onEvict.withReason(), defaultExpiration), nil
+}
+
+// newBaseLRU constructs an LRU bounded by size entries and/or maxCost
+// total cost, a zero limit is not enforced.
+func newBaseLRU[K comparable, V any](size int, maxCost int64, costFunc CostFunc[K, V], onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) *BASELRU[K, V] {
+    return &BASELRU[K, V]{
         size:      size,
+        maxCost:   maxCost,
+        costFunc:  costFunc,
         evictList: list.New(),
-        items:     make(map[string]*list.Element),
+        items:     make(map[K]*list.Element),
         onEvict:   onEvict,
         defaultExpiration: defaultExpiration,
     }
-    return c, nil
 }
 
 // Purge is used to completely clear the cache
-func (c *BASELRU) Purge() {
+func (c *BASELRU[K, V]) Purge() {
     for k, v := range c.items {
-        if c.onEvict != nil {
-            c.onEvict(k, v.Value.(*entry).value)
-        }
+        c.notify(v.Value.(*entry[K, V]), EvictPurged)
         delete(c.items, k)
     }
     c.evictList.Init()
+    c.expiry = nil
+    c.cost = 0
 }
 
-func (c *BASELRU) Add(key string, value interface{}) bool {
+func (c *BASELRU[K, V]) Add(key K, value V) bool {
     return c.AddWithExpire(key, value, NoExpiration)
 }
 
-func (c *BASELRU) AddWithTimeout(key string, value interface{}, timeout int64) bool {
+func (c *BASELRU[K, V]) AddWithTimeout(key K, value V, timeout int64) bool {
+    return c.addWithCost(key, value, ttl{expiration: timeout}, c.costOf(key, value))
+}
+
+// Add adds a value to the cache.  Returns true if an eviction occurred.
+func (c *BASELRU[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    return c.AddWithTimeout(key, value, c.expireAt(d))
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *BASELRU[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    return c.addWithCost(key, value, c.ttlOf(d), cost)
+}
+
+// AddWithRefresh adds a value that is refreshed after the soft TTL
+// refresh and expires after d. Returns true if an eviction occurred.
+func (c *BASELRU[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    return c.addWithCost(key, value, ttl{expiration: c.expireAt(d), refresh: refresh}, c.costOf(key, value))
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *BASELRU[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    return c.addWithCost(key, value, deadlineTTL(deadline), c.costOf(key, value))
+}
+
+// AddWithSliding adds a value that expires after d, each successful Get
+// pushing its deadline d further. A positive max caps its lifetime from
+// now regardless of accesses. Returns true if an eviction occurred.
+func (c *BASELRU[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    return c.addWithCost(key, value, c.slidingTTL(d, max), c.costOf(key, value))
+}
+
+func (c *BASELRU[K, V]) addWithCost(key K, value V, t ttl, cost int64) bool {
     // Check for existing item
     if ent, ok := c.items[key]; ok {
         c.evictList.MoveToFront(ent)
-        ent.Value.(*entry).value = value
-        return false
+        c.update(ent.Value.(*entry[K, V]), value, t, cost)
+        return c.ensureCapacity()
     }
 
     // Add new item
-    ent := &entry{key, value, timeout}
-    entry := c.evictList.PushFront(ent)
-    c.items[key] = entry
+    if c.stats != nil {
+        c.stats.add()
+    }
+    return c.put(newEntry(key, value, t, cost))
+}
+
+// update replaces the value, ttl and cost of item in place, firing the
+// eviction callback for the old value. The caller enforces capacity.
+func (c *BASELRU[K, V]) update(item *entry[K, V], value V, t ttl, cost int64) {
+    c.notify(item, EvictReplaced)
+    item.value = value
+    item.refreshing = false
+    item.setTTL(t)
+    c.expiry.update(item, t.expiration)
+    c.cost += cost - item.cost
+    item.cost = cost
+}
+
+// refreshEntry stores the result of refreshing ent, restarting both its
+// soft and hard TTLs. Returns true if an eviction occurred.
+func (c *BASELRU[K, V]) refreshEntry(ent *entry[K, V], value V, d time.Duration) bool {
+    c.notify(ent, EvictReplaced)
+    cost := c.costOf(ent.key, value)
+    ent.value = value
+    ent.refreshing = false
+    ent.refreshAt = time.Now().Add(ent.refresh).UnixNano()
+    c.cost += cost - ent.cost
+    ent.cost = cost
+    c.expiry.update(ent, c.expireAt(d))
+    return c.ensureCapacity()
+}
+
+// put inserts ent as the newest entry, usually after taking it from
+// another list. Returns true if an eviction occurred.
+func (c *BASELRU[K, V]) put(ent *entry[K, V]) bool {
+    c.items[ent.key] = c.evictList.PushFront(ent)
+    c.expiry.track(ent)
+    c.cost += ent.cost
+    return c.ensureCapacity()
+}
+
+// take removes the key's entry without firing the eviction callback so
+// it can be moved to another list.
+func (c *BASELRU[K, V]) take(key K) (*entry[K, V], bool) {
+    ent, ok := c.items[key]
+    if !ok {
+        return nil, false
+    }
+    c.evictList.Remove(ent)
+    delete(c.items, key)
+    kv := ent.Value.(*entry[K, V])
+    c.expiry.untrack(kv)
+    c.cost -= kv.cost
+    return kv, true
+}
+
+// takeOldest takes the oldest entry without firing the eviction callback
+// so it can be moved to another list.
+func (c *BASELRU[K, V]) takeOldest() (*entry[K, V], bool) {
+    ent := c.evictList.Back()
+    if ent == nil {
+        return nil, false
+    }
+    return c.take(ent.Value.(*entry[K, V]).key)
+}
 
-    evict := c.evictList.Len() > c.size
-    // Verify size not exceeded
-    if evict {
-        c.removeOldest()
+// ensureCapacity evicts the oldest entries until both the size and the
+// cost limits are met. Returns true if an eviction occurred.
+func (c *BASELRU[K, V]) ensureCapacity() bool {
+    evict := false
+    for c.overCapacity() {
+        c.removeOldestEntry(EvictCapacity)
+        evict = true
     }
     return evict
 }
 
+func (c *BASELRU[K, V]) overCapacity() bool {
+    if c.evictList.Len() == 0 {
+        return false
+    }
+    return (c.size > 0 && c.evictList.Len() > c.size) || (c.maxCost > 0 && c.cost > c.maxCost)
+}
 
-// Add adds a value to the cache.  Returns true if an eviction occurred.
-func (c *BASELRU) AddWithExpire(key string, value interface{}, d time.Duration) bool {
-    var e int64
+// costOf returns the cost of an entry added without an explicit cost.
+func (c *BASELRU[K, V]) costOf(key K, value V) int64 {
+    if c.costFunc == nil {
+        return 1
+    }
+    return c.costFunc(key, value)
+}
+
+// ttlOf returns the ttl of an entry added now that expires after d.
+func (c *BASELRU[K, V]) ttlOf(d time.Duration) ttl {
+    return ttl{expiration: c.expireAt(d)}
+}
+
+// deadlineTTL returns the ttl of an entry expiring at deadline, never if
+// it is the zero time.
+func deadlineTTL(deadline time.Time) ttl {
+    if deadline.IsZero() {
+        return ttl{}
+    }
+    return ttl{expiration: deadline.UnixNano()}
+}
+
+// slidingTTL returns the ttl of an entry added now that expires after d
+// of inactivity and, if max is positive, at most max from now.
+func (c *BASELRU[K, V]) slidingTTL(d time.Duration, max time.Duration) ttl {
+    if d == DefaultExpiration {
+        d = c.defaultExpiration
+    }
+    return slidingTTL(d, max)
+}
+
+// slidingTTL does the work of BASELRU.slidingTTL once d is resolved.
+func slidingTTL(d time.Duration, max time.Duration) ttl {
+    if d <= 0 {
+        return ttl{}
+    }
+    now := time.Now()
+    t := ttl{expiration: now.Add(d).UnixNano(), sliding: d}
+    if max > 0 {
+        t.maxExpiration = now.Add(max).UnixNano()
+        if t.expiration > t.maxExpiration {
+            t.expiration = t.maxExpiration
+        }
+    }
+    return t
+}
+
+// slide pushes the deadline of a sliding entry forward after an access.
+func (c *BASELRU[K, V]) slide(ent *entry[K, V]) {
+    c.expiry.slide(ent)
+}
+
+// expireAt converts a duration into the expiration timestamp of an entry
+// added now, 0 meaning it never expires.
+func (c *BASELRU[K, V]) expireAt(d time.Duration) int64 {
     if d == DefaultExpiration {
         d = c.defaultExpiration
     }
     if d > 0 {
-        e = time.Now().Add(d).UnixNano()
+        return time.Now().Add(d).UnixNano()
     }
-    return c.AddWithTimeout(key, value, e)
+    return 0
 }
 
 // Get looks up a key's value from the cache.
-func (c *BASELRU) Get(key string) (value interface{}, ok bool) {
+func (c *BASELRU[K, V]) Get(key K) (value V, ok bool) {
+    if item, ok := c.getEntry(key); ok {
+        return item.value, true
+    }
+    return value, false
+}
+
+// getEntry does the work of Get, returning the key's entry.
+func (c *BASELRU[K, V]) getEntry(key K) (*entry[K, V], bool) {
     ent, ok := c.items[key]
     if !ok {
         return nil, false
     }
 
-    item := ent.Value.(*entry)
+    item := ent.Value.(*entry[K, V])
 
     if item.Expiration > 0 {
         if time.Now().UnixNano() > item.Expiration {
+            c.removeElement(ent, EvictExpired)
             return nil, false
         }
     }
     c.evictList.MoveToFront(ent)
+    c.slide(item)
 
-    return ent.Value.(*entry).value, true
+    return item, true
 }
 
 // Check if a key is in the cache, without updating the recent-ness
 // or deleting it for being stale.
-func (c *BASELRU) Contains(key string) (ok bool) {
+func (c *BASELRU[K, V]) Contains(key K) (ok bool) {
     item, ok := c.items[key]
-    if ok && item.Value.(*entry).Expired() {
+    if ok && item.Value.(*entry[K, V]).Expired() {
         return false
     }
     return ok
 }
 
-// Returns the key value (or undefined if not found) without updating
-// the "recently used"-ness of the key.
-func (c *BASELRU) Peek(key string) (value interface{}, ok bool) {
-    if ent, ok := c.items[key]; ok {
-        return ent.Value.(*entry).value, true
+// Returns the key value (or undefined if not found or stale) without
+// updating the "recently used"-ness of the key.
+func (c *BASELRU[K, V]) Peek(key K) (value V, ok bool) {
+    if ent, ok := c.items[key]; ok && !ent.Value.(*entry[K, V]).Expired() {
+        return ent.Value.(*entry[K, V]).value, true
     }
-    return nil, ok
+    return value, false
 }
 
-// Returns the key value (or undefined if not found) without updating
-// the "recently used"-ness of the key.
-func (c *BASELRU) PeekWithExpire(key string) (value interface{}, ok bool, ts int64) {
+// peekEntry returns the key's entry regardless of its expiration.
+func (c *BASELRU[K, V]) peekEntry(key K) (*entry[K, V], bool) {
     if ent, ok := c.items[key]; ok {
-        val := ent.Value.(*entry)
+        return ent.Value.(*entry[K, V]), true
+    }
+    return nil, false
+}
+
+// moveToFront marks ent as the most recently used entry, returning false
+// if it is no longer part of the cache.
+func (c *BASELRU[K, V]) moveToFront(ent *entry[K, V]) bool {
+    if el, ok := c.items[ent.key]; ok && el.Value.(*entry[K, V]) == ent {
+        c.evictList.MoveToFront(el)
+        return true
+    }
+    return false
+}
+
+// Returns the key value and its expiration (or undefined if not found
+// or stale) without updating the "recently used"-ness of the key.
+func (c *BASELRU[K, V]) PeekWithExpire(key K) (value V, ok bool, ts int64) {
+    if ent, ok := c.items[key]; ok && !ent.Value.(*entry[K, V]).Expired() {
+        val := ent.Value.(*entry[K, V])
         return val.value, true, val.Expiration
     }
-    return nil, ok, 0
+    return value, false, 0
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *BASELRU[K, V]) TTL(key K) (d time.Duration, ok bool) {
+    ent, ok := c.items[key]
+    if !ok || ent.Value.(*entry[K, V]).Expired() {
+        return 0, false
+    }
+    expiration := ent.Value.(*entry[K, V]).Expiration
+    if expiration == 0 {
+        return NoExpiration, true
+    }
+    return time.Duration(expiration - time.Now().UnixNano()), true
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration,
+// without updating its value or recent-ness. A sliding expiration is
+// replaced by the fixed deadline. Returns false if the key is missing
+// or stale.
+func (c *BASELRU[K, V]) Touch(key K, d time.Duration) bool {
+    return c.setExpiration(key, c.ttlOf(d))
 }
 
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time, without updating its value or recent-ness. A sliding
+// expiration is replaced by the fixed deadline. Returns false if the
+// key is missing or stale.
+func (c *BASELRU[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    return c.setExpiration(key, deadlineTTL(deadline))
+}
+
+func (c *BASELRU[K, V]) setExpiration(key K, t ttl) bool {
+    ent, ok := c.items[key]
+    if !ok || ent.Value.(*entry[K, V]).Expired() {
+        return false
+    }
+    item := ent.Value.(*entry[K, V])
+    item.sliding = 0
+    item.maxExpiration = 0
+    c.expiry.update(item, t.expiration)
+    return true
+}
 
 // Remove removes the provided key from the cache, returning if the
 // key was contained.
-func (c *BASELRU) Remove(key string) bool {
+func (c *BASELRU[K, V]) Remove(key K) bool {
+    return c.removeKey(key, EvictRemoved)
+}
+
+// removeKey removes the provided key for the given reason, returning if
+// the key was contained.
+func (c *BASELRU[K, V]) removeKey(key K, reason EvictReason) bool {
     if ent, ok := c.items[key]; ok {
-        c.removeElement(ent)
+        c.removeElement(ent, reason)
         return true
     }
     return false
 }
 
+// RemoveExpired removes every expired item from the cache in the order
+// they expired, returning how many were removed.
+func (c *BASELRU[K, V]) RemoveExpired() int {
+    now := time.Now().UnixNano()
+    removed := 0
+    for {
+        kv, ok := c.expiry.peek()
+        if !ok || kv.Expiration >= now {
+            return removed
+        }
+        c.removeElement(c.items[kv.key], EvictExpired)
+        removed++
+    }
+}
+
 // RemoveOldest removes the oldest item from the cache.
-func (c *BASELRU) RemoveOldest() (string, interface{}, bool) {
-    ent := c.evictList.Back()
-    if ent != nil {
-        c.removeElement(ent)
-        kv := ent.Value.(*entry)
+func (c *BASELRU[K, V]) RemoveOldest() (K, V, bool) {
+    if kv, ok := c.removeOldestEntry(EvictRemoved); ok {
         return kv.key, kv.value, true
     }
-    return "", nil, false
+    var k K
+    var v V
+    return k, v, false
+}
+
+// removeOldestEntry removes the oldest item from the cache for the given
+// reason and returns its entry.
+func (c *BASELRU[K, V]) removeOldestEntry(reason EvictReason) (*entry[K, V], bool) {
+    ent := c.evictList.Back()
+    if ent == nil {
+        return nil, false
+    }
+    c.removeElement(ent, reason)
+    return ent.Value.(*entry[K, V]), true
 }
 
 // GetOldest returns the oldest entry
-func (c *BASELRU) GetOldest() (string, interface{}, bool) {
+func (c *BASELRU[K, V]) GetOldest() (K, V, bool) {
     ent := c.evictList.Back()
     if ent != nil {
-        kv := ent.Value.(*entry)
+        kv := ent.Value.(*entry[K, V])
         return kv.key, kv.value, true
     }
-    return "", nil, false
+    var k K
+    var v V
+    return k, v, false
 }
 
 // Keys returns a slice of the keys in the cache, from oldest to newest.
-func (c *BASELRU) Keys() []string {
-    keys := make([]string, len(c.items))
+func (c *BASELRU[K, V]) Keys() []K {
+    keys := make([]K, len(c.items))
     i := 0
     for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
-        keys[i] = ent.Value.(*entry).key
+        keys[i] = ent.Value.(*entry[K, V]).key
         i++
     }
     return keys
 }
 
 // Len returns the number of items in the cache.
-func (c *BASELRU) Len() int {
+func (c *BASELRU[K, V]) Len() int {
     return c.evictList.Len()
 }
 
-// removeOldest removes the oldest item from the cache.
-func (c *BASELRU) removeOldest() {
-    ent := c.evictList.Back()
-    if ent != nil {
-        c.removeElement(ent)
+// Cost returns the total cost of the items in the cache. Without a cost
+// function every item costs 1.
+func (c *BASELRU[K, V]) Cost() int64 {
+    return c.cost
+}
+
+// MaxCost returns the cost budget of the cache, 0 if it is only bounded
+// by the number of items.
+func (c *BASELRU[K, V]) MaxCost() int64 {
+    return c.maxCost
+}
+
+// Resize changes the capacity of the cache, in items or in cost if it is
+// bounded by cost, evicting the oldest items that no longer fit. Returns
+// how many were evicted. A non-positive size is ignored.
+func (c *BASELRU[K, V]) Resize(size int) (evicted int) {
+    if size <= 0 {
+        return 0
+    }
+    if c.maxCost > 0 {
+        c.maxCost = int64(size)
+    } else {
+        c.size = size
     }
+    for c.overCapacity() {
+        c.removeOldestEntry(EvictCapacity)
+        evicted++
+    }
+    return evicted
 }
 
 // removeElement is used to remove a given list element from the cache
-func (c *BASELRU) removeElement(e *list.Element) {
+func (c *BASELRU[K, V]) removeElement(e *list.Element, reason EvictReason) {
     c.evictList.Remove(e)
-    kv := e.Value.(*entry)
+    kv := e.Value.(*entry[K, V])
     delete(c.items, kv.key)
+    c.expiry.untrack(kv)
+    c.cost -= kv.cost
+    c.notify(kv, reason)
+}
+
+// notify counts kv leaving for the given reason and fires the eviction
+// callback, if there is one.
+func (c *BASELRU[K, V]) notify(kv *entry[K, V], reason EvictReason) {
+    if c.stats != nil {
+        c.stats.evict(reason)
+    }
     if c.onEvict != nil {
-        c.onEvict(kv.key, kv.value)
+        c.onEvict(kv.key, kv.value, reason)
     }
 }
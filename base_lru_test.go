@@ -3,6 +3,7 @@ package go_lru
 import (
     "testing"
     "fmt"
+    "time"
 )
 
 func TestBaseLRU(t *testing.T) {
@@ -169,3 +170,302 @@ func TestBaseLRU_Peek(t *testing.T) {
         t.Errorf("should not have updated recent-ness of 1")
     }
 }
+
+// Test that a cost budget evicts by total cost rather than by count
+func TestBaseLRU_Cost(t *testing.T) {
+    evictCounter := 0
+    onEvicted := func(k string, v int) {
+        evictCounter += 1
+    }
+    l, err := NewBaseLRUWithCostOf[string, int](10, func(k string, v int) int64 {
+        return int64(v)
+    }, onEvicted, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.Add("a", 4)
+    l.Add("b", 4)
+    if l.Cost() != 8 || l.Len() != 2 {
+        t.Fatalf("bad cost: %v len: %v", l.Cost(), l.Len())
+    }
+
+    // Pushes the total to 12, "a" has to go
+    if !l.Add("c", 4) {
+        t.Fatalf("should have an eviction")
+    }
+    if l.Contains("a") || l.Cost() != 8 || evictCounter != 1 {
+        t.Fatalf("bad cost: %v evicted: %v", l.Cost(), evictCounter)
+    }
+
+    // Growing an entry evicts the oldest ones
+    if !l.Add("c", 9) {
+        t.Fatalf("should have an eviction")
+    }
+    if l.Contains("b") || l.Cost() != 9 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // Explicit costs override the cost function
+    l.AddWithCost("d", 100, 1, NoExpiration)
+    if !l.Contains("c") || l.Cost() != 10 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // Entries larger than the budget are not kept
+    l.AddWithCost("e", 0, 11, NoExpiration)
+    if l.Len() != 0 || l.Cost() != 0 {
+        t.Fatalf("bad len: %v cost: %v", l.Len(), l.Cost())
+    }
+
+    if _, err := NewBaseLRUWithCostOf[string, int](0, nil, nil, NoExpiration); err == nil {
+        t.Fatalf("should reject a zero budget")
+    }
+}
+
+// Test that RemoveExpired reclaims only the expired items
+func TestBaseLRU_RemoveExpired(t *testing.T) {
+    evictCounter := 0
+    onEvicted := func(k string, v interface{}) {
+        evictCounter += 1
+    }
+    l, err := NewBaseLRU(128, onEvicted, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 10; i++ {
+        if i%2 == 0 {
+            l.AddWithExpire(fmt.Sprintf("%d", i), i, 10*time.Millisecond)
+        } else {
+            l.Add(fmt.Sprintf("%d", i), i)
+        }
+    }
+    if n := l.RemoveExpired(); n != 0 {
+        t.Fatalf("nothing should be expired yet: %d", n)
+    }
+
+    time.Sleep(20 * time.Millisecond)
+    if n := l.RemoveExpired(); n != 5 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if l.Len() != 5 || evictCounter != 5 {
+        t.Fatalf("bad len: %v evicted: %v", l.Len(), evictCounter)
+    }
+    for i, k := range l.Keys() {
+        if k != fmt.Sprintf("%d", 2*i+1) {
+            t.Fatalf("bad key: %v", k)
+        }
+    }
+}
+
+// Test that the expiration index follows every change to the cache
+func TestBaseLRU_ExpiryIndex(t *testing.T) {
+    var order []string
+    onEvicted := func(k string, v interface{}) {
+        order = append(order, k)
+    }
+    l, err := NewBaseLRU(4, onEvicted, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    checkIndex := func(n int) {
+        t.Helper()
+        if len(l.expiry) != n {
+            t.Fatalf("bad index len: %d want %d", len(l.expiry), n)
+        }
+        for i, ent := range l.expiry {
+            if ent.index != i || l.items[ent.key] == nil {
+                t.Fatalf("stale index entry: %v", ent.key)
+            }
+        }
+    }
+
+    // Inserted in the reverse order of their expiration
+    l.AddWithExpire("a", 1, 40*time.Millisecond)
+    l.AddWithExpire("b", 2, 30*time.Millisecond)
+    l.AddWithExpire("c", 3, 20*time.Millisecond)
+    l.Add("d", 4)
+    checkIndex(3)
+
+    // Updates keep a single index entry
+    l.AddWithExpire("c", 30, 20*time.Millisecond)
+    checkIndex(3)
+
+    // Capacity eviction, Remove and RemoveOldest drop index entries
+    l.AddWithExpire("e", 5, 10*time.Millisecond)
+    checkIndex(3)
+    l.Remove("d")
+    checkIndex(3)
+    l.RemoveOldest()
+    checkIndex(2)
+
+    l.AddWithExpire("f", 6, 10*time.Millisecond)
+    l.AddWithExpire("g", 7, 50*time.Millisecond)
+    checkIndex(4)
+
+    order = nil
+    time.Sleep(45 * time.Millisecond)
+    if n := l.RemoveExpired(); n != 3 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if fmt.Sprint(order) != "[e f c]" {
+        t.Fatalf("should remove in expiration order: %v", order)
+    }
+    checkIndex(1)
+
+    l.Purge()
+    checkIndex(0)
+}
+
+// Test that the eviction callback learns why each entry left
+func TestBaseLRU_EvictReason(t *testing.T) {
+    reasons := make(map[string]EvictReason)
+    onEvicted := func(k string, v int, reason EvictReason) {
+        reasons[fmt.Sprintf("%s=%d", k, v)] = reason
+    }
+    l, err := NewBaseLRUWithEvictReasonOf[string, int](2, onEvicted, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.Add("a", 1)
+    l.Add("a", 2)
+    l.Add("b", 1)
+    l.Add("c", 1)
+    l.Remove("b")
+    l.AddWithExpire("d", 1, time.Millisecond)
+    time.Sleep(5 * time.Millisecond)
+    if _, ok := l.Get("d"); ok {
+        t.Fatalf("d should be expired")
+    }
+    l.Purge()
+
+    want := map[string]EvictReason{
+        "a=1": EvictReplaced,
+        "a=2": EvictCapacity,
+        "b=1": EvictRemoved,
+        "d=1": EvictExpired,
+        "c=1": EvictPurged,
+    }
+    if fmt.Sprint(reasons) != fmt.Sprint(want) {
+        t.Fatalf("bad reasons: %v", reasons)
+    }
+
+    // Plain callbacks are not told about replaced values
+    evictCounter := 0
+    p, err := NewBaseLRUOf[string, int](2, func(k string, v int) {
+        evictCounter += 1
+    }, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    p.Add("a", 1)
+    p.Add("a", 2)
+    if evictCounter != 0 {
+        t.Fatalf("bad evict count: %v", evictCounter)
+    }
+}
+
+func TestBaseLRU_Sliding(t *testing.T) {
+    l, err := NewBaseLRU(128, nil, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithSliding("a", 1, 50*time.Millisecond, 100*time.Millisecond)
+    l.AddWithExpire("b", 2, 50*time.Millisecond)
+    time.Sleep(30 * time.Millisecond)
+    l.Get("a")
+    l.Get("b")
+
+    // Get extends a sliding entry but not a fixed one
+    time.Sleep(30 * time.Millisecond)
+    if _, ok := l.Peek("a"); !ok {
+        t.Fatalf("sliding entry should still be cached")
+    }
+    if _, ok := l.Peek("b"); ok {
+        t.Fatalf("fixed entry should have expired")
+    }
+
+    // The lifetime is capped by max
+    l.Get("a")
+    time.Sleep(50 * time.Millisecond)
+    if _, ok := l.Peek("a"); ok {
+        t.Fatalf("sliding entry should expire at its max lifetime")
+    }
+    if n := l.RemoveExpired(); n != 2 {
+        t.Fatalf("bad removed: %d", n)
+    }
+}
+
+func TestBaseLRU_TTL(t *testing.T) {
+    l, err := NewBaseLRU(128, nil, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.Add("a", 1)
+    l.AddWithExpire("b", 2, time.Minute)
+    if d, ok := l.TTL("a"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    if d, ok := l.TTL("b"); !ok || d <= 59*time.Second || d > time.Minute {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    if _, ok := l.TTL("c"); ok {
+        t.Fatalf("missing key should have no ttl")
+    }
+
+    // Updates honor the new duration
+    l.AddWithExpire("a", 10, 10*time.Millisecond)
+    l.Add("b", 20)
+    if d, ok := l.TTL("b"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("a"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if l.Touch("a", time.Minute) {
+        t.Fatalf("stale key should not be touched")
+    }
+
+    // Touch and SetExpiration change the deadline only
+    l.Add("c", 3)
+    l.Add("d", 4)
+    if !l.Touch("c", 10*time.Millisecond) {
+        t.Fatalf("should touch")
+    }
+    deadline := time.Now().Add(time.Hour)
+    if !l.SetExpiration("d", deadline) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("d"); !ok || d <= 59*time.Minute || d > time.Hour {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    if k, _, _ := l.GetOldest(); k != "a" {
+        t.Fatalf("recent-ness should not change: %v", k)
+    }
+
+    l.AddWithDeadline("e", 5, time.Now().Add(10*time.Millisecond))
+    l.AddWithDeadline("f", 6, time.Time{})
+    l.AddWithSliding("g", 7, 10*time.Millisecond, NoExpiration)
+    l.SetExpiration("g", time.Time{})
+    time.Sleep(20 * time.Millisecond)
+    if n := l.RemoveExpired(); n != 3 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if v, ok := l.Get("g"); !ok || v != 7 {
+        t.Fatalf("bad: %v, %v", v, ok)
+    }
+    if d, ok := l.TTL("g"); !ok || d != NoExpiration {
+        t.Fatalf("SetExpiration should stop sliding: %v, %v", d, ok)
+    }
+    if fmt.Sprint(l.Keys()) != "[b d f g]" {
+        t.Fatalf("bad keys: %v", l.Keys())
+    }
+    if len(l.expiry) != 1 {
+        t.Fatalf("bad index len: %d", len(l.expiry))
+    }
+}
@@ -195,3 +195,576 @@ func BenchmarkARC_Freq(b *testing.B) {
     }
     b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
 }
+
+func BenchmarkCAR_Rand(b *testing.B) {
+    l, err := go_lru.NewCAR(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    var hit, miss int
+    for i := 0; i < 2*b.N; i++ {
+        if i%2 == 0 {
+            l.Add(trace[i], trace[i])
+        } else {
+            _, ok := l.Get(trace[i])
+            if ok {
+                hit++
+            } else {
+                miss++
+            }
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkCAR_Freq(b *testing.B) {
+    l, err := go_lru.NewCAR(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        if i%2 == 0 {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%16384)
+        } else {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+        }
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    for i := 0; i < b.N; i++ {
+        l.Add(trace[i], trace[i])
+    }
+    var hit, miss int
+    for i := 0; i < b.N; i++ {
+        _, ok := l.Get(trace[i])
+        if ok {
+            hit++
+        } else {
+            miss++
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkLIRS_Rand(b *testing.B) {
+    l, err := go_lru.NewLIRS(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    var hit, miss int
+    for i := 0; i < 2*b.N; i++ {
+        if i%2 == 0 {
+            l.Add(trace[i], trace[i])
+        } else {
+            _, ok := l.Get(trace[i])
+            if ok {
+                hit++
+            } else {
+                miss++
+            }
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkLIRS_Freq(b *testing.B) {
+    l, err := go_lru.NewLIRS(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        if i%2 == 0 {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%16384)
+        } else {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+        }
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    for i := 0; i < b.N; i++ {
+        l.Add(trace[i], trace[i])
+    }
+    var hit, miss int
+    for i := 0; i < b.N; i++ {
+        _, ok := l.Get(trace[i])
+        if ok {
+            hit++
+        } else {
+            miss++
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkPolicyARC_Rand(b *testing.B) {
+    l, err := go_lru.NewPolicyCache(8192, go_lru.NewARCPolicy[string](8192), go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    var hit, miss int
+    for i := 0; i < 2*b.N; i++ {
+        if i%2 == 0 {
+            l.Add(trace[i], trace[i])
+        } else {
+            _, ok := l.Get(trace[i])
+            if ok {
+                hit++
+            } else {
+                miss++
+            }
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkPolicyARC_Freq(b *testing.B) {
+    l, err := go_lru.NewPolicyCache(8192, go_lru.NewARCPolicy[string](8192), go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        if i%2 == 0 {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%16384)
+        } else {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+        }
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    for i := 0; i < b.N; i++ {
+        l.Add(trace[i], trace[i])
+    }
+    var hit, miss int
+    for i := 0; i < b.N; i++ {
+        _, ok := l.Get(trace[i])
+        if ok {
+            hit++
+        } else {
+            miss++
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkSieve_Rand(b *testing.B) {
+    l, err := go_lru.NewSieve(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    var hit, miss int
+    for i := 0; i < 2*b.N; i++ {
+        if i%2 == 0 {
+            l.Add(trace[i], trace[i])
+        } else {
+            _, ok := l.Get(trace[i])
+            if ok {
+                hit++
+            } else {
+                miss++
+            }
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkSieve_Freq(b *testing.B) {
+    l, err := go_lru.NewSieve(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        if i%2 == 0 {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%16384)
+        } else {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+        }
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    for i := 0; i < b.N; i++ {
+        l.Add(trace[i], trace[i])
+    }
+    var hit, miss int
+    for i := 0; i < b.N; i++ {
+        _, ok := l.Get(trace[i])
+        if ok {
+            hit++
+        } else {
+            miss++
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkS3FIFO_Rand(b *testing.B) {
+    l, err := go_lru.NewS3FIFO(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    var hit, miss int
+    for i := 0; i < 2*b.N; i++ {
+        if i%2 == 0 {
+            l.Add(trace[i], trace[i])
+        } else {
+            _, ok := l.Get(trace[i])
+            if ok {
+                hit++
+            } else {
+                miss++
+            }
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkS3FIFO_Freq(b *testing.B) {
+    l, err := go_lru.NewS3FIFO(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        if i%2 == 0 {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%16384)
+        } else {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+        }
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    for i := 0; i < b.N; i++ {
+        l.Add(trace[i], trace[i])
+    }
+    var hit, miss int
+    for i := 0; i < b.N; i++ {
+        _, ok := l.Get(trace[i])
+        if ok {
+            hit++
+        } else {
+            miss++
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkTinyLFU_Rand(b *testing.B) {
+    l, err := go_lru.NewTinyLFU(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    var hit, miss int
+    for i := 0; i < 2*b.N; i++ {
+        if i%2 == 0 {
+            l.Add(trace[i], trace[i])
+        } else {
+            _, ok := l.Get(trace[i])
+            if ok {
+                hit++
+            } else {
+                miss++
+            }
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkTinyLFU_Freq(b *testing.B) {
+    l, err := go_lru.NewTinyLFU(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        if i%2 == 0 {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%16384)
+        } else {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+        }
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    for i := 0; i < b.N; i++ {
+        l.Add(trace[i], trace[i])
+    }
+    var hit, miss int
+    for i := 0; i < b.N; i++ {
+        _, ok := l.Get(trace[i])
+        if ok {
+            hit++
+        } else {
+            miss++
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkLFU_Rand(b *testing.B) {
+    l, err := go_lru.NewLFU(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    var hit, miss int
+    for i := 0; i < 2*b.N; i++ {
+        if i%2 == 0 {
+            l.Add(trace[i], trace[i])
+        } else {
+            _, ok := l.Get(trace[i])
+            if ok {
+                hit++
+            } else {
+                miss++
+            }
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+func BenchmarkLFU_Freq(b *testing.B) {
+    l, err := go_lru.NewLFU(8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+
+    trace := make([]string, b.N*2)
+    for i := 0; i < b.N*2; i++ {
+        if i%2 == 0 {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%16384)
+        } else {
+            trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+        }
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    for i := 0; i < b.N; i++ {
+        l.Add(trace[i], trace[i])
+    }
+    var hit, miss int
+    for i := 0; i < b.N; i++ {
+        _, ok := l.Get(trace[i])
+        if ok {
+            hit++
+        } else {
+            miss++
+        }
+    }
+    b.Logf("hit: %d miss: %d ratio: %f", hit, miss, float64(hit)/float64(miss))
+}
+
+// parallelTrace is the key space shared by the parallel benchmarks.
+func parallelTrace() []string {
+    trace := make([]string, 1<<16)
+    for i := range trace {
+        trace[i] = fmt.Sprintf("%d", rand.Int63()%32768)
+    }
+    return trace
+}
+
+// benchmarkParallel runs a read-mostly workload against l from every
+// goroutine of b.RunParallel, one add for every nine gets.
+func benchmarkParallel(b *testing.B, l go_lru.Interface[string, string]) {
+    trace := parallelTrace()
+    for _, k := range trace[:8192] {
+        l.Add(k, k)
+    }
+
+    b.ResetTimer()
+    b.ReportAllocs()
+
+    b.RunParallel(func(pb *testing.PB) {
+        i := rand.Intn(len(trace))
+        for pb.Next() {
+            k := trace[i%len(trace)]
+            if i%10 == 0 {
+                l.Add(k, k)
+            } else {
+                l.Get(k)
+            }
+            i++
+        }
+    })
+}
+
+func BenchmarkLRU_Parallel(b *testing.B) {
+    l, err := go_lru.NewOf[string, string](8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkShardedLRU_Parallel(b *testing.B) {
+    for _, shards := range []int{4, 16, 64} {
+        b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+            l, err := go_lru.NewShardedOf[string, string](shards, 8192, func(size int) (go_lru.Shard[string, string], error) {
+                return go_lru.NewOf[string, string](size, go_lru.NoExpiration)
+            })
+            if err != nil {
+                b.Fatalf("err: %v", err)
+            }
+            benchmarkParallel(b, l)
+        })
+    }
+}
+
+func Benchmark2Q_Parallel(b *testing.B) {
+    l, err := go_lru.New2QOf[string, string](8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkSharded2Q_Parallel(b *testing.B) {
+    l, err := go_lru.NewShardedOf[string, string](16, 8192, func(size int) (go_lru.Shard[string, string], error) {
+        return go_lru.New2QOf[string, string](size, go_lru.NoExpiration)
+    })
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkARC_Parallel(b *testing.B) {
+    l, err := go_lru.NewARCOf[string, string](8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkShardedARC_Parallel(b *testing.B) {
+    l, err := go_lru.NewShardedOf[string, string](16, 8192, func(size int) (go_lru.Shard[string, string], error) {
+        return go_lru.NewARCOf[string, string](size, go_lru.NoExpiration)
+    })
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkCAR_Parallel(b *testing.B) {
+    l, err := go_lru.NewCAROf[string, string](8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkLIRS_Parallel(b *testing.B) {
+    l, err := go_lru.NewLIRSOf[string, string](8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkPolicyARC_Parallel(b *testing.B) {
+    l, err := go_lru.NewPolicyCacheOf[string, string](8192, go_lru.NewARCPolicy[string](8192), go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkSieve_Parallel(b *testing.B) {
+    l, err := go_lru.NewSieveOf[string, string](8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
+
+func BenchmarkS3FIFO_Parallel(b *testing.B) {
+    l, err := go_lru.NewS3FIFOOf[string, string](8192, go_lru.NoExpiration)
+    if err != nil {
+        b.Fatalf("err: %v", err)
+    }
+    benchmarkParallel(b, l)
+}
@@ -0,0 +1,59 @@
+package go_lru
+
+import (
+    "time"
+)
+
+// Interface is the method set shared by every thread-safe cache in this
+// package, so callers can pick an eviction policy at runtime.
+type Interface[K comparable, V any] interface {
+    // Add adds a value to the cache. Returns true if an eviction occurred.
+    Add(key K, value V) bool
+
+    // AddWithExpire adds a value to the cache that expires after d.
+    // Returns true if an eviction occurred.
+    AddWithExpire(key K, value V, d time.Duration) bool
+
+    // Get looks up a key's value from the cache, updating its recent-ness.
+    Get(key K) (V, bool)
+
+    // Peek returns the key's value without updating its recent-ness.
+    Peek(key K) (V, bool)
+
+    // Contains checks if a key is in the cache without updating its
+    // recent-ness.
+    Contains(key K) bool
+
+    // ContainsOrAdd checks if a key is in the cache without updating its
+    // recent-ness, and if not, adds the value. Returns whether found and
+    // whether an eviction occurred.
+    ContainsOrAdd(key K, value V) (ok, evict bool)
+
+    // Remove removes the provided key from the cache, returning if the
+    // key was contained.
+    Remove(key K) bool
+
+    // Keys returns a slice of the keys in the cache, from the first to be
+    // evicted to the last.
+    Keys() []K
+
+    // Len returns the number of items in the cache.
+    Len() int
+
+    // Purge is used to completely clear the cache.
+    Purge()
+}
+
+var (
+    _ Interface[string, interface{}] = (*Cache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*TwoQueueCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*ARCCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*CARCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*LIRSCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*PolicyCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*TinyLFUCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*LFUCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*SieveCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*S3FIFOCache[string, interface{}])(nil)
+    _ Interface[string, interface{}] = (*ShardedCache[string, interface{}])(nil)
+)
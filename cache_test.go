@@ -0,0 +1,90 @@
+package go_lru_test
+
+import (
+    "testing"
+    "time"
+
+    "github.com/wonktnodi/go_lru"
+    "github.com/wonktnodi/go_lru/cachetest"
+)
+
+func TestConformance_LRU(t *testing.T) {
+    cachetest.RunWithOptions(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewOf[string, int](size, d)
+    }, cachetest.Options{LRUOrder: true})
+}
+
+func TestConformance_2Q(t *testing.T) {
+    cachetest.RunWithOptions(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.New2QOf[string, int](size, d)
+    }, cachetest.Options{LRUOrder: true})
+}
+
+func TestConformance_ARC(t *testing.T) {
+    cachetest.RunWithOptions(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewARCOf[string, int](size, d)
+    }, cachetest.Options{LRUOrder: true})
+}
+
+func TestConformance_CAR(t *testing.T) {
+    cachetest.Run(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewCAROf[string, int](size, d)
+    })
+}
+
+func TestConformance_LFU(t *testing.T) {
+    cachetest.Run(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewLFUOf[string, int](size, d)
+    })
+}
+
+func TestConformance_Sieve(t *testing.T) {
+    cachetest.Run(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewSieveOf[string, int](size, d)
+    })
+}
+
+func TestConformance_S3FIFO(t *testing.T) {
+    cachetest.Run(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewS3FIFOOf[string, int](size, d)
+    })
+}
+
+func TestConformance_PolicyLRU(t *testing.T) {
+    cachetest.RunWithOptions(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewPolicyCacheOf[string, int](size, go_lru.NewLRUPolicy[string](), d)
+    }, cachetest.Options{LRUOrder: true})
+}
+
+func TestConformance_Policy2Q(t *testing.T) {
+    cachetest.RunWithOptions(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewPolicyCacheOf[string, int](size, go_lru.New2QPolicy[string](int64(size)), d)
+    }, cachetest.Options{LRUOrder: true})
+}
+
+func TestConformance_PolicyARC(t *testing.T) {
+    cachetest.RunWithOptions(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewPolicyCacheOf[string, int](size, go_lru.NewARCPolicy[string](int64(size)), d)
+    }, cachetest.Options{LRUOrder: true})
+}
+
+func TestConformance_Sharded(t *testing.T) {
+    cachetest.RunWithOptions(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        // A single shard keeps the global recency order the suite checks
+        return go_lru.NewShardedOf[string, int](1, size, func(size int) (go_lru.Shard[string, int], error) {
+            return go_lru.NewOf[string, int](size, d)
+        })
+    }, cachetest.Options{LRUOrder: true})
+}
+
+func TestConformance_TinyLFU(t *testing.T) {
+    cachetest.Run(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewTinyLFUOf[string, int](size, d)
+    })
+}
+
+func TestConformance_LIRS(t *testing.T) {
+    cachetest.Run(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+        return go_lru.NewLIRSOf[string, int](size, d)
+    })
+}
@@ -0,0 +1,375 @@
+// Package cachetest provides a conformance suite that checks an
+// implementation of go_lru.Interface behaves like the caches in go_lru.
+// It can be run against third-party implementations as well:
+//
+//     func TestMyCache(t *testing.T) {
+//         cachetest.Run(t, func(size int, d time.Duration) (go_lru.Interface[string, int], error) {
+//             return NewMyCache(size, d)
+//         })
+//     }
+//
+// The checks of the eviction order, which only hold for caches evicting
+// like an LRU, are enabled with RunWithOptions.
+package cachetest
+
+import (
+    "fmt"
+    "testing"
+    "time"
+
+    "github.com/wonktnodi/go_lru"
+)
+
+// Factory creates an empty cache holding at most size entries, using
+// defaultExpiration for entries added with go_lru.DefaultExpiration.
+type Factory func(size int, defaultExpiration time.Duration) (go_lru.Interface[string, int], error)
+
+// Options selects the optional conformance tests.
+type Options struct {
+    // LRUOrder checks that the cache evicts like an LRU: the oldest key
+    // goes first, Get makes a key the newest while Contains, Peek and
+    // ContainsOrAdd do not, and Keys lists the keys from the oldest to
+    // the newest. It holds for Cache, and for TwoQueueCache and ARCCache
+    // at the small sizes the tests use. Policies weighing frequency, reuse
+    // distance or reference bits, such as LFU, TinyLFU, LIRS, CAR, SIEVE
+    // and S3-FIFO, evict in another order.
+    LRUOrder bool
+}
+
+// Run runs the conformance tests that hold for any eviction policy
+// against caches created by newCache.
+func Run(t *testing.T, newCache Factory) {
+    RunWithOptions(t, newCache, Options{})
+}
+
+// RunWithOptions runs the conformance tests against caches created by
+// newCache, along with the optional ones selected by opts.
+func RunWithOptions(t *testing.T, newCache Factory, opts Options) {
+    tests := []struct {
+        name     string
+        fn       func(*testing.T, Factory)
+        lruOrder bool
+    }{
+        {"AddGet", testAddGet, false},
+        {"Add", testAdd, false},
+        {"Remove", testRemove, false},
+        {"Purge", testPurge, false},
+        {"Contains", testContains, false},
+        {"ContainsOrAdd", testContainsOrAdd, false},
+        {"Peek", testPeek, false},
+        {"Expiration", testExpiration, false},
+        {"DefaultExpiration", testDefaultExpiration, false},
+        {"ContainsRecency", testContainsRecency, true},
+        {"ContainsOrAddEviction", testContainsOrAddEviction, true},
+        {"PeekRecency", testPeekRecency, true},
+        {"GetRecency", testGetRecency, true},
+        {"Keys", testKeys, true},
+    }
+    for _, tt := range tests {
+        if tt.lruOrder && !opts.LRUOrder {
+            continue
+        }
+        t.Run(tt.name, func(t *testing.T) {
+            tt.fn(t, newCache)
+        })
+    }
+}
+
+func mustNew(t *testing.T, newCache Factory, size int, d time.Duration) go_lru.Interface[string, int] {
+    t.Helper()
+    l, err := newCache(size, d)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    return l
+}
+
+// Test that values can be read back and the size is bounded
+func testAddGet(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 128, go_lru.NoExpiration)
+
+    for i := 0; i < 256; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    for _, k := range l.Keys() {
+        if v, ok := l.Get(k); !ok || fmt.Sprint(v) != k {
+            t.Fatalf("bad key: %v", k)
+        }
+    }
+    if _, ok := l.Get("missing"); ok {
+        t.Fatalf("should not contain missing")
+    }
+
+    l.Add("255", 1000)
+    if v, ok := l.Get("255"); !ok || v != 1000 {
+        t.Fatalf("update not visible: %v, %v", v, ok)
+    }
+}
+
+// Test that Add returns true/false if an eviction occurred
+func testAdd(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 1, go_lru.NoExpiration)
+
+    if l.Add("1", 1) {
+        t.Errorf("should not have an eviction")
+    }
+    if l.Add("1", 1) {
+        t.Errorf("update should not have an eviction")
+    }
+    if !l.Add("2", 2) {
+        t.Errorf("should have an eviction")
+    }
+    if l.Len() != 1 {
+        t.Errorf("bad len: %v", l.Len())
+    }
+}
+
+// Test that Remove reports whether the key was contained
+func testRemove(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 2, go_lru.NoExpiration)
+
+    l.Add("1", 1)
+    if !l.Remove("1") {
+        t.Errorf("1 should have been removed")
+    }
+    if l.Remove("1") {
+        t.Errorf("1 should not be contained anymore")
+    }
+    if _, ok := l.Get("1"); ok {
+        t.Errorf("1 should be deleted")
+    }
+    if l.Len() != 0 {
+        t.Errorf("bad len: %v", l.Len())
+    }
+}
+
+// Test that Purge empties the cache
+func testPurge(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 4, go_lru.NoExpiration)
+
+    for i := 0; i < 4; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    l.Get("0")
+    l.Purge()
+    if l.Len() != 0 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if k := l.Keys(); len(k) != 0 {
+        t.Fatalf("bad keys: %v", k)
+    }
+    for i := 0; i < 4; i++ {
+        if l.Contains(fmt.Sprint(i)) {
+            t.Fatalf("should contain nothing")
+        }
+    }
+}
+
+// Test that Contains reports the cached keys only
+func testContains(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 4, go_lru.NoExpiration)
+
+    l.Add("1", 1)
+    l.Add("2", 2)
+    if !l.Contains("1") || !l.Contains("2") {
+        t.Errorf("1 and 2 should be contained")
+    }
+    if l.Contains("3") {
+        t.Errorf("3 should not be contained")
+    }
+}
+
+// Test that Contains doesn't update recent-ness
+func testContainsRecency(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 2, go_lru.NoExpiration)
+
+    l.Add("1", 1)
+    l.Add("2", 2)
+    if !l.Contains("1") {
+        t.Errorf("1 should be contained")
+    }
+
+    l.Add("3", 3)
+    if l.Contains("1") {
+        t.Errorf("Contains should not have updated recent-ness of 1")
+    }
+}
+
+// Test that ContainsOrAdd only adds missing keys
+func testContainsOrAdd(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 4, go_lru.NoExpiration)
+
+    l.Add("1", 1)
+    l.Add("2", 2)
+    contains, evict := l.ContainsOrAdd("1", 100)
+    if !contains {
+        t.Errorf("1 should be contained")
+    }
+    if evict {
+        t.Errorf("nothing should be evicted here")
+    }
+    if v, _ := l.Peek("1"); v != 1 {
+        t.Errorf("1 should not have been updated: %v", v)
+    }
+
+    contains, evict = l.ContainsOrAdd("3", 3)
+    if contains {
+        t.Errorf("3 should not have been contained")
+    }
+    if evict {
+        t.Errorf("nothing should be evicted here")
+    }
+    if v, ok := l.Peek("3"); !ok || v != 3 {
+        t.Errorf("3 should be set to 3: %v, %v", v, ok)
+    }
+}
+
+// Test that ContainsOrAdd doesn't update recent-ness, and evicts to
+// add a missing key
+func testContainsOrAddEviction(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 2, go_lru.NoExpiration)
+
+    l.Add("1", 1)
+    l.Add("2", 2)
+    contains, evict := l.ContainsOrAdd("1", 100)
+    if !contains {
+        t.Errorf("1 should be contained")
+    }
+    if evict {
+        t.Errorf("nothing should be evicted here")
+    }
+    if v, _ := l.Peek("1"); v != 1 {
+        t.Errorf("1 should not have been updated: %v", v)
+    }
+
+    l.Add("3", 3)
+    contains, evict = l.ContainsOrAdd("1", 1)
+    if contains {
+        t.Errorf("1 should not have been contained")
+    }
+    if !evict {
+        t.Errorf("an eviction should have occurred")
+    }
+    if !l.Contains("1") {
+        t.Errorf("now 1 should be contained")
+    }
+}
+
+// Test that Peek returns the cached values only
+func testPeek(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 4, go_lru.NoExpiration)
+
+    l.Add("1", 1)
+    if v, ok := l.Peek("1"); !ok || v != 1 {
+        t.Errorf("1 should be set to 1: %v, %v", v, ok)
+    }
+    if _, ok := l.Peek("2"); ok {
+        t.Errorf("2 should not be contained")
+    }
+}
+
+// Test that Peek doesn't update recent-ness
+func testPeekRecency(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 2, go_lru.NoExpiration)
+
+    l.Add("1", 1)
+    l.Add("2", 2)
+    if v, ok := l.Peek("1"); !ok || v != 1 {
+        t.Errorf("1 should be set to 1: %v, %v", v, ok)
+    }
+
+    l.Add("3", 3)
+    if l.Contains("1") {
+        t.Errorf("should not have updated recent-ness of 1")
+    }
+}
+
+// Test that Get updates recent-ness
+func testGetRecency(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 2, go_lru.NoExpiration)
+
+    l.Add("1", 1)
+    l.Add("2", 2)
+    if v, ok := l.Get("1"); !ok || v != 1 {
+        t.Errorf("1 should be set to 1: %v, %v", v, ok)
+    }
+
+    l.Add("3", 3)
+    if !l.Contains("1") {
+        t.Errorf("Get should have updated recent-ness of 1")
+    }
+    if l.Contains("2") {
+        t.Errorf("2 should have been evicted")
+    }
+}
+
+// Test that Keys are ordered from the first to be evicted to the last
+func testKeys(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 4, go_lru.NoExpiration)
+
+    for i := 0; i < 3; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    keys := l.Keys()
+    if len(keys) != 3 {
+        t.Fatalf("bad keys: %v", keys)
+    }
+    for i, k := range keys {
+        if k != fmt.Sprint(i) {
+            t.Fatalf("out of order keys: %v", keys)
+        }
+    }
+
+    l.Get("0")
+    keys = l.Keys()
+    if len(keys) != 3 || keys[2] != "0" {
+        t.Fatalf("0 should be the newest key: %v", keys)
+    }
+}
+
+// Test that entries are invisible once they expire
+func testExpiration(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 4, go_lru.NoExpiration)
+
+    l.AddWithExpire("1", 1, 50*time.Millisecond)
+    l.Add("2", 2)
+    if v, ok := l.Get("1"); !ok || v != 1 {
+        t.Fatalf("1 should be set to 1: %v, %v", v, ok)
+    }
+
+    time.Sleep(100 * time.Millisecond)
+    if _, ok := l.Get("1"); ok {
+        t.Fatalf("1 should be expired")
+    }
+    if _, ok := l.Peek("1"); ok {
+        t.Fatalf("Peek should not return expired entries")
+    }
+    if l.Contains("1") {
+        t.Fatalf("Contains should not report expired entries")
+    }
+    if v, ok := l.Get("2"); !ok || v != 2 {
+        t.Fatalf("2 should not expire: %v, %v", v, ok)
+    }
+}
+
+// Test that DefaultExpiration uses the cache's default
+func testDefaultExpiration(t *testing.T, newCache Factory) {
+    l := mustNew(t, newCache, 4, 50*time.Millisecond)
+
+    l.AddWithExpire("1", 1, go_lru.DefaultExpiration)
+    l.AddWithExpire("2", 2, go_lru.NoExpiration)
+    if !l.Contains("1") || !l.Contains("2") {
+        t.Fatalf("1 and 2 should be contained")
+    }
+
+    time.Sleep(100 * time.Millisecond)
+    if l.Contains("1") {
+        t.Fatalf("1 should be expired")
+    }
+    if !l.Contains("2") {
+        t.Fatalf("2 should not expire")
+    }
+}
@@ -0,0 +1,560 @@
+package go_lru
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// CARCache is a thread-safe fixed size CAR cache (Clock with Adaptive
+// Replacement). Like ARC, it splits the cache between the entries seen
+// once (T1) and those seen again (T2), tracks recently evicted keys of
+// each (B1 and B2) and learns from their hits how large T1 should be.
+// T1 and T2 are clocks rather than LRU lists though: a hit only sets
+// the reference bit of an entry, and the hand moves the referenced
+// entries to the tail of T2 as it looks for one to evict. Hits therefore
+// never reorder the lists, and Get only takes the shared lock.
+type CARCache[K comparable, V any] struct {
+    size int64 // Size is the total capacity of the cache, in entries or cost
+    p    int64 // P is the dynamic target size of T1
+
+    t1 *BASELRU[K, V] // T1 is the clock of recently accessed items, head at the back
+    b1 *BASELRU[K, V] // B1 is the LRU for evictions from t1
+
+    t2 *BASELRU[K, V] // T2 is the clock of frequently accessed items, head at the back
+    b2 *BASELRU[K, V] // B2 is the LRU for evictions from t2
+
+    lock      sync.RWMutex
+    janitor   *janitor
+    loads     loadGroup[K, V]
+    refresher refresher[K, V]
+    stats     *counters
+}
+
+// NewCAR creates a CAR cache of the given size with string keys and
+// untyped values.
+func NewCAR(size int, defaultExpiration time.Duration) (*CARCache[string, interface{}], error) {
+    return NewCAROf[string, interface{}](size, defaultExpiration)
+}
+
+// NewCARWithCost creates a CAR cache with string keys and untyped values
+// that keeps the total cost of its entries within maxCost.
+func NewCARWithCost(maxCost int64, defaultExpiration time.Duration, costFunc func(key string, value interface{}) int64) (*CARCache[string, interface{}], error) {
+    return NewCARWithCostOf[string, interface{}](maxCost, defaultExpiration, costFunc)
+}
+
+// NewCARWithEvict creates a CAR cache of the given size with string
+// keys, untyped values and the given eviction callback.
+func NewCARWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*CARCache[string, interface{}], error) {
+    return NewCARWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewCARWithEvictReason creates a CAR cache of the given size with
+// string keys and untyped values whose eviction callback also learns why
+// an entry left the cache.
+func NewCARWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*CARCache[string, interface{}], error) {
+    return NewCARWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewCAROf creates a CAR cache of the given size for any comparable key
+// type and value type.
+func NewCAROf[K comparable, V any](size int, defaultExpiration time.Duration) (*CARCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return newCAR[K, V](size, 0, nil, nil, defaultExpiration), nil
+}
+
+// NewCARWithEvictOf creates a CAR cache of the given size with the given
+// eviction callback. It is called once for every entry dropped from T1
+// or T2, never for moves from T1 to T2 or for ghost entries.
+func NewCARWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*CARCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return newCAR[K, V](size, 0, nil, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration), nil
+}
+
+// NewCARWithEvictReasonOf creates a CAR cache of the given size whose
+// eviction callback also learns why an entry left the cache. Entries
+// moving from T1 to T2 and ghost entries never trigger the callback.
+func NewCARWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*CARCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return newCAR[K, V](size, 0, nil, onEvicted, defaultExpiration), nil
+}
+
+// NewCARWithCostOf creates a CAR cache that keeps the total cost of its
+// entries within maxCost, P then becomes a cost target for T1. Entries
+// added without an explicit cost are weighed by costFunc, or cost 1 if
+// it is nil.
+func NewCARWithCostOf[K comparable, V any](maxCost int64, defaultExpiration time.Duration, costFunc func(key K, value V) int64) (*CARCache[K, V], error) {
+    if maxCost <= 0 {
+        return nil, errors.New("Must provide a positive max cost")
+    }
+    return newCAR[K, V](0, maxCost, costFunc, nil, defaultExpiration), nil
+}
+
+// newCAR creates a CAR cache bounded either by size entries or by
+// maxCost total cost.
+func newCAR[K comparable, V any](size int, maxCost int64, costFunc CostFunc[K, V], onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) *CARCache[K, V] {
+    capacity := int64(size)
+    if maxCost > 0 {
+        capacity = maxCost
+    }
+
+    // Ghost entries keep the cost of the entry they stand for but are
+    // invisible to the eviction callback
+    c := &CARCache[K, V]{
+        size:  capacity,
+        t1:    newBaseLRU[K, V](size, maxCost, costFunc, onEvict, defaultExpiration),
+        b1:    newBaseLRU[K, V](size, maxCost, nil, nil, defaultExpiration),
+        t2:    newBaseLRU[K, V](size, maxCost, costFunc, onEvict, defaultExpiration),
+        b2:    newBaseLRU[K, V](size, maxCost, nil, nil, defaultExpiration),
+        stats: new(counters),
+    }
+    c.t1.stats = c.stats
+    c.t2.stats = c.stats
+    return c
+}
+
+// Get looks up a key's value from the cache, setting its reference bit.
+// Only sliding, refreshed or expired entries take the exclusive lock.
+func (c *CARCache[K, V]) Get(key K) (V, bool) {
+    c.lock.RLock()
+    ent, ok := c.peekEntry(key)
+    if ok && ent.deferrable() {
+        value := ent.value
+        ent.visit(1)
+        c.lock.RUnlock()
+        c.stats.hit()
+        return value, true
+    }
+    c.lock.RUnlock()
+    if !ok {
+        c.stats.miss()
+        var empty V
+        return empty, false
+    }
+    return c.getExclusive(key)
+}
+
+// getExclusive does the work of Get under the exclusive lock, for the
+// entries that a read updates.
+func (c *CARCache[K, V]) getExclusive(key K) (V, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, clock := range c.clocks() {
+        if ent, ok := clock.peekEntry(key); ok {
+            if !ent.Expired() {
+                ent.visit(1)
+                clock.slide(ent)
+                return c.hit(ent)
+            }
+            clock.removeKey(key, EvictExpired)
+        }
+    }
+
+    // No hit
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// hit returns the value of a cache hit, starting a background refresh
+// if it is past its soft TTL.
+func (c *CARCache[K, V]) hit(ent *entry[K, V]) (V, bool) {
+    c.stats.hit()
+    if loader, ok := c.refresher.due(ent, time.Now().UnixNano()); ok {
+        go c.refresh(ent, loader)
+    }
+    return ent.value, true
+}
+
+// refresh reloads ent, dropping the result if the entry was updated or
+// removed in the meantime.
+func (c *CARCache[K, V]) refresh(ent *entry[K, V], loader LoaderFunc[K, V]) {
+    value, d, err := loader(context.Background(), ent.key)
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, clock := range c.clocks() {
+        if cur, ok := clock.peekEntry(ent.key); ok {
+            if cur != ent || !ent.refreshing {
+                return
+            }
+            if err != nil {
+                ent.refreshing = false
+                return
+            }
+            clock.refreshEntry(ent, value, d)
+            c.makeRoom(0)
+            return
+        }
+    }
+}
+
+// GetOrLoad looks up a key's value from the cache, or loads it with
+// loader and adds it with the duration the loader returns. Concurrent
+// calls for the same key share a single load; callers waiting on it
+// give up when their ctx is done, but the load carries on for the
+// others. A panic in the loader is raised again in each of them. The
+// cache is not locked while the loader runs.
+func (c *CARCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+    return c.loads.load(ctx, c, key, loader)
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+func (c *CARCache[K, V]) Add(key K, value V) bool {
+    return c.AddWithExpire(key, value, NoExpiration)
+}
+
+// AddWithExpire adds a value to the cache that expires after d.
+// Returns true if an eviction occurred.
+func (c *CARCache[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.t1.costOf(key, value), c.t1.ttlOf(d))
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *CARCache[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, cost, c.t1.ttlOf(d))
+}
+
+// AddWithSliding adds a value that expires after d, each successful Get
+// pushing its deadline d further. A positive max caps its lifetime from
+// now regardless of accesses. Returns true if an eviction occurred.
+func (c *CARCache[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.t1.costOf(key, value), c.t1.slidingTTL(d, max))
+}
+
+// AddWithRefresh adds a value that expires after d. Once the soft TTL
+// refresh has passed, Get keeps returning it but also reloads it in the
+// background with the loader registered by SetRefreshLoader.
+// Returns true if an eviction occurred.
+func (c *CARCache[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.t1.costOf(key, value), ttl{expiration: c.t1.expireAt(d), refresh: refresh})
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *CARCache[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.t1.costOf(key, value), deadlineTTL(deadline))
+}
+
+// SetRefreshLoader registers the loader used to refresh entries added
+// with AddWithRefresh. The duration it returns restarts the hard TTL.
+func (c *CARCache[K, V]) SetRefreshLoader(loader LoaderFunc[K, V]) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.refresher.loader = loader
+}
+
+// ContainsOrAdd checks if a key is in the cache without setting its
+// reference bit, and if not, adds the value.
+// Returns whether found and whether an eviction occurred.
+func (c *CARCache[K, V]) ContainsOrAdd(key K, value V) (ok, evict bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if c.t1.Contains(key) || c.t2.Contains(key) {
+        return true, false
+    }
+    return false, c.add(key, value, c.t1.costOf(key, value), c.t1.ttlOf(NoExpiration))
+}
+
+// add does the work of AddWithCost, the caller must hold the lock.
+func (c *CARCache[K, V]) add(key K, value V, cost int64, t ttl) bool {
+    // Update the value in place, which counts as a reference
+    for _, clock := range c.clocks() {
+        if ent, ok := clock.peekEntry(key); ok {
+            clock.update(ent, value, t, cost)
+            ent.visit(1)
+            return c.makeRoom(0)
+        }
+    }
+
+    // Make room first, the hand may move entries between the lists
+    evicted := c.makeRoom(cost)
+
+    // Check if this value was recently evicted from T1, which is then
+    // too small: increase P appropriately and add it to T2
+    if ghost, ok := c.b1.peekEntry(key); ok {
+        c.stats.ghostHit()
+        delta := arcDelta(ghost.cost, c.b2.Cost(), c.b1.Cost())
+        if c.p+delta >= c.size {
+            c.p = c.size
+        } else {
+            c.p += delta
+        }
+        c.b1.Remove(key)
+        c.t2.addWithCost(key, value, t, cost)
+        return evicted
+    }
+
+    // Check if this value was recently evicted from T2, which is then
+    // too small: decrease P appropriately and add it back to T2
+    if ghost, ok := c.b2.peekEntry(key); ok {
+        c.stats.ghostHit()
+        delta := arcDelta(ghost.cost, c.b1.Cost(), c.b2.Cost())
+        if delta >= c.p {
+            c.p = 0
+        } else {
+            c.p -= delta
+        }
+        c.b2.Remove(key)
+        c.t2.addWithCost(key, value, t, cost)
+        return evicted
+    }
+
+    // Keep T1 and B1 within the capacity, and the whole directory within
+    // twice the capacity
+    if c.t1.Cost()+c.b1.Cost()+cost > c.size {
+        for c.b1.Len() > 0 && c.t1.Cost()+c.b1.Cost()+cost > c.size {
+            c.b1.RemoveOldest()
+        }
+    } else {
+        for c.b2.Len() > 0 && c.t1.Cost()+c.t2.Cost()+c.b1.Cost()+c.b2.Cost()+cost > 2*c.size {
+            c.b2.RemoveOldest()
+        }
+    }
+
+    // Add to the tail of the recently seen clock
+    c.t1.addWithCost(key, value, t, cost)
+    return evicted
+}
+
+// makeRoom evicts from T1 or T2 until an entry of the given cost fits.
+// Returns true if an entry was evicted.
+func (c *CARCache[K, V]) makeRoom(cost int64) bool {
+    evicted := false
+    for c.t1.Cost()+c.t2.Cost()+cost > c.size && c.replace() {
+        evicted = true
+    }
+    return evicted
+}
+
+// replace moves the hand of T1 if it is larger than P, of T2 otherwise,
+// until it finds an entry whose reference bit is clear, and evicts it to
+// its ghost list. Referenced entries have their bit cleared and move to
+// the tail of T2. Returns true if an entry was evicted.
+func (c *CARCache[K, V]) replace() bool {
+    var empty V
+    for {
+        target := c.p
+        if target < 1 {
+            target = 1
+        }
+        if c.t1.Len() > 0 && (c.t1.Cost() >= target || c.t2.Len() == 0) {
+            ent, _ := c.t1.takeOldest()
+            if atomic.LoadUint32(&ent.visits) > 0 {
+                atomic.StoreUint32(&ent.visits, 0)
+                c.t2.put(ent)
+                continue
+            }
+            c.t1.notify(ent, EvictCapacity)
+            c.b1.addWithCost(ent.key, empty, ttl{}, ent.cost)
+            return true
+        }
+        if c.t2.Len() == 0 {
+            return false
+        }
+        ent, _ := c.t2.takeOldest()
+        if atomic.LoadUint32(&ent.visits) > 0 {
+            atomic.StoreUint32(&ent.visits, 0)
+            c.t2.put(ent)
+            continue
+        }
+        c.t2.notify(ent, EvictCapacity)
+        c.b2.addWithCost(ent.key, empty, ttl{}, ent.cost)
+        return true
+    }
+}
+
+// clocks returns T1 and T2.
+func (c *CARCache[K, V]) clocks() [2]*BASELRU[K, V] {
+    return [2]*BASELRU[K, V]{c.t1, c.t2}
+}
+
+// peekEntry returns the key's entry regardless of its expiration.
+func (c *CARCache[K, V]) peekEntry(key K) (*entry[K, V], bool) {
+    if ent, ok := c.t1.peekEntry(key); ok {
+        return ent, true
+    }
+    return c.t2.peekEntry(key)
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *CARCache[K, V]) TTL(key K) (time.Duration, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    if d, ok := c.t1.TTL(key); ok {
+        return d, ok
+    }
+    return c.t2.TTL(key)
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration,
+// without updating its value or reference bit. Returns false if the key
+// is missing or stale.
+func (c *CARCache[K, V]) Touch(key K, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.t1.Touch(key, d) || c.t2.Touch(key, d)
+}
+
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time, without updating its value or reference bit. Returns false
+// if the key is missing or stale.
+func (c *CARCache[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.t1.SetExpiration(key, deadline) || c.t2.SetExpiration(key, deadline)
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (c *CARCache[K, V]) Stats() Stats {
+    s := c.stats.snapshot()
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    s.Len = c.t1.Len() + c.t2.Len()
+    s.Cost = c.t1.Cost() + c.t2.Cost()
+    s.CAR = &ARCStats{
+        P:  c.p,
+        T1: c.t1.Len(),
+        T2: c.t2.Len(),
+        B1: c.b1.Len(),
+        B2: c.b2.Len(),
+    }
+    return s
+}
+
+// ResetStats zeroes the counters of the cache, to report statistics per
+// time window.
+func (c *CARCache[K, V]) ResetStats() {
+    c.stats.reset()
+}
+
+// RemoveExpired removes every expired entry from T1 and T2, returning
+// how many were removed. Expired entries are not remembered as ghosts.
+func (c *CARCache[K, V]) RemoveExpired() int {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.t1.RemoveExpired() + c.t2.RemoveExpired()
+}
+
+// StartJanitor starts a background goroutine that removes expired
+// entries every interval, replacing any janitor started before. Call
+// Close to stop it. A non-positive interval is ignored.
+func (c *CARCache[K, V]) StartJanitor(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    j := startJanitor(interval, func() { c.RemoveExpired() })
+    c.lock.Lock()
+    old := c.janitor
+    c.janitor = j
+    c.lock.Unlock()
+    old.Stop()
+}
+
+// Close stops the janitor, if any. The cache remains usable.
+func (c *CARCache[K, V]) Close() {
+    c.lock.Lock()
+    j := c.janitor
+    c.janitor = nil
+    c.lock.Unlock()
+    j.Stop()
+}
+
+// Len returns the number of cached entries
+func (c *CARCache[K, V]) Len() int {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.t1.Len() + c.t2.Len()
+}
+
+// Cost returns the total cost of the cached entries
+func (c *CARCache[K, V]) Cost() int64 {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.t1.Cost() + c.t2.Cost()
+}
+
+// Keys returns all the cached keys, roughly from the first to be evicted
+// to the last: the entries of T1 then T2 whose reference bit is clear,
+// then the referenced ones, each from the head of their clock to the
+// tail.
+func (c *CARCache[K, V]) Keys() []K {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    keys := make([]K, 0, c.t1.Len()+c.t2.Len())
+    var referenced []K
+    for _, clock := range c.clocks() {
+        for el := clock.evictList.Back(); el != nil; el = el.Prev() {
+            ent := el.Value.(*entry[K, V])
+            if atomic.LoadUint32(&ent.visits) == 0 {
+                keys = append(keys, ent.key)
+            } else {
+                referenced = append(referenced, ent.key)
+            }
+        }
+    }
+    return append(keys, referenced...)
+}
+
+// Remove is used to purge a key from the cache, returning if the key
+// was contained. Forgetting a ghost entry does not count as containing it.
+func (c *CARCache[K, V]) Remove(key K) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if c.t1.Remove(key) || c.t2.Remove(key) {
+        return true
+    }
+    if !c.b1.Remove(key) {
+        c.b2.Remove(key)
+    }
+    return false
+}
+
+// Purge is used to clear the cache
+func (c *CARCache[K, V]) Purge() {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.t1.Purge()
+    c.t2.Purge()
+    c.b1.Purge()
+    c.b2.Purge()
+}
+
+// Contains is used to check if the cache contains a key without setting
+// its reference bit.
+func (c *CARCache[K, V]) Contains(key K) bool {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.t1.Contains(key) || c.t2.Contains(key)
+}
+
+// Peek is used to inspect the cache value of a key without setting its
+// reference bit.
+func (c *CARCache[K, V]) Peek(key K) (V, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    if val, ok := c.t1.Peek(key); ok {
+        return val, ok
+    }
+    return c.t2.Peek(key)
+}
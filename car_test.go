@@ -0,0 +1,333 @@
+package go_lru
+
+import (
+    "fmt"
+    "math/rand"
+    "testing"
+    "time"
+)
+
+func TestCAR_RandomOps(t *testing.T) {
+    size := 128
+    l, err := NewCAR(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    n := 200000
+    for i := 0; i < n; i++ {
+        key := fmt.Sprintf("%d", rand.Int63()%512)
+        r := rand.Int63()
+        switch r % 3 {
+        case 0:
+            l.Add(key, key)
+        case 1:
+            l.Get(key)
+        case 2:
+            l.Remove(key)
+        }
+
+        if l.t1.Len()+l.t2.Len() > size {
+            t.Fatalf("bad: t1: %d t2: %d b1: %d b2: %d p: %d",
+                l.t1.Len(), l.t2.Len(), l.b1.Len(), l.b2.Len(), l.p)
+        }
+        if l.t1.Len()+l.b1.Len() > size || l.t1.Len()+l.t2.Len()+l.b1.Len()+l.b2.Len() > 2*size {
+            t.Fatalf("bad: t1: %d t2: %d b1: %d b2: %d p: %d",
+                l.t1.Len(), l.t2.Len(), l.b1.Len(), l.b2.Len(), l.p)
+        }
+    }
+}
+
+// Test that a hit only sets the reference bit, the hand moving the
+// entry to t2 when it goes by
+func TestCAR_Get_RecentToFrequent(t *testing.T) {
+    l, err := NewCAR(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Touch all the entries, should be in t1
+    for i := 0; i < 128; i++ {
+        l.Add(fmt.Sprintf("%d", i), i)
+    }
+    for i := 0; i < 128; i++ {
+        if _, ok := l.Get(fmt.Sprint(i)); !ok {
+            t.Fatalf("missing: %d", i)
+        }
+    }
+    if n := l.t1.Len(); n != 128 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // The hand moves every referenced entry to t2 before evicting 0
+    l.Add("128", 128)
+    if n := l.t1.Len(); n != 1 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.t2.Len(); n != 127 {
+        t.Fatalf("bad: %d", n)
+    }
+    if l.Contains("0") || l.b2.Len() != 1 {
+        t.Fatalf("0 should have been evicted from t2")
+    }
+}
+
+func TestCAR_Adaptive(t *testing.T) {
+    l, err := NewCAR(4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Fill t1
+    for i := 0; i < 4; i++ {
+        l.Add(fmt.Sprintf("%d", i), i)
+    }
+    if n := l.t1.Len(); n != 4 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // Reference without moving
+    l.Get("0")
+    l.Get("1")
+    if n := l.t2.Len(); n != 0 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // The hand moves 0 and 1 to t2, and evicts 2 from t1
+    l.Add("4", 4)
+    if n := l.t2.Len(); n != 2 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.b1.Len(); n != 1 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // Current state
+    // t1 : (head) [3, 4] (tail)
+    // t2 : (head) [0, 1] (tail)
+    // b1 : (MRU) [2] (LRU)
+    // b2 : (MRU) [] (LRU)
+
+    // Add 2, should cause hit on b1
+    l.Add("2", 2)
+    if n := l.b1.Len(); n != 1 {
+        t.Fatalf("bad: %d", n)
+    }
+    if l.p != 1 {
+        t.Fatalf("bad: %d", l.p)
+    }
+    if n := l.t2.Len(); n != 3 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // Current state
+    // t1 : (head) [4] (tail)
+    // t2 : (head) [0, 1, 2] (tail)
+    // b1 : (MRU) [3] (LRU)
+    // b2 : (MRU) [] (LRU)
+
+    // Add 4, should reference it in t1
+    l.Add("4", 4)
+    if n := l.t1.Len(); n != 1 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.t2.Len(); n != 3 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // Add 5, the hand moves 4 to t2 and evicts 0 to b2
+    l.Add("5", 5)
+    if n := l.t1.Len(); n != 1 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.t2.Len(); n != 3 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.b2.Len(); n != 1 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // Current state
+    // t1 : (head) [5] (tail)
+    // t2 : (head) [1, 2, 4] (tail)
+    // b1 : (MRU) [3] (LRU)
+    // b2 : (MRU) [0] (LRU)
+
+    // Add 0, should decrease p
+    l.Add("0", 0)
+    if n := l.t1.Len(); n != 0 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.t2.Len(); n != 4 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.b1.Len(); n != 2 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.b2.Len(); n != 0 {
+        t.Fatalf("bad: %d", n)
+    }
+    if l.p != 0 {
+        t.Fatalf("bad: %d", l.p)
+    }
+
+    // Current state
+    // t1 : (head) [] (tail)
+    // t2 : (head) [1, 2, 4, 0] (tail)
+    // b1 : (MRU) [5, 3] (LRU)
+    // b2 : (MRU) [] (LRU)
+}
+
+func TestCAR(t *testing.T) {
+    l, err := NewCAR(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 256; i++ {
+        l.Add(fmt.Sprintf("%d", i), i)
+    }
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+
+    for i, k := range l.Keys() {
+        if v, ok := l.Get(k); !ok || fmt.Sprint(v) != k || v.(int) != i+128 {
+            t.Fatalf("bad key: %v", k)
+        }
+    }
+    for i := 0; i < 128; i++ {
+        if _, ok := l.Get(fmt.Sprintf("%d", i)); ok {
+            t.Fatalf("should be evicted")
+        }
+    }
+
+    l.AddWithExpire(fmt.Sprint(256), 256, 20*time.Millisecond)
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if v, ok := l.Get(fmt.Sprint(256)); !ok || v.(int) != 256 {
+        t.Fatalf("bad key: %v", 256)
+    }
+    time.Sleep(30 * time.Millisecond)
+    if _, ok := l.Get(fmt.Sprint(256)); ok {
+        t.Fatalf("bad key: %v", 256)
+    }
+
+    for i := 128; i < 192; i++ {
+        l.Remove(fmt.Sprintf("%d", i))
+        if _, ok := l.Get(fmt.Sprintf("%d", i)); ok {
+            t.Fatalf("should be deleted")
+        }
+    }
+
+    l.Purge()
+    if l.Len() != 0 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if _, ok := l.Get(fmt.Sprint(200)); ok {
+        t.Fatalf("should contain nothing")
+    }
+}
+
+// Test that P adapts in units of cost
+func TestCAR_Cost(t *testing.T) {
+    l, err := NewCARWithCostOf[string, int](100, NoExpiration, func(k string, v int) int64 {
+        return int64(v)
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Fill t1 and reference half of it
+    for i := 0; i < 4; i++ {
+        l.Add(fmt.Sprint(i), 25)
+    }
+    l.Get("0")
+    l.Get("1")
+
+    // Evict "2" from t1 to b1, keeping its cost
+    l.Add("4", 20)
+    if n := l.t2.Cost(); n != 50 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.b1.Cost(); n != 25 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.Cost(); n > 100 {
+        t.Fatalf("over budget: %d", n)
+    }
+
+    // A hit on b1 grows P by the ghost's cost
+    l.Add("2", 25)
+    if l.p != 25 {
+        t.Fatalf("bad: %d", l.p)
+    }
+    if n := l.Cost(); n > 100 {
+        t.Fatalf("over budget: %d", n)
+    }
+    if v, ok := l.Get("2"); !ok || v != 25 {
+        t.Fatalf("2 should be cached")
+    }
+}
+
+func TestCAR_Sliding(t *testing.T) {
+    l, err := NewCAR(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithSliding("a", 1, 50*time.Millisecond, NoExpiration)
+    for i := 0; i < 3; i++ {
+        time.Sleep(30 * time.Millisecond)
+        if _, ok := l.Get("a"); !ok {
+            t.Fatalf("sliding entry should still be cached")
+        }
+    }
+
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Peek("a"); ok {
+        t.Fatalf("sliding entry should have expired")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+}
+
+func TestCAR_TTL(t *testing.T) {
+    l, err := NewCAR(2, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Entries of t1 and t2 alike, the hand moving both referenced entries
+    // to t2 then evicting "a"
+    l.AddWithExpire("a", 1, 10*time.Millisecond)
+    l.Add("b", 2)
+    l.Get("a")
+    l.Get("b")
+    l.Add("c", 3)
+    if l.t2.Len() != 1 || l.Contains("a") {
+        t.Fatalf("b should have moved to t2")
+    }
+    if !l.Touch("c", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("c"); !ok || d <= 59*time.Second {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Updates honor the new duration
+    l.Add("c", 30)
+    if d, ok := l.TTL("c"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("b"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+}
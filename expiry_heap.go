@@ -0,0 +1,92 @@
+package go_lru
+
+import (
+    "container/heap"
+    "time"
+)
+
+// expiryHeap is a min-heap of entries ordered by Expiration, so expired
+// entries can be found without scanning the whole cache. Entries that
+// never expire are not part of it. It implements heap.Interface.
+type expiryHeap[K comparable, V any] []*entry[K, V]
+
+func (h expiryHeap[K, V]) Len() int {
+    return len(h)
+}
+
+func (h expiryHeap[K, V]) Less(i, j int) bool {
+    return h[i].Expiration < h[j].Expiration
+}
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+
+func (h *expiryHeap[K, V]) Push(x interface{}) {
+    ent := x.(*entry[K, V])
+    ent.index = len(*h)
+    *h = append(*h, ent)
+}
+
+func (h *expiryHeap[K, V]) Pop() interface{} {
+    old := *h
+    n := len(old)
+    ent := old[n-1]
+    old[n-1] = nil
+    ent.index = -1
+    *h = old[:n-1]
+    return ent
+}
+
+// peek returns the entry expiring first.
+func (h expiryHeap[K, V]) peek() (*entry[K, V], bool) {
+    if len(h) == 0 {
+        return nil, false
+    }
+    return h[0], true
+}
+
+// track adds ent to the heap if it expires.
+func (h *expiryHeap[K, V]) track(ent *entry[K, V]) {
+    if ent.Expiration > 0 {
+        heap.Push(h, ent)
+    } else {
+        ent.index = -1
+    }
+}
+
+// untrack removes ent from the heap if it is part of it.
+func (h *expiryHeap[K, V]) untrack(ent *entry[K, V]) {
+    if ent.index >= 0 {
+        heap.Remove(h, ent.index)
+    }
+}
+
+// update changes the expiration of ent and restores the heap order.
+func (h *expiryHeap[K, V]) update(ent *entry[K, V], expiration int64) {
+    switch {
+    case ent.index >= 0 && expiration > 0:
+        ent.Expiration = expiration
+        heap.Fix(h, ent.index)
+    case ent.index >= 0:
+        heap.Remove(h, ent.index)
+        ent.Expiration = expiration
+    default:
+        ent.Expiration = expiration
+        h.track(ent)
+    }
+}
+
+// slide pushes the deadline of a sliding entry forward after an access.
+func (h *expiryHeap[K, V]) slide(ent *entry[K, V]) {
+    if ent.sliding <= 0 {
+        return
+    }
+    expiration := time.Now().Add(ent.sliding).UnixNano()
+    if ent.maxExpiration > 0 && expiration > ent.maxExpiration {
+        expiration = ent.maxExpiration
+    }
+    h.update(ent, expiration)
+}
@@ -0,0 +1,296 @@
+// Package exporter publishes the statistics of go_lru caches to
+// monitoring systems, in the Prometheus text exposition format over HTTP
+// or as an expvar variable, without external dependencies:
+//
+//     reg := exporter.NewRegistry()
+//     reg.Register("users", users)
+//     reg.Register("sessions", sessions)
+//     http.Handle("/metrics", reg)
+//     expvar.Publish("caches", reg)
+package exporter
+
+import (
+    "bufio"
+    "encoding/json"
+    "expvar"
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/wonktnodi/go_lru"
+)
+
+// Source is implemented by every cache of go_lru.
+type Source interface {
+    Stats() go_lru.Stats
+}
+
+// ContentType is the content type of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry holds named caches whose statistics are exported together,
+// each under its own cache label. It is an http.Handler serving the
+// Prometheus text format and an expvar.Var. It is safe for concurrent
+// use.
+type Registry struct {
+    lock    sync.RWMutex
+    sources map[string]Source
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+    return &Registry{sources: make(map[string]Source)}
+}
+
+// Register adds a cache under name, failing if the name is taken.
+func (r *Registry) Register(name string, s Source) error {
+    r.lock.Lock()
+    defer r.lock.Unlock()
+    if _, ok := r.sources[name]; ok {
+        return fmt.Errorf("cache %q is already registered", name)
+    }
+    r.sources[name] = s
+    return nil
+}
+
+// Unregister removes the cache registered under name, returning if there
+// was one.
+func (r *Registry) Unregister(name string) bool {
+    r.lock.Lock()
+    defer r.lock.Unlock()
+    _, ok := r.sources[name]
+    delete(r.sources, name)
+    return ok
+}
+
+// snapshot returns the statistics of every cache, by name.
+func (r *Registry) snapshot() map[string]go_lru.Stats {
+    r.lock.RLock()
+    defer r.lock.RUnlock()
+    stats := make(map[string]go_lru.Stats, len(r.sources))
+    for name, s := range r.sources {
+        stats[name] = s.Stats()
+    }
+    return stats
+}
+
+// ServeHTTP writes the statistics of every cache in the Prometheus text
+// format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+    w.Header().Set("Content-Type", ContentType)
+    r.WritePrometheus(w)
+}
+
+// WritePrometheus writes the statistics of every cache to w in the
+// Prometheus text format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+    return writePrometheus(w, r.snapshot())
+}
+
+// String returns the statistics of every cache as a JSON object keyed by
+// name, implementing expvar.Var.
+func (r *Registry) String() string {
+    return marshal(r.snapshot())
+}
+
+// Var returns an expvar.Var publishing the statistics of a single cache
+// as a JSON object.
+func Var(s Source) expvar.Var {
+    return expvar.Func(func() interface{} {
+        return s.Stats()
+    })
+}
+
+// Handler returns an http.Handler serving the statistics of a single
+// cache in the Prometheus text format, labeled with name.
+func Handler(name string, s Source) http.Handler {
+    r := NewRegistry()
+    r.Register(name, s)
+    return r
+}
+
+func marshal(v interface{}) string {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return strconv.Quote(err.Error())
+    }
+    return string(b)
+}
+
+// sample is one labeled value of a metric.
+type sample struct {
+    label string // label is an extra label, as name="value", or empty
+    value string
+}
+
+// metric describes a metric family and how to read it from Stats.
+type metric struct {
+    name    string
+    typ     string
+    help    string
+    samples func(s go_lru.Stats) []sample
+}
+
+func counter(v uint64) []sample {
+    return []sample{{value: strconv.FormatUint(v, 10)}}
+}
+
+func gauge(v int64) []sample {
+    return []sample{{value: strconv.FormatInt(v, 10)}}
+}
+
+var metrics = []metric{
+    {"go_lru_hits_total", "counter", "Lookups that found a live entry.",
+        func(s go_lru.Stats) []sample { return counter(s.Hits) }},
+    {"go_lru_misses_total", "counter", "Lookups that found no live entry.",
+        func(s go_lru.Stats) []sample { return counter(s.Misses) }},
+    {"go_lru_adds_total", "counter", "Keys added to the cache.",
+        func(s go_lru.Stats) []sample { return counter(s.Adds) }},
+    {"go_lru_updates_total", "counter", "Values replaced by an add or a refresh.",
+        func(s go_lru.Stats) []sample { return counter(s.Updates) }},
+    {"go_lru_ghost_hits_total", "counter", "Adds of keys remembered as recently evicted.",
+        func(s go_lru.Stats) []sample { return counter(s.GhostHits) }},
+    {"go_lru_evictions_total", "counter", "Entries that left the cache, by reason.",
+        func(s go_lru.Stats) []sample {
+            return []sample{
+                {`reason="` + go_lru.EvictCapacity.String() + `"`, strconv.FormatUint(s.Evictions, 10)},
+                {`reason="` + go_lru.EvictExpired.String() + `"`, strconv.FormatUint(s.Expirations, 10)},
+                {`reason="` + go_lru.EvictRemoved.String() + `"`, strconv.FormatUint(s.Removals, 10)},
+                {`reason="` + go_lru.EvictPurged.String() + `"`, strconv.FormatUint(s.Purges, 10)},
+            }
+        }},
+    {"go_lru_entries", "gauge", "Entries in the cache.",
+        func(s go_lru.Stats) []sample { return gauge(int64(s.Len)) }},
+    {"go_lru_cost", "gauge", "Total cost of the entries in the cache.",
+        func(s go_lru.Stats) []sample { return gauge(s.Cost) }},
+    {"go_lru_2q_entries", "gauge", "Entries in each list of a 2Q cache, ghosts included.",
+        func(s go_lru.Stats) []sample {
+            if s.TwoQueue == nil {
+                return nil
+            }
+            return []sample{
+                {`list="recent"`, strconv.Itoa(s.TwoQueue.Recent)},
+                {`list="frequent"`, strconv.Itoa(s.TwoQueue.Frequent)},
+                {`list="recent_evict"`, strconv.Itoa(s.TwoQueue.RecentEvict)},
+            }
+        }},
+    {"go_lru_arc_p", "gauge", "Target size of the T1 list of an ARC cache.",
+        func(s go_lru.Stats) []sample {
+            if s.ARC == nil {
+                return nil
+            }
+            return gauge(s.ARC.P)
+        }},
+    {"go_lru_arc_entries", "gauge", "Entries in each list of an ARC cache, ghosts included.",
+        func(s go_lru.Stats) []sample {
+            if s.ARC == nil {
+                return nil
+            }
+            return []sample{
+                {`list="t1"`, strconv.Itoa(s.ARC.T1)},
+                {`list="t2"`, strconv.Itoa(s.ARC.T2)},
+                {`list="b1"`, strconv.Itoa(s.ARC.B1)},
+                {`list="b2"`, strconv.Itoa(s.ARC.B2)},
+            }
+        }},
+    {"go_lru_car_p", "gauge", "Target size of the T1 clock of a CAR cache.",
+        func(s go_lru.Stats) []sample {
+            if s.CAR == nil {
+                return nil
+            }
+            return gauge(s.CAR.P)
+        }},
+    {"go_lru_car_entries", "gauge", "Entries in each list of a CAR cache, ghosts included.",
+        func(s go_lru.Stats) []sample {
+            if s.CAR == nil {
+                return nil
+            }
+            return []sample{
+                {`list="t1"`, strconv.Itoa(s.CAR.T1)},
+                {`list="t2"`, strconv.Itoa(s.CAR.T2)},
+                {`list="b1"`, strconv.Itoa(s.CAR.B1)},
+                {`list="b2"`, strconv.Itoa(s.CAR.B2)},
+            }
+        }},
+    {"go_lru_tinylfu_entries", "gauge", "Entries in each segment of a TinyLFU cache.",
+        func(s go_lru.Stats) []sample {
+            if s.TinyLFU == nil {
+                return nil
+            }
+            return []sample{
+                {`segment="window"`, strconv.Itoa(s.TinyLFU.Window)},
+                {`segment="probation"`, strconv.Itoa(s.TinyLFU.Probation)},
+                {`segment="protected"`, strconv.Itoa(s.TinyLFU.Protected)},
+            }
+        }},
+    {"go_lru_s3fifo_entries", "gauge", "Entries in each queue of an S3-FIFO cache, ghosts included.",
+        func(s go_lru.Stats) []sample {
+            if s.S3FIFO == nil {
+                return nil
+            }
+            return []sample{
+                {`queue="small"`, strconv.Itoa(s.S3FIFO.Small)},
+                {`queue="main"`, strconv.Itoa(s.S3FIFO.Main)},
+                {`queue="ghost"`, strconv.Itoa(s.S3FIFO.Ghost)},
+            }
+        }},
+    {"go_lru_lirs_entries", "gauge", "Entries in each set of a LIRS cache, ghosts included.",
+        func(s go_lru.Stats) []sample {
+            if s.LIRS == nil {
+                return nil
+            }
+            return []sample{
+                {`set="lir"`, strconv.Itoa(s.LIRS.LIR)},
+                {`set="hir"`, strconv.Itoa(s.LIRS.HIR)},
+                {`set="ghost"`, strconv.Itoa(s.LIRS.Ghost)},
+            }
+        }},
+    {"go_lru_lirs_stack", "gauge", "Keys in the stack of a LIRS cache, ghosts included.",
+        func(s go_lru.Stats) []sample {
+            if s.LIRS == nil {
+                return nil
+            }
+            return gauge(int64(s.LIRS.Stack))
+        }},
+}
+
+// writePrometheus writes stats in the Prometheus text format, a family
+// at a time with the caches sorted by name. Families without samples
+// are left out.
+func writePrometheus(w io.Writer, stats map[string]go_lru.Stats) error {
+    names := make([]string, 0, len(stats))
+    for name := range stats {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    bw := bufio.NewWriter(w)
+    for _, m := range metrics {
+        header := false
+        for _, name := range names {
+            for _, smp := range m.samples(stats[name]) {
+                if !header {
+                    fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+                    header = true
+                }
+                labels := `cache="` + escape(name) + `"`
+                if smp.label != "" {
+                    labels += "," + smp.label
+                }
+                fmt.Fprintf(bw, "%s{%s} %s\n", m.name, labels, smp.value)
+            }
+        }
+    }
+    return bw.Flush()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes a label value for the Prometheus text format.
+func escape(s string) string {
+    return escaper.Replace(s)
+}
@@ -0,0 +1,115 @@
+package exporter_test
+
+import (
+    "encoding/json"
+    "io"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/wonktnodi/go_lru"
+    "github.com/wonktnodi/go_lru/exporter"
+)
+
+func TestRegistry_Prometheus(t *testing.T) {
+    lru, _ := go_lru.NewOf[string, int](128, go_lru.NoExpiration)
+    twoQ, _ := go_lru.New2QOf[string, int](128, go_lru.NoExpiration)
+    arc, _ := go_lru.NewARCOf[string, int](128, go_lru.NoExpiration)
+    lru.Add("a", 1)
+    lru.Get("a")
+    lru.Get("b")
+    twoQ.Add("a", 1)
+    arc.Add("a", 1)
+    arc.Get("a")
+
+    reg := exporter.NewRegistry()
+    for name, s := range map[string]exporter.Source{"lru": lru, "two\"q": twoQ, "arc": arc} {
+        if err := reg.Register(name, s); err != nil {
+            t.Fatalf("err: %v", err)
+        }
+    }
+    if err := reg.Register("lru", lru); err == nil {
+        t.Fatalf("duplicate name should fail")
+    }
+
+    srv := httptest.NewServer(reg)
+    defer srv.Close()
+    resp, err := srv.Client().Get(srv.URL)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    defer resp.Body.Close()
+    if ct := resp.Header.Get("Content-Type"); ct != exporter.ContentType {
+        t.Fatalf("bad content type: %v", ct)
+    }
+    b, _ := io.ReadAll(resp.Body)
+    body := string(b)
+
+    for _, line := range []string{
+        "# TYPE go_lru_hits_total counter",
+        `go_lru_hits_total{cache="arc"} 1`,
+        `go_lru_hits_total{cache="lru"} 1`,
+        `go_lru_misses_total{cache="lru"} 1`,
+        `go_lru_adds_total{cache="two\"q"} 1`,
+        `go_lru_evictions_total{cache="lru",reason="capacity"} 0`,
+        `go_lru_entries{cache="two\"q"} 1`,
+        `go_lru_2q_entries{cache="two\"q",list="recent"} 1`,
+        `go_lru_arc_p{cache="arc"} 0`,
+        `go_lru_arc_entries{cache="arc",list="t2"} 1`,
+    } {
+        if !strings.Contains(body, line+"\n") {
+            t.Fatalf("missing %q in:\n%s", line, body)
+        }
+    }
+    if strings.Count(body, "# TYPE go_lru_hits_total") != 1 {
+        t.Fatalf("families should be written once:\n%s", body)
+    }
+    if strings.Contains(body, `go_lru_2q_entries{cache="lru"`) {
+        t.Fatalf("policy internals of other caches should be left out:\n%s", body)
+    }
+
+    // Unregistered caches are no longer exported
+    if !reg.Unregister("arc") || reg.Unregister("arc") {
+        t.Fatalf("bad unregister")
+    }
+    rec := httptest.NewRecorder()
+    reg.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+    if strings.Contains(rec.Body.String(), `cache="arc"`) || strings.Contains(rec.Body.String(), "go_lru_arc_p") {
+        t.Fatalf("arc should not be exported:\n%s", rec.Body.String())
+    }
+}
+
+func TestRegistry_Expvar(t *testing.T) {
+    l, _ := go_lru.NewOf[string, int](128, go_lru.NoExpiration)
+    l.Add("a", 1)
+    l.Get("a")
+
+    reg := exporter.NewRegistry()
+    reg.Register("lru", l)
+    var all map[string]go_lru.Stats
+    if err := json.Unmarshal([]byte(reg.String()), &all); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if all["lru"].Hits != 1 || all["lru"].Len != 1 {
+        t.Fatalf("bad stats: %+v", all)
+    }
+
+    var one go_lru.Stats
+    if err := json.Unmarshal([]byte(exporter.Var(l).String()), &one); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if one.Adds != 1 {
+        t.Fatalf("bad stats: %+v", one)
+    }
+}
+
+func TestHandler(t *testing.T) {
+    l, _ := go_lru.NewOf[string, int](128, go_lru.NoExpiration)
+    l.Add("a", 1)
+
+    rec := httptest.NewRecorder()
+    exporter.Handler("users", l).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+    if !strings.Contains(rec.Body.String(), `go_lru_entries{cache="users"} 1`+"\n") {
+        t.Fatalf("bad body:\n%s", rec.Body.String())
+    }
+}
@@ -0,0 +1,47 @@
+package go_lru
+
+import (
+    "time"
+)
+
+// janitor periodically calls a sweep function from its own goroutine
+// until it is stopped.
+type janitor struct {
+    stop chan struct{}
+    done chan struct{}
+}
+
+// startJanitor starts a goroutine calling sweep every interval.
+func startJanitor(interval time.Duration, sweep func()) *janitor {
+    j := &janitor{
+        stop: make(chan struct{}),
+        done: make(chan struct{}),
+    }
+    go j.run(interval, sweep)
+    return j
+}
+
+func (j *janitor) run(interval time.Duration, sweep func()) {
+    defer close(j.done)
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            sweep()
+        case <-j.stop:
+            return
+        }
+    }
+}
+
+// Stop stops the goroutine and waits for a running sweep to finish.
+// It is safe to call on a nil janitor. The caller must not hold a lock
+// the sweep function takes.
+func (j *janitor) Stop() {
+    if j == nil {
+        return
+    }
+    close(j.stop)
+    <-j.done
+}
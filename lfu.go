@@ -0,0 +1,582 @@
+package go_lru
+
+import (
+    "container/list"
+    "context"
+    "errors"
+    "sync"
+    "time"
+)
+
+// LFUCache is a thread-safe fixed size LFU cache. It evicts the entry
+// accessed the fewest times, the least recently used one among those
+// tied. Entries are kept in buckets of equal frequency so that every
+// operation runs in constant time. With SetDecay, the frequencies are
+// periodically halved so that keys gone quiet lose their popularity.
+// The size of the cache bounds the total cost of its entries, one each
+// unless added with AddWithCost.
+type LFUCache[K comparable, V any] struct {
+    size              int64
+    cost              int64      // cost is the total cost of the entries
+    buckets           *list.List // buckets holds the *lfuBucket, by increasing frequency
+    items             map[K]*list.Element
+    expiry            expiryHeap[K, V] // expiry indexes the expiring entries
+    onEvict           EvictReasonCallback[K, V]
+    defaultExpiration time.Duration
+
+    decay     time.Duration // decay is the period of the aging, 0 if none
+    nextDecay int64         // nextDecay is when the frequencies are next halved
+
+    lock      sync.Mutex
+    janitor   *janitor
+    loads     loadGroup[K, V]
+    refresher refresher[K, V]
+    stats     *counters
+}
+
+// lfuBucket holds the entries accessed freq times, from oldest to
+// newest.
+type lfuBucket[K comparable, V any] struct {
+    freq    uint64
+    entries *list.List
+}
+
+// lfuEntry is an entry of an LFUCache, held in the list of its bucket.
+type lfuEntry[K comparable, V any] struct {
+    *entry[K, V]
+    bucket *list.Element // bucket is the element of its bucket in buckets
+}
+
+// NewLFU creates an LFU cache of the given size with string keys and
+// untyped values.
+func NewLFU(size int, defaultExpiration time.Duration) (*LFUCache[string, interface{}], error) {
+    return NewLFUOf[string, interface{}](size, defaultExpiration)
+}
+
+// NewLFUWithEvict creates an LFU cache of the given size with string
+// keys, untyped values and the given eviction callback.
+func NewLFUWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*LFUCache[string, interface{}], error) {
+    return NewLFUWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewLFUWithEvictReason creates an LFU cache of the given size with
+// string keys, untyped values and an eviction callback that also learns
+// why an entry left the cache.
+func NewLFUWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*LFUCache[string, interface{}], error) {
+    return NewLFUWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewLFUOf creates an LFU cache of the given size for any comparable key
+// type and value type.
+func NewLFUOf[K comparable, V any](size int, defaultExpiration time.Duration) (*LFUCache[K, V], error) {
+    return newLFU[K, V](size, nil, defaultExpiration)
+}
+
+// NewLFUWithEvictOf creates an LFU cache of the given size with the
+// given eviction callback.
+func NewLFUWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*LFUCache[K, V], error) {
+    return newLFU[K, V](size, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration)
+}
+
+// NewLFUWithEvictReasonOf creates an LFU cache of the given size whose
+// eviction callback also learns why an entry left the cache, including
+// the old value of an updated entry.
+func NewLFUWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*LFUCache[K, V], error) {
+    return newLFU[K, V](size, onEvicted, defaultExpiration)
+}
+
+// newLFU creates an LFU cache holding at most size entries.
+func newLFU[K comparable, V any](size int, onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) (*LFUCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return &LFUCache[K, V]{
+        size:              int64(size),
+        buckets:           list.New(),
+        items:             make(map[K]*list.Element),
+        onEvict:           onEvict,
+        defaultExpiration: defaultExpiration,
+        stats:             new(counters),
+    }, nil
+}
+
+// SetDecay makes the cache halve the access frequency of every entry
+// each interval, so that old popularity fades, or never if interval is
+// not positive. The aging is applied lazily, by the next Get or add
+// after it is due.
+func (c *LFUCache[K, V]) SetDecay(interval time.Duration) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.decay = interval
+    c.nextDecay = 0
+    if interval > 0 {
+        c.nextDecay = time.Now().Add(interval).UnixNano()
+    }
+}
+
+// Get looks up a key's value from the cache, counting an access.
+func (c *LFUCache[K, V]) Get(key K) (V, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.age()
+
+    if el, ok := c.items[key]; ok {
+        ent := el.Value.(*lfuEntry[K, V])
+        if !ent.Expired() {
+            c.increment(el)
+            c.expiry.slide(ent.entry)
+            return c.hit(ent.entry)
+        }
+        c.removeElement(el, EvictExpired)
+    }
+
+    // No hit
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// hit returns the value of a cache hit, starting a background refresh
+// if it is past its soft TTL.
+func (c *LFUCache[K, V]) hit(ent *entry[K, V]) (V, bool) {
+    c.stats.hit()
+    if loader, ok := c.refresher.due(ent, time.Now().UnixNano()); ok {
+        go c.refresh(ent, loader)
+    }
+    return ent.value, true
+}
+
+// refresh reloads ent, dropping the result if the entry was updated or
+// removed in the meantime.
+func (c *LFUCache[K, V]) refresh(ent *entry[K, V], loader LoaderFunc[K, V]) {
+    value, d, err := loader(context.Background(), ent.key)
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    el, ok := c.items[ent.key]
+    if !ok || el.Value.(*lfuEntry[K, V]).entry != ent || !ent.refreshing {
+        return
+    }
+    if err != nil {
+        ent.refreshing = false
+        return
+    }
+    c.notify(ent, EvictReplaced)
+    ent.value = value
+    ent.refreshing = false
+    ent.refreshAt = time.Now().Add(ent.refresh).UnixNano()
+    c.expiry.update(ent, c.expireAt(d))
+}
+
+// GetOrLoad looks up a key's value from the cache, or loads it with
+// loader and adds it with the duration the loader returns. Concurrent
+// calls for the same key share a single load; callers waiting on it
+// give up when their ctx is done, but the load carries on for the
+// others. A panic in the loader is raised again in each of them. The
+// cache is not locked while the loader runs.
+func (c *LFUCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+    return c.loads.load(ctx, c, key, loader)
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+func (c *LFUCache[K, V]) Add(key K, value V) bool {
+    return c.AddWithExpire(key, value, NoExpiration)
+}
+
+// AddWithExpire adds a value to the cache that expires after d. Updating
+// a key counts as an access. Returns true if an eviction occurred.
+func (c *LFUCache[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, 1, ttl{expiration: c.expireAt(d)})
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *LFUCache[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, cost, ttl{expiration: c.expireAt(d)})
+}
+
+// AddWithSliding adds a value that expires after d, each successful Get
+// pushing its deadline d further. A positive max caps its lifetime from
+// now regardless of accesses. Returns true if an eviction occurred.
+func (c *LFUCache[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    if d == DefaultExpiration {
+        d = c.defaultExpiration
+    }
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, 1, slidingTTL(d, max))
+}
+
+// AddWithRefresh adds a value that expires after d. Once the soft TTL
+// refresh has passed, Get keeps returning it but also reloads it in the
+// background with the loader registered by SetRefreshLoader.
+// Returns true if an eviction occurred.
+func (c *LFUCache[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, 1, ttl{expiration: c.expireAt(d), refresh: refresh})
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *LFUCache[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, 1, deadlineTTL(deadline))
+}
+
+// SetRefreshLoader registers the loader used to refresh entries added
+// with AddWithRefresh. The duration it returns restarts the hard TTL.
+func (c *LFUCache[K, V]) SetRefreshLoader(loader LoaderFunc[K, V]) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.refresher.loader = loader
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating its
+// frequency or deleting it for being stale, and if not, adds the value.
+// Returns whether found and whether an eviction occurred.
+func (c *LFUCache[K, V]) ContainsOrAdd(key K, value V) (ok, evict bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if c.contains(key) {
+        return true, false
+    }
+    return false, c.add(key, value, 1, ttl{expiration: c.expireAt(NoExpiration)})
+}
+
+// add does the work of AddWithCost, the caller must hold the lock.
+func (c *LFUCache[K, V]) add(key K, value V, cost int64, t ttl) bool {
+    c.age()
+
+    // Check for existing item
+    if el, ok := c.items[key]; ok {
+        ent := el.Value.(*lfuEntry[K, V])
+        c.notify(ent.entry, EvictReplaced)
+        ent.value = value
+        ent.refreshing = false
+        ent.setTTL(t)
+        c.expiry.update(ent.entry, t.expiration)
+        c.cost += cost - ent.cost
+        ent.cost = cost
+        c.increment(el)
+        return c.makeRoom(0)
+    }
+
+    // Make room before adding, so the new entry is not the victim
+    evict := c.makeRoom(cost)
+
+    // New entries start in the bucket of frequency 1
+    c.stats.add()
+    front := c.buckets.Front()
+    if front == nil || front.Value.(*lfuBucket[K, V]).freq != 1 {
+        front = c.buckets.PushFront(&lfuBucket[K, V]{freq: 1, entries: list.New()})
+    }
+    ent := &lfuEntry[K, V]{entry: newEntry(key, value, t, cost), bucket: front}
+    c.items[key] = front.Value.(*lfuBucket[K, V]).entries.PushBack(ent)
+    c.expiry.track(ent.entry)
+    c.cost += cost
+    return evict
+}
+
+// makeRoom evicts entries until one of the given cost fits. Returns true
+// if an entry was evicted.
+func (c *LFUCache[K, V]) makeRoom(cost int64) bool {
+    evicted := false
+    for len(c.items) > 0 && c.cost+cost > c.size {
+        c.removeElement(c.victim(), EvictCapacity)
+        evicted = true
+    }
+    return evicted
+}
+
+// increment moves the entry of el to the bucket of the next frequency,
+// as its newest entry.
+func (c *LFUCache[K, V]) increment(el *list.Element) {
+    ent := el.Value.(*lfuEntry[K, V])
+    cur := ent.bucket
+    freq := cur.Value.(*lfuBucket[K, V]).freq + 1
+
+    next := cur.Next()
+    if next == nil || next.Value.(*lfuBucket[K, V]).freq != freq {
+        next = c.buckets.InsertAfter(&lfuBucket[K, V]{freq: freq, entries: list.New()}, cur)
+    }
+    c.unlink(el)
+    ent.bucket = next
+    c.items[ent.key] = next.Value.(*lfuBucket[K, V]).entries.PushBack(ent)
+}
+
+// unlink removes el from its bucket, dropping the bucket once empty.
+func (c *LFUCache[K, V]) unlink(el *list.Element) {
+    b := el.Value.(*lfuEntry[K, V]).bucket
+    entries := b.Value.(*lfuBucket[K, V]).entries
+    entries.Remove(el)
+    if entries.Len() == 0 {
+        c.buckets.Remove(b)
+    }
+}
+
+// victim returns the element of the next entry to evict: the oldest of
+// the least frequently used.
+func (c *LFUCache[K, V]) victim() *list.Element {
+    return c.buckets.Front().Value.(*lfuBucket[K, V]).entries.Front()
+}
+
+// age halves the frequencies if the decay is due.
+func (c *LFUCache[K, V]) age() {
+    if c.decay <= 0 {
+        return
+    }
+    now := time.Now()
+    if now.UnixNano() < c.nextDecay {
+        return
+    }
+    c.nextDecay = now.Add(c.decay).UnixNano()
+    c.halve()
+}
+
+// halve halves the frequency of every entry, keeping it at least 1, and
+// merges the buckets that end up with the same frequency. Entries keep
+// their order, those of the more frequent bucket counting as newer.
+func (c *LFUCache[K, V]) halve() {
+    var prev *list.Element
+    for cur := c.buckets.Front(); cur != nil; {
+        next := cur.Next()
+        b := cur.Value.(*lfuBucket[K, V])
+        b.freq /= 2
+        if b.freq == 0 {
+            b.freq = 1
+        }
+
+        if prev != nil && prev.Value.(*lfuBucket[K, V]).freq == b.freq {
+            into := prev.Value.(*lfuBucket[K, V]).entries
+            for el := b.entries.Front(); el != nil; el = el.Next() {
+                ent := el.Value.(*lfuEntry[K, V])
+                ent.bucket = prev
+                c.items[ent.key] = into.PushBack(ent)
+            }
+            c.buckets.Remove(cur)
+        } else {
+            prev = cur
+        }
+        cur = next
+    }
+}
+
+// expireAt converts a duration into the expiration timestamp of an entry
+// added now, 0 meaning it never expires.
+func (c *LFUCache[K, V]) expireAt(d time.Duration) int64 {
+    if d == DefaultExpiration {
+        d = c.defaultExpiration
+    }
+    if d > 0 {
+        return time.Now().Add(d).UnixNano()
+    }
+    return 0
+}
+
+// RemoveExpired removes every expired entry from the cache in the order
+// they expired, returning how many were removed.
+func (c *LFUCache[K, V]) RemoveExpired() int {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    now := time.Now().UnixNano()
+    removed := 0
+    for {
+        kv, ok := c.expiry.peek()
+        if !ok || kv.Expiration >= now {
+            return removed
+        }
+        c.removeElement(c.items[kv.key], EvictExpired)
+        removed++
+    }
+}
+
+// StartJanitor starts a background goroutine that removes expired
+// entries every interval, replacing any janitor started before. Call
+// Close to stop it. A non-positive interval is ignored.
+func (c *LFUCache[K, V]) StartJanitor(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    j := startJanitor(interval, func() { c.RemoveExpired() })
+    c.lock.Lock()
+    old := c.janitor
+    c.janitor = j
+    c.lock.Unlock()
+    old.Stop()
+}
+
+// Close stops the janitor, if any. The cache remains usable.
+func (c *LFUCache[K, V]) Close() {
+    c.lock.Lock()
+    j := c.janitor
+    c.janitor = nil
+    c.lock.Unlock()
+    j.Stop()
+}
+
+// Len returns the number of cached entries.
+func (c *LFUCache[K, V]) Len() int {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return len(c.items)
+}
+
+// Cost returns the total cost of the cached entries.
+func (c *LFUCache[K, V]) Cost() int64 {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.cost
+}
+
+// Keys returns all the cached keys, from the first to be evicted to the
+// last: by increasing frequency, then from oldest to newest.
+func (c *LFUCache[K, V]) Keys() []K {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    keys := make([]K, 0, len(c.items))
+    for b := c.buckets.Front(); b != nil; b = b.Next() {
+        for el := b.Value.(*lfuBucket[K, V]).entries.Front(); el != nil; el = el.Next() {
+            keys = append(keys, el.Value.(*lfuEntry[K, V]).key)
+        }
+    }
+    return keys
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *LFUCache[K, V]) Remove(key K) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if el, ok := c.items[key]; ok {
+        c.removeElement(el, EvictRemoved)
+        return true
+    }
+    return false
+}
+
+// Purge is used to completely clear the cache.
+func (c *LFUCache[K, V]) Purge() {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for k, el := range c.items {
+        c.notify(el.Value.(*lfuEntry[K, V]).entry, EvictPurged)
+        delete(c.items, k)
+    }
+    c.buckets.Init()
+    c.expiry = nil
+    c.cost = 0
+}
+
+// Contains is used to check if the cache contains a key without
+// updating its frequency.
+func (c *LFUCache[K, V]) Contains(key K) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.contains(key)
+}
+
+func (c *LFUCache[K, V]) contains(key K) bool {
+    el, ok := c.items[key]
+    return ok && !el.Value.(*lfuEntry[K, V]).Expired()
+}
+
+// Peek is used to inspect the cache value of a key without updating its
+// frequency.
+func (c *LFUCache[K, V]) Peek(key K) (V, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if el, ok := c.items[key]; ok && !el.Value.(*lfuEntry[K, V]).Expired() {
+        return el.Value.(*lfuEntry[K, V]).value, true
+    }
+    var empty V
+    return empty, false
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *LFUCache[K, V]) TTL(key K) (time.Duration, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    el, ok := c.items[key]
+    if !ok || el.Value.(*lfuEntry[K, V]).Expired() {
+        return 0, false
+    }
+    expiration := el.Value.(*lfuEntry[K, V]).Expiration
+    if expiration == 0 {
+        return NoExpiration, true
+    }
+    return time.Duration(expiration - time.Now().UnixNano()), true
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration,
+// without updating its value or frequency. A sliding expiration is
+// replaced by the fixed deadline. Returns false if the key is missing or
+// stale.
+func (c *LFUCache[K, V]) Touch(key K, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.setExpiration(key, c.expireAt(d))
+}
+
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time, without updating its value or frequency. A sliding
+// expiration is replaced by the fixed deadline. Returns false if the key
+// is missing or stale.
+func (c *LFUCache[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.setExpiration(key, deadlineTTL(deadline).expiration)
+}
+
+func (c *LFUCache[K, V]) setExpiration(key K, expiration int64) bool {
+    el, ok := c.items[key]
+    if !ok || el.Value.(*lfuEntry[K, V]).Expired() {
+        return false
+    }
+    ent := el.Value.(*lfuEntry[K, V])
+    ent.sliding = 0
+    ent.maxExpiration = 0
+    c.expiry.update(ent.entry, expiration)
+    return true
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (c *LFUCache[K, V]) Stats() Stats {
+    s := c.stats.snapshot()
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    s.Len = len(c.items)
+    s.Cost = c.cost
+    return s
+}
+
+// ResetStats zeroes the counters of the cache, to report statistics per
+// time window.
+func (c *LFUCache[K, V]) ResetStats() {
+    c.stats.reset()
+}
+
+// removeElement removes el from the cache for the given reason.
+func (c *LFUCache[K, V]) removeElement(el *list.Element, reason EvictReason) {
+    ent := el.Value.(*lfuEntry[K, V])
+    c.unlink(el)
+    delete(c.items, ent.key)
+    c.expiry.untrack(ent.entry)
+    c.cost -= ent.cost
+    c.notify(ent.entry, reason)
+}
+
+// notify counts kv leaving for the given reason and fires the eviction
+// callback, if there is one.
+func (c *LFUCache[K, V]) notify(kv *entry[K, V], reason EvictReason) {
+    c.stats.evict(reason)
+    if c.onEvict != nil {
+        c.onEvict(kv.key, kv.value, reason)
+    }
+}
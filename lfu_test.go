@@ -0,0 +1,276 @@
+package go_lru
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestLFU(t *testing.T) {
+    evictCounter := 0
+    onEvicted := func(k string, v interface{}) {
+        evictCounter++
+    }
+    l, err := NewLFUWithEvict(128, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 256; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if evictCounter != 128 {
+        t.Fatalf("bad evict count: %v", evictCounter)
+    }
+
+    // Among equal frequencies, the oldest entries are evicted first
+    for i, k := range l.Keys() {
+        if v, ok := l.Get(k); !ok || v != i+128 {
+            t.Fatalf("bad key: %v", k)
+        }
+    }
+    for i := 0; i < 128; i++ {
+        if _, ok := l.Get(fmt.Sprint(i)); ok {
+            t.Fatalf("should be evicted")
+        }
+    }
+
+    l.Add("128", 1000)
+    if v, ok := l.Peek("128"); !ok || v != 1000 {
+        t.Fatalf("update not visible: %v, %v", v, ok)
+    }
+    if !l.Remove("128") || l.Contains("128") {
+        t.Fatalf("should be removed")
+    }
+    l.Purge()
+    if l.Len() != 0 || len(l.Keys()) != 0 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+}
+
+// Test that the least frequently used entry is evicted, whatever its
+// recency
+func TestLFU_Frequency(t *testing.T) {
+    l, err := NewLFUOf[string, int](3, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.Add("a", 1)
+    l.Add("b", 2)
+    l.Add("c", 3)
+    for i := 0; i < 3; i++ {
+        l.Get("a")
+    }
+    l.Get("b")
+    l.Get("c")
+    l.Get("c")
+
+    // b is the least frequently used even though a is older
+    if !l.Add("d", 4) || l.Contains("b") {
+        t.Fatalf("b should have been evicted: %v", l.Keys())
+    }
+    if keys := fmt.Sprint(l.Keys()); keys != "[d c a]" {
+        t.Fatalf("bad keys: %v", keys)
+    }
+
+    // Updates count as accesses
+    l.Add("d", 5)
+    l.Add("d", 6)
+    if keys := fmt.Sprint(l.Keys()); keys != "[c d a]" {
+        t.Fatalf("bad keys: %v", keys)
+    }
+
+    // Peek and Contains do not
+    l.Peek("c")
+    l.Contains("c")
+    l.Add("e", 7)
+    if l.Contains("c") {
+        t.Fatalf("c should have been evicted: %v", l.Keys())
+    }
+}
+
+func TestLFU_Decay(t *testing.T) {
+    l, err := NewLFUOf[string, int](3, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.Add("a", 1)
+    for i := 0; i < 8; i++ {
+        l.Get("a")
+    }
+    l.Add("b", 2)
+    for i := 0; i < 3; i++ {
+        l.Get("b")
+    }
+    l.Add("c", 3)
+    l.Get("c")
+
+    // 9, 4 and 2 become 4, 2 and 1, b and c swapping buckets
+    l.halve()
+    freqs := ""
+    for b := l.buckets.Front(); b != nil; b = b.Next() {
+        freqs += fmt.Sprint(b.Value.(*lfuBucket[string, int]).freq, " ")
+    }
+    if freqs != "1 2 4 " {
+        t.Fatalf("bad frequencies: %v", freqs)
+    }
+
+    // Buckets ending up with the same frequency are merged, the more
+    // frequent entries counting as newer
+    l.halve()
+    l.halve()
+    if l.buckets.Len() != 1 {
+        t.Fatalf("bad buckets: %v", l.buckets.Len())
+    }
+    if keys := fmt.Sprint(l.Keys()); keys != "[c b a]" {
+        t.Fatalf("bad keys: %v", keys)
+    }
+
+    // A key gone quiet loses to keys accessed less often, but lately
+    l.SetDecay(10 * time.Millisecond)
+    for i := 0; i < 8; i++ {
+        l.Get("a")
+    }
+    time.Sleep(20 * time.Millisecond)
+    for i := 0; i < 5; i++ {
+        l.Get("b")
+        l.Get("c")
+    }
+    l.Add("d", 4)
+    if l.Contains("a") || !l.Contains("b") || !l.Contains("c") {
+        t.Fatalf("a should have been evicted: %v", l.Keys())
+    }
+}
+
+func TestLFU_Expiration(t *testing.T) {
+    reasons := make(map[string]EvictReason)
+    onEvicted := func(k string, v int, reason EvictReason) {
+        reasons[k] = reason
+    }
+    l, err := NewLFUWithEvictReasonOf[string, int](4, 10*time.Millisecond, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithExpire("a", 1, DefaultExpiration)
+    l.AddWithExpire("b", 2, DefaultExpiration)
+    l.AddWithExpire("c", 3, NoExpiration)
+    l.AddWithExpire("d", 4, time.Hour)
+    time.Sleep(20 * time.Millisecond)
+
+    if l.Contains("a") {
+        t.Fatalf("a should have expired")
+    }
+    if _, ok := l.Peek("a"); ok {
+        t.Fatalf("a should have expired")
+    }
+    if _, ok := l.Get("a"); ok {
+        t.Fatalf("a should have expired")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if l.Len() != 2 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if reasons["a"] != EvictExpired || reasons["b"] != EvictExpired {
+        t.Fatalf("bad reasons: %v", reasons)
+    }
+
+    // Updating an entry replaces its expiration
+    l.AddWithExpire("c", 5, 10*time.Millisecond)
+    time.Sleep(20 * time.Millisecond)
+    if l.Contains("c") || reasons["c"] != EvictReplaced {
+        t.Fatalf("c should have expired: %v", reasons)
+    }
+}
+
+func TestLFU_Cost(t *testing.T) {
+    l, err := NewLFUOf[string, int](10, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithCost("a", 1, 4, NoExpiration)
+    l.AddWithCost("b", 2, 4, NoExpiration)
+    l.Get("a")
+    l.Add("c", 3)
+    if l.Cost() != 9 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // b is the oldest of the least frequent, evicting it makes room
+    if !l.AddWithCost("d", 4, 3, NoExpiration) {
+        t.Fatalf("should have an eviction")
+    }
+    if l.Contains("b") || !l.Contains("a") || !l.Contains("c") {
+        t.Fatalf("b should have been evicted: %v", l.Keys())
+    }
+    if l.Cost() != 8 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // A costly entry evicts as many entries as it needs
+    l.AddWithCost("e", 5, 6, NoExpiration)
+    if l.Contains("c") || l.Contains("d") || !l.Contains("a") || l.Cost() != 10 {
+        t.Fatalf("c and d should have been evicted: %v", l.Keys())
+    }
+
+    // Updates change the cost
+    l.AddWithCost("a", 1, 2, NoExpiration)
+    if l.Cost() != 8 || l.Stats().Cost != 8 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+    l.Purge()
+    if l.Cost() != 0 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+}
+
+func TestLFU_TTL(t *testing.T) {
+    l, err := NewLFUOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithExpire("a", 1, 10*time.Millisecond)
+    l.Add("b", 2)
+    if !l.Touch("a", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("a"); !ok || d <= 59*time.Second {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    l.AddWithDeadline("c", 3, time.Now().Add(time.Hour))
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("b"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if d, ok := l.TTL("c"); !ok || d <= 59*time.Minute {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Updates honor the new duration
+    l.Add("a", 10)
+    if d, ok := l.TTL("a"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Sliding entries are pushed forward by every hit
+    l.AddWithSliding("d", 4, 50*time.Millisecond, NoExpiration)
+    for i := 0; i < 3; i++ {
+        time.Sleep(30 * time.Millisecond)
+        if _, ok := l.Get("d"); !ok {
+            t.Fatalf("sliding entry should still be cached")
+        }
+    }
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Peek("d"); ok {
+        t.Fatalf("sliding entry should have expired")
+    }
+}
@@ -0,0 +1,692 @@
+package go_lru
+
+import (
+    "container/list"
+    "context"
+    "errors"
+    "sync"
+    "time"
+)
+
+const (
+    // DefaultLIRSHIRRatio is the ratio of the LIRS cache dedicated to the
+    // resident HIR entries, the rest holding the LIR set.
+    DefaultLIRSHIRRatio = 0.01
+)
+
+// LIRSCache is a thread-safe fixed size LIRS cache (Low Inter-reference
+// Recency Set). Rather than how recently a key was used, LIRS looks at
+// its reuse distance: the number of other keys seen between its last two
+// accesses. Keys with a short reuse distance form the LIR set, which
+// takes most of the cache and is never evicted directly; the others are
+// HIR entries, only a few of which stay resident in a small queue. The
+// stack S orders the keys by recency down to the oldest LIR entry, and
+// remembers the HIR keys evicted recently as non-resident ghosts: an HIR
+// key accessed again while still in S has a shorter reuse distance than
+// the oldest LIR entry and takes its place. Scans and loops slightly
+// larger than the cache, which flush an LRU cache entirely, thus leave
+// the LIR set in place.
+type LIRSCache[K comparable, V any] struct {
+    size      int64 // size is the total capacity of the cache, in entries or cost
+    lirSize   int64 // lirSize is the capacity of the LIR set
+    lirCost   int64 // lirCost is the total cost of the LIR entries
+    ghostCost int64 // ghostCost is the total cost the non-resident entries stood for
+
+    resident *BASELRU[K, V]     // resident holds the LIR and resident HIR entries, in no order
+    nodes    map[K]*lirsNode[K] // nodes holds the state of every key, ghosts included
+    stack    *list.List         // stack is S, the most recent key at the front
+    queue    *list.List         // queue is Q, the resident HIR keys, newest at the front
+    ghosts   *list.List         // ghosts holds the non-resident keys of S, newest at the front
+
+    lock      sync.RWMutex
+    janitor   *janitor
+    loads     loadGroup[K, V]
+    refresher refresher[K, V]
+    stats     *counters
+    reads     readBuffer[K, V]
+}
+
+// lirsStatus is the status of a key in a LIRSCache.
+type lirsStatus int
+
+const (
+    lirsLIR   lirsStatus = iota // lirsLIR keys have a short reuse distance
+    lirsHIR                     // lirsHIR keys are resident in Q
+    lirsGhost                   // lirsGhost keys are remembered in S alone
+)
+
+// lirsNode tracks a key in the lists of a LIRSCache.
+type lirsNode[K comparable] struct {
+    key    K
+    status lirsStatus
+    cost   int64         // cost is the cost of the entry, kept for ghosts
+    s      *list.Element // s is the element in S, nil once pruned
+    q      *list.Element // q is the element in Q, or in ghosts for a ghost
+}
+
+// NewLIRS creates a LIRS cache of the given size with string keys and
+// untyped values.
+func NewLIRS(size int, defaultExpiration time.Duration) (*LIRSCache[string, interface{}], error) {
+    return NewLIRSOf[string, interface{}](size, defaultExpiration)
+}
+
+// NewLIRSWithCost creates a LIRS cache with string keys and untyped
+// values that keeps the total cost of its entries within maxCost.
+func NewLIRSWithCost(maxCost int64, defaultExpiration time.Duration, costFunc func(key string, value interface{}) int64) (*LIRSCache[string, interface{}], error) {
+    return NewLIRSWithCostOf[string, interface{}](maxCost, defaultExpiration, costFunc)
+}
+
+// NewLIRSWithEvict creates a LIRS cache of the given size with string
+// keys, untyped values and the given eviction callback.
+func NewLIRSWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*LIRSCache[string, interface{}], error) {
+    return NewLIRSWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewLIRSWithEvictReason creates a LIRS cache of the given size with
+// string keys and untyped values whose eviction callback also learns why
+// an entry left the cache.
+func NewLIRSWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*LIRSCache[string, interface{}], error) {
+    return NewLIRSWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewLIRSOf creates a LIRS cache of the given size for any comparable key
+// type and value type.
+func NewLIRSOf[K comparable, V any](size int, defaultExpiration time.Duration) (*LIRSCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return newLIRS[K, V](size, 0, nil, nil, defaultExpiration), nil
+}
+
+// NewLIRSWithEvictOf creates a LIRS cache of the given size with the
+// given eviction callback. It is called once for every resident entry
+// dropped, never for moves between the LIR and HIR sets or for ghosts.
+func NewLIRSWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*LIRSCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return newLIRS[K, V](size, 0, nil, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration), nil
+}
+
+// NewLIRSWithEvictReasonOf creates a LIRS cache of the given size whose
+// eviction callback also learns why an entry left the cache. Moves
+// between the LIR and HIR sets and ghosts never trigger the callback.
+func NewLIRSWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*LIRSCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return newLIRS[K, V](size, 0, nil, onEvicted, defaultExpiration), nil
+}
+
+// NewLIRSWithCostOf creates a LIRS cache that keeps the total cost of
+// its entries within maxCost, the LIR set then getting a cost budget.
+// Entries added without an explicit cost are weighed by costFunc, or
+// cost 1 if it is nil.
+func NewLIRSWithCostOf[K comparable, V any](maxCost int64, defaultExpiration time.Duration, costFunc func(key K, value V) int64) (*LIRSCache[K, V], error) {
+    if maxCost <= 0 {
+        return nil, errors.New("Must provide a positive max cost")
+    }
+    return newLIRS[K, V](0, maxCost, costFunc, nil, defaultExpiration), nil
+}
+
+// newLIRS creates a LIRS cache bounded either by size entries or by
+// maxCost total cost.
+func newLIRS[K comparable, V any](size int, maxCost int64, costFunc CostFunc[K, V], onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) *LIRSCache[K, V] {
+    capacity := int64(size)
+    if maxCost > 0 {
+        capacity = maxCost
+    }
+
+    // Determine the sub-sizes, at least one entry is left for the
+    // resident HIR entries
+    hirSize := int64(float64(capacity) * DefaultLIRSHIRRatio)
+    if hirSize < 1 {
+        hirSize = 1
+    }
+
+    // The cache enforces the capacity, the resident entries are unbounded
+    c := &LIRSCache[K, V]{
+        size:     capacity,
+        lirSize:  capacity - hirSize,
+        resident: newBaseLRU[K, V](0, 0, costFunc, onEvict, defaultExpiration),
+        nodes:    make(map[K]*lirsNode[K]),
+        stack:    list.New(),
+        queue:    list.New(),
+        ghosts:   list.New(),
+        stats:    new(counters),
+    }
+    c.resident.stats = c.stats
+    return c
+}
+
+// Get looks up a key's value from the cache. Hits are served under the
+// shared lock, their effect on the lists is deferred until the next call
+// that takes the exclusive lock.
+func (c *LIRSCache[K, V]) Get(key K) (V, bool) {
+    c.lock.RLock()
+    ent, ok := c.resident.peekEntry(key)
+    if ok && ent.deferrable() {
+        value := ent.value
+        recorded := c.reads.record(ent)
+        c.lock.RUnlock()
+        c.stats.hit()
+        if !recorded {
+            c.drainReads(ent)
+        }
+        return value, true
+    }
+    c.lock.RUnlock()
+    if !ok {
+        c.stats.miss()
+        var empty V
+        return empty, false
+    }
+    return c.getExclusive(key)
+}
+
+// getExclusive does the work of Get under the exclusive lock, for the
+// entries that a read updates.
+func (c *LIRSCache[K, V]) getExclusive(key K) (V, bool) {
+    c.writeLock()
+    defer c.lock.Unlock()
+    if ent, ok := c.resident.peekEntry(key); ok {
+        if !ent.Expired() {
+            c.access(c.nodes[key])
+            c.resident.slide(ent)
+            return c.hit(ent)
+        }
+        c.remove(c.nodes[key], EvictExpired)
+    }
+
+    // No hit
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// GetOrLoad looks up a key's value from the cache, or loads it with
+// loader and adds it with the duration the loader returns. Concurrent
+// calls for the same key share a single load; callers waiting on it
+// give up when their ctx is done, but the load carries on for the
+// others. A panic in the loader is raised again in each of them. The
+// cache is not locked while the loader runs.
+func (c *LIRSCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+    return c.loads.load(ctx, c, key, loader)
+}
+
+// writeLock takes the exclusive lock and applies the buffered reads, so
+// the caller sees the lists as left by every Get.
+func (c *LIRSCache[K, V]) writeLock() {
+    c.lock.Lock()
+    c.reads.drain(c.applyRead)
+}
+
+// drainReads applies the buffered reads and the read of ent that did
+// not fit in the buffer. The read is lost if the lock is busy.
+func (c *LIRSCache[K, V]) drainReads(ent *entry[K, V]) {
+    if c.lock.TryLock() {
+        c.reads.drain(c.applyRead)
+        c.applyRead(ent)
+        c.lock.Unlock()
+    }
+}
+
+// applyRead applies a buffered read of ent like Get does, if it is
+// still cached.
+func (c *LIRSCache[K, V]) applyRead(ent *entry[K, V]) {
+    if cur, ok := c.resident.peekEntry(ent.key); ok && cur == ent {
+        c.access(c.nodes[ent.key])
+    }
+}
+
+// hit returns the value of a cache hit, starting a background refresh
+// if it is past its soft TTL.
+func (c *LIRSCache[K, V]) hit(ent *entry[K, V]) (V, bool) {
+    c.stats.hit()
+    if loader, ok := c.refresher.due(ent, time.Now().UnixNano()); ok {
+        go c.refresh(ent, loader)
+    }
+    return ent.value, true
+}
+
+// refresh reloads ent, dropping the result if the entry was updated or
+// removed in the meantime.
+func (c *LIRSCache[K, V]) refresh(ent *entry[K, V], loader LoaderFunc[K, V]) {
+    value, d, err := loader(context.Background(), ent.key)
+
+    c.writeLock()
+    defer c.lock.Unlock()
+    cur, ok := c.resident.peekEntry(ent.key)
+    if !ok || cur != ent || !ent.refreshing {
+        return
+    }
+    if err != nil {
+        ent.refreshing = false
+        return
+    }
+    c.resident.refreshEntry(ent, value, d)
+    c.recost(c.nodes[ent.key], ent.cost)
+    c.shrinkLIR()
+    c.makeRoom(0)
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+func (c *LIRSCache[K, V]) Add(key K, value V) bool {
+    return c.AddWithExpire(key, value, NoExpiration)
+}
+
+// AddWithExpire adds a value to the cache that expires after d.
+// Returns true if an eviction occurred.
+func (c *LIRSCache[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.resident.costOf(key, value), c.resident.ttlOf(d))
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *LIRSCache[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, cost, c.resident.ttlOf(d))
+}
+
+// AddWithSliding adds a value that expires after d, each successful Get
+// pushing its deadline d further. A positive max caps its lifetime from
+// now regardless of accesses. Returns true if an eviction occurred.
+func (c *LIRSCache[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.resident.costOf(key, value), c.resident.slidingTTL(d, max))
+}
+
+// AddWithRefresh adds a value that expires after d. Once the soft TTL
+// refresh has passed, Get keeps returning it but also reloads it in the
+// background with the loader registered by SetRefreshLoader.
+// Returns true if an eviction occurred.
+func (c *LIRSCache[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.resident.costOf(key, value), ttl{expiration: c.resident.expireAt(d), refresh: refresh})
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *LIRSCache[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.resident.costOf(key, value), deadlineTTL(deadline))
+}
+
+// SetRefreshLoader registers the loader used to refresh entries added
+// with AddWithRefresh. The duration it returns restarts the hard TTL.
+func (c *LIRSCache[K, V]) SetRefreshLoader(loader LoaderFunc[K, V]) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.refresher.loader = loader
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating its
+// recency, and if not, adds the value. Returns whether found and whether
+// an eviction occurred.
+func (c *LIRSCache[K, V]) ContainsOrAdd(key K, value V) (ok, evict bool) {
+    c.writeLock()
+    defer c.lock.Unlock()
+    if c.resident.Contains(key) {
+        return true, false
+    }
+    return false, c.add(key, value, c.resident.costOf(key, value), c.resident.ttlOf(NoExpiration))
+}
+
+// add does the work of AddWithCost, the caller must hold the lock.
+func (c *LIRSCache[K, V]) add(key K, value V, cost int64, t ttl) bool {
+    // Update the value in place, which counts as an access
+    if ent, ok := c.resident.peekEntry(key); ok {
+        n := c.nodes[key]
+        c.resident.update(ent, value, t, cost)
+        c.recost(n, cost)
+        c.access(n)
+        return c.makeRoom(0)
+    }
+
+    // Make room first, evictions may prune the ghost of the key
+    evicted := c.makeRoom(cost)
+    c.stats.add()
+    c.resident.put(newEntry(key, value, t, cost))
+
+    // A ghost accessed again while still in S has a shorter reuse
+    // distance than the oldest LIR entry, which it replaces
+    if n, ok := c.nodes[key]; ok {
+        c.stats.ghostHit()
+        c.ghosts.Remove(n.q)
+        c.ghostCost -= n.cost
+        n.q = nil
+        n.cost = cost
+        c.stack.MoveToFront(n.s)
+        c.promote(n)
+        return evicted
+    }
+
+    // Fill the LIR set first, the other new keys are resident HIR
+    n := &lirsNode[K]{key: key, cost: cost}
+    c.nodes[key] = n
+    n.s = c.stack.PushFront(n)
+    if c.lirCost+cost <= c.lirSize {
+        n.status = lirsLIR
+        c.lirCost += cost
+    } else {
+        n.status = lirsHIR
+        n.q = c.queue.PushFront(n)
+        c.prune()
+    }
+    return evicted
+}
+
+// access updates the lists for an access to the resident key of n.
+func (c *LIRSCache[K, V]) access(n *lirsNode[K]) {
+    switch {
+    case n.status == lirsLIR:
+        // Leaving the bottom of S lets the HIR keys older than the next
+        // LIR entry go
+        c.stack.MoveToFront(n.s)
+        c.prune()
+        c.shrinkLIR()
+    case n.s != nil:
+        // An HIR key still in S has a short reuse distance
+        c.queue.Remove(n.q)
+        n.q = nil
+        c.stack.MoveToFront(n.s)
+        c.promote(n)
+    default:
+        // An HIR key pruned from S stays HIR, as the newest of Q
+        n.s = c.stack.PushFront(n)
+        c.queue.MoveToFront(n.q)
+        c.prune()
+    }
+}
+
+// promote turns the HIR key of n, already at the top of S, into a LIR
+// one, demoting the oldest LIR entries to make room.
+func (c *LIRSCache[K, V]) promote(n *lirsNode[K]) {
+    n.status = lirsLIR
+    c.lirCost += n.cost
+    c.shrinkLIR()
+}
+
+// shrinkLIR demotes the oldest LIR entries until the LIR set fits. The
+// newest is only demoted if it does not fit alone.
+func (c *LIRSCache[K, V]) shrinkLIR() {
+    for c.lirCost > c.lirSize {
+        c.demote(c.stack.Back().Value.(*lirsNode[K]))
+    }
+}
+
+// demote turns the LIR key of n, the bottom of S, into a resident HIR
+// one, as the newest of Q.
+func (c *LIRSCache[K, V]) demote(n *lirsNode[K]) {
+    c.stack.Remove(n.s)
+    n.s = nil
+    n.status = lirsHIR
+    c.lirCost -= n.cost
+    n.q = c.queue.PushFront(n)
+    c.prune()
+}
+
+// prune removes the HIR keys from the bottom of S so that it ends with a
+// LIR entry, or is empty if there is none. Ghosts leaving S are
+// forgotten.
+func (c *LIRSCache[K, V]) prune() {
+    for el := c.stack.Back(); el != nil; el = c.stack.Back() {
+        n := el.Value.(*lirsNode[K])
+        if n.status == lirsLIR {
+            return
+        }
+        c.stack.Remove(el)
+        n.s = nil
+        if n.status == lirsGhost {
+            c.forgetGhost(n)
+        }
+    }
+}
+
+// recost changes the cost of n, already changed in its entry.
+func (c *LIRSCache[K, V]) recost(n *lirsNode[K], cost int64) {
+    if n.status == lirsLIR {
+        c.lirCost += cost - n.cost
+    }
+    n.cost = cost
+}
+
+// makeRoom evicts resident HIR entries until an entry of the given cost
+// fits. Returns true if an entry was evicted.
+func (c *LIRSCache[K, V]) makeRoom(cost int64) bool {
+    evicted := false
+    for c.resident.Len() > 0 && c.resident.Cost()+cost > c.size {
+        c.evict()
+        evicted = true
+    }
+    return evicted
+}
+
+// evict evicts the oldest resident HIR entry, demoting the oldest LIR
+// entry first if Q is empty. The key stays in S as a ghost if it is
+// part of it.
+func (c *LIRSCache[K, V]) evict() {
+    if c.queue.Len() == 0 {
+        c.demote(c.stack.Back().Value.(*lirsNode[K]))
+    }
+    n := c.queue.Remove(c.queue.Back()).(*lirsNode[K])
+    ent, _ := c.resident.take(n.key)
+    c.resident.notify(ent, EvictCapacity)
+    if n.s == nil {
+        delete(c.nodes, n.key)
+        return
+    }
+
+    // Remember the ghosts up to the capacity of the cache
+    n.status = lirsGhost
+    n.q = c.ghosts.PushFront(n)
+    c.ghostCost += n.cost
+    for c.ghostCost > c.size {
+        oldest := c.ghosts.Back().Value.(*lirsNode[K])
+        c.stack.Remove(oldest.s)
+        oldest.s = nil
+        c.forgetGhost(oldest)
+    }
+}
+
+// forgetGhost forgets the ghost of n, already removed from S.
+func (c *LIRSCache[K, V]) forgetGhost(n *lirsNode[K]) {
+    c.ghosts.Remove(n.q)
+    n.q = nil
+    c.ghostCost -= n.cost
+    delete(c.nodes, n.key)
+}
+
+// remove removes the resident key of n for the given reason, without
+// remembering it as a ghost.
+func (c *LIRSCache[K, V]) remove(n *lirsNode[K], reason EvictReason) {
+    c.resident.removeKey(n.key, reason)
+    if n.status == lirsLIR {
+        c.lirCost -= n.cost
+    } else {
+        c.queue.Remove(n.q)
+        n.q = nil
+    }
+    if n.s != nil {
+        c.stack.Remove(n.s)
+        n.s = nil
+    }
+    delete(c.nodes, n.key)
+    c.prune()
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (c *LIRSCache[K, V]) Stats() Stats {
+    s := c.stats.snapshot()
+    c.writeLock()
+    defer c.lock.Unlock()
+    s.Len = c.resident.Len()
+    s.Cost = c.resident.Cost()
+    s.LIRS = &LIRSStats{
+        LIR:   c.resident.Len() - c.queue.Len(),
+        HIR:   c.queue.Len(),
+        Ghost: c.ghosts.Len(),
+        Stack: c.stack.Len(),
+    }
+    return s
+}
+
+// ResetStats zeroes the counters of the cache, to report statistics per
+// time window.
+func (c *LIRSCache[K, V]) ResetStats() {
+    c.stats.reset()
+}
+
+// RemoveExpired removes every expired entry from the cache in the order
+// they expired, returning how many were removed. Expired entries are not
+// remembered as ghosts.
+func (c *LIRSCache[K, V]) RemoveExpired() int {
+    c.writeLock()
+    defer c.lock.Unlock()
+    now := time.Now().UnixNano()
+    removed := 0
+    for {
+        kv, ok := c.resident.expiry.peek()
+        if !ok || kv.Expiration >= now {
+            return removed
+        }
+        c.remove(c.nodes[kv.key], EvictExpired)
+        removed++
+    }
+}
+
+// StartJanitor starts a background goroutine that removes expired
+// entries every interval, replacing any janitor started before. Call
+// Close to stop it. A non-positive interval is ignored.
+func (c *LIRSCache[K, V]) StartJanitor(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    j := startJanitor(interval, func() { c.RemoveExpired() })
+    c.lock.Lock()
+    old := c.janitor
+    c.janitor = j
+    c.lock.Unlock()
+    old.Stop()
+}
+
+// Close stops the janitor, if any. The cache remains usable.
+func (c *LIRSCache[K, V]) Close() {
+    c.lock.Lock()
+    j := c.janitor
+    c.janitor = nil
+    c.lock.Unlock()
+    j.Stop()
+}
+
+// Len returns the number of cached entries
+func (c *LIRSCache[K, V]) Len() int {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.resident.Len()
+}
+
+// Cost returns the total cost of the cached entries
+func (c *LIRSCache[K, V]) Cost() int64 {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.resident.Cost()
+}
+
+// Keys returns all the cached keys, from the first to be evicted to the
+// last: the resident HIR keys from oldest to newest, then the LIR keys
+// from the bottom of S to its top.
+func (c *LIRSCache[K, V]) Keys() []K {
+    c.writeLock()
+    defer c.lock.Unlock()
+    keys := make([]K, 0, c.resident.Len())
+    for el := c.queue.Back(); el != nil; el = el.Prev() {
+        keys = append(keys, el.Value.(*lirsNode[K]).key)
+    }
+    for el := c.stack.Back(); el != nil; el = el.Prev() {
+        if n := el.Value.(*lirsNode[K]); n.status == lirsLIR {
+            keys = append(keys, n.key)
+        }
+    }
+    return keys
+}
+
+// Remove is used to purge a key from the cache, returning if the key
+// was contained. Forgetting a ghost entry does not count as containing it.
+func (c *LIRSCache[K, V]) Remove(key K) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    n, ok := c.nodes[key]
+    if !ok {
+        return false
+    }
+    if n.status != lirsGhost {
+        c.remove(n, EvictRemoved)
+        return true
+    }
+    c.stack.Remove(n.s)
+    n.s = nil
+    c.forgetGhost(n)
+    return false
+}
+
+// Purge is used to clear the cache, forgetting the ghosts too.
+func (c *LIRSCache[K, V]) Purge() {
+    c.writeLock()
+    defer c.lock.Unlock()
+    c.resident.Purge()
+    c.nodes = make(map[K]*lirsNode[K])
+    c.stack.Init()
+    c.queue.Init()
+    c.ghosts.Init()
+    c.lirCost = 0
+    c.ghostCost = 0
+}
+
+// Contains is used to check if the cache contains a key without
+// updating its recency.
+func (c *LIRSCache[K, V]) Contains(key K) bool {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.resident.Contains(key)
+}
+
+// Peek is used to inspect the cache value of a key without updating its
+// recency.
+func (c *LIRSCache[K, V]) Peek(key K) (V, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.resident.Peek(key)
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *LIRSCache[K, V]) TTL(key K) (time.Duration, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.resident.TTL(key)
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration,
+// without updating its value or recency. Returns false if the key is
+// missing or stale.
+func (c *LIRSCache[K, V]) Touch(key K, d time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.resident.Touch(key, d)
+}
+
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time, without updating its value or recency. Returns false if the
+// key is missing or stale.
+func (c *LIRSCache[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.resident.SetExpiration(key, deadline)
+}
@@ -0,0 +1,407 @@
+package go_lru
+
+import (
+    "fmt"
+    "math/rand"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// stackKeys returns the keys of S from top to bottom, ghosts included.
+func (c *LIRSCache[K, V]) stackKeys() []K {
+    c.flushReads()
+    var keys []K
+    for el := c.stack.Front(); el != nil; el = el.Next() {
+        keys = append(keys, el.Value.(*lirsNode[K]).key)
+    }
+    return keys
+}
+
+// check verifies the invariants of the lists.
+func (c *LIRSCache[K, V]) check() error {
+    c.flushReads()
+    if c.resident.Cost() > c.size || c.lirCost > c.lirSize || c.ghostCost > c.size {
+        return fmt.Errorf("over capacity: resident %d lir %d ghosts %d", c.resident.Cost(), c.lirCost, c.ghostCost)
+    }
+    if el := c.stack.Back(); el != nil && el.Value.(*lirsNode[K]).status != lirsLIR {
+        return fmt.Errorf("bottom of S is not LIR: %v", el.Value.(*lirsNode[K]).key)
+    }
+    lir := 0
+    for _, n := range c.nodes {
+        if n.status == lirsLIR {
+            lir++
+        }
+        if _, ok := c.resident.peekEntry(n.key); ok == (n.status == lirsGhost) {
+            return fmt.Errorf("bad residency of %v", n.key)
+        }
+    }
+    if lir+c.queue.Len() != c.resident.Len() || lir+c.queue.Len()+c.ghosts.Len() != len(c.nodes) {
+        return fmt.Errorf("bad sets: lir %d hir %d ghosts %d nodes %d", lir, c.queue.Len(), c.ghosts.Len(), len(c.nodes))
+    }
+    return nil
+}
+
+func TestLIRS_RandomOps(t *testing.T) {
+    l, err := NewLIRS(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    n := 200000
+    for i := 0; i < n; i++ {
+        key := fmt.Sprintf("%d", rand.Int63()%512)
+        r := rand.Int63()
+        switch r % 3 {
+        case 0:
+            l.Add(key, key)
+        case 1:
+            l.Get(key)
+        case 2:
+            l.Remove(key)
+        }
+
+        if i%64 == 0 {
+            if err := l.check(); err != nil {
+                t.Fatalf("bad: %v", err)
+            }
+        }
+    }
+}
+
+// Test that a loop slightly larger than the cache keeps hitting the LIR
+// set, where an LRU cache never hits
+func TestLIRS_Loop(t *testing.T) {
+    l, err := NewLIRS(4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    lru, err := New(4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    loop := func(c Interface[string, interface{}]) (hits int) {
+        for i := 0; i < 5; i++ {
+            k := fmt.Sprint(i)
+            if _, ok := c.Get(k); ok {
+                hits++
+            } else {
+                c.Add(k, i)
+            }
+        }
+        return hits
+    }
+
+    // The first three keys fill the LIR set, 3 is evicted by 4 but stays
+    // in S as a ghost
+    loop(l)
+    loop(lru)
+    if s := l.stackKeys(); !reflect.DeepEqual(s, []string{"4", "3", "2", "1", "0"}) {
+        t.Fatalf("bad S: %v", s)
+    }
+    if s := l.Stats().LIRS; *s != (LIRSStats{LIR: 3, HIR: 1, Ghost: 1, Stack: 5}) {
+        t.Fatalf("bad sets: %+v", s)
+    }
+
+    // Current state
+    // S : (top) [4, 3g, 2, 1, 0] (bottom)
+    // Q : (newest) [4] (oldest)
+
+    // Every pass hits the LIR set, pruning the HIR keys from S when 2
+    // leaves its bottom
+    for pass := 0; pass < 3; pass++ {
+        if hits := loop(l); hits != 3 {
+            t.Fatalf("bad hits: %d", hits)
+        }
+        if hits := loop(lru); hits != 0 {
+            t.Fatalf("bad LRU hits: %d", hits)
+        }
+        if s := l.stackKeys(); !reflect.DeepEqual(s, []string{"4", "3", "2", "1", "0"}) {
+            t.Fatalf("bad S: %v", s)
+        }
+    }
+    if k := l.Keys(); !reflect.DeepEqual(k, []string{"4", "0", "1", "2"}) {
+        t.Fatalf("bad keys: %v", k)
+    }
+}
+
+// Test that a scan of keys seen once only goes through the resident HIR
+// entries
+func TestLIRS_Scan(t *testing.T) {
+    l, err := NewLIRS(10, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Fill the LIR set with the hot keys
+    for i := 0; i < 9; i++ {
+        l.Add(fmt.Sprintf("hot%d", i), i)
+    }
+    for i := 0; i < 9; i++ {
+        l.Get(fmt.Sprintf("hot%d", i))
+    }
+
+    // Scan many more keys than the cache holds
+    for i := 0; i < 100; i++ {
+        l.Add(fmt.Sprintf("scan%d", i), i)
+    }
+    if s := l.Stats().LIRS; s.LIR != 9 || s.HIR != 1 {
+        t.Fatalf("bad sets: %+v", s)
+    }
+    for i := 0; i < 9; i++ {
+        if _, ok := l.Get(fmt.Sprintf("hot%d", i)); !ok {
+            t.Fatalf("hot%d should survive the scan", i)
+        }
+    }
+    if !l.Contains("scan99") || l.Contains("scan98") {
+        t.Fatalf("only the last scanned key should be resident")
+    }
+    if err := l.check(); err != nil {
+        t.Fatalf("bad: %v", err)
+    }
+}
+
+func TestLIRS_Adaptive(t *testing.T) {
+    l, err := NewLIRS(4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Fill the LIR set, then evict 3 for 4
+    for i := 0; i < 5; i++ {
+        l.Add(fmt.Sprintf("%d", i), i)
+    }
+    if n := l.queue.Len(); n != 1 {
+        t.Fatalf("bad: %d", n)
+    }
+    if n := l.ghosts.Len(); n != 1 {
+        t.Fatalf("bad: %d", n)
+    }
+
+    // Current state
+    // S : (top) [4, 3g, 2, 1, 0] (bottom)
+    // Q : (newest) [4] (oldest)
+
+    // Add 3, should hit the ghost: 4 is evicted to make room, and 3
+    // takes the place of 0 in the LIR set
+    l.Add("3", 3)
+    if s := l.Stats(); s.GhostHits != 1 || *s.LIRS != (LIRSStats{LIR: 3, HIR: 1, Ghost: 1, Stack: 4}) {
+        t.Fatalf("bad stats: %+v %+v", s, s.LIRS)
+    }
+    if s := l.stackKeys(); !reflect.DeepEqual(s, []string{"3", "4", "2", "1"}) {
+        t.Fatalf("bad S: %v", s)
+    }
+
+    // Current state
+    // S : (top) [3, 4g, 2, 1] (bottom)
+    // Q : (newest) [0] (oldest)
+
+    // Get 0, a resident HIR key pruned from S, should stay HIR
+    if _, ok := l.Get("0"); !ok {
+        t.Fatalf("0 should be resident")
+    }
+    if s := l.stackKeys(); !reflect.DeepEqual(s, []string{"0", "3", "4", "2", "1"}) {
+        t.Fatalf("bad S: %v", s)
+    }
+    if k := l.Keys(); !reflect.DeepEqual(k, []string{"0", "1", "2", "3"}) {
+        t.Fatalf("bad keys: %v", k)
+    }
+
+    // Current state
+    // S : (top) [0, 3, 4g, 2, 1] (bottom)
+    // Q : (newest) [0] (oldest)
+
+    // Get 0 again, its reuse distance now beats 1 which is demoted
+    l.Get("0")
+    if s := l.stackKeys(); !reflect.DeepEqual(s, []string{"0", "3", "4", "2"}) {
+        t.Fatalf("bad S: %v", s)
+    }
+    if k := l.Keys(); !reflect.DeepEqual(k, []string{"1", "2", "3", "0"}) {
+        t.Fatalf("bad keys: %v", k)
+    }
+
+    // Current state
+    // S : (top) [0, 3, 4g, 2] (bottom)
+    // Q : (newest) [1] (oldest)
+
+    // Remove 4, forgetting a ghost is not containing it
+    if l.Remove("4") {
+        t.Fatalf("4 should not be contained")
+    }
+    if n := l.ghosts.Len(); n != 0 {
+        t.Fatalf("bad: %d", n)
+    }
+    if err := l.check(); err != nil {
+        t.Fatalf("bad: %v", err)
+    }
+}
+
+func TestLIRS(t *testing.T) {
+    l, err := NewLIRS(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // The first keys fill the LIR set, the others go through Q
+    for i := 0; i < 256; i++ {
+        l.Add(fmt.Sprintf("%d", i), i)
+    }
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    for i, k := range l.Keys() {
+        want := i - 1
+        if i == 0 {
+            want = 255
+        }
+        if v, ok := l.Get(k); !ok || fmt.Sprint(v) != k || v.(int) != want {
+            t.Fatalf("bad key: %v", k)
+        }
+    }
+    for i := 127; i < 255; i++ {
+        if _, ok := l.Get(fmt.Sprintf("%d", i)); ok {
+            t.Fatalf("should be evicted")
+        }
+    }
+
+    l.AddWithExpire(fmt.Sprint(256), 256, 20*time.Millisecond)
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if v, ok := l.Get(fmt.Sprint(256)); !ok || v.(int) != 256 {
+        t.Fatalf("bad key: %v", 256)
+    }
+    time.Sleep(30 * time.Millisecond)
+    if _, ok := l.Get(fmt.Sprint(256)); ok {
+        t.Fatalf("bad key: %v", 256)
+    }
+
+    for i := 0; i < 64; i++ {
+        l.Remove(fmt.Sprintf("%d", i))
+        if _, ok := l.Get(fmt.Sprintf("%d", i)); ok {
+            t.Fatalf("should be deleted")
+        }
+    }
+    if err := l.check(); err != nil {
+        t.Fatalf("bad: %v", err)
+    }
+
+    l.Purge()
+    if l.Len() != 0 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if _, ok := l.Get(fmt.Sprint(100)); ok {
+        t.Fatalf("should contain nothing")
+    }
+    if s := l.Stats().LIRS; *s != (LIRSStats{}) {
+        t.Fatalf("bad sets: %+v", s)
+    }
+}
+
+// Test that the LIR set gets a cost budget
+func TestLIRS_Cost(t *testing.T) {
+    l, err := NewLIRSWithCostOf[string, int](100, NoExpiration, func(k string, v int) int64 {
+        return int64(v)
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Without resident HIR entries, the oldest LIR entry is demoted and
+    // evicted to make room
+    l.Add("a", 30)
+    l.Add("b", 30)
+    l.Add("c", 30)
+    l.Add("d", 30)
+    if l.Contains("a") || l.Cost() != 90 {
+        t.Fatalf("a should have been evicted: %d", l.Cost())
+    }
+
+    // The LIR set holds 99, leaving f in Q
+    l.Get("b")
+    l.Add("e", 5)
+    l.Add("f", 5)
+    if s := l.Stats().LIRS; s.LIR != 4 || s.HIR != 1 {
+        t.Fatalf("bad sets: %+v", s)
+    }
+
+    // Promoting f demotes c, the oldest LIR entry
+    l.Get("f")
+    if k := l.Keys(); !reflect.DeepEqual(k, []string{"c", "d", "b", "e", "f"}) {
+        t.Fatalf("bad keys: %v", k)
+    }
+    if l.lirCost != 70 {
+        t.Fatalf("bad lir cost: %d", l.lirCost)
+    }
+    if err := l.check(); err != nil {
+        t.Fatalf("bad: %v", err)
+    }
+}
+
+func TestLIRS_Sliding(t *testing.T) {
+    l, err := NewLIRS(128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithSliding("a", 1, 50*time.Millisecond, NoExpiration)
+    for i := 0; i < 3; i++ {
+        time.Sleep(30 * time.Millisecond)
+        if _, ok := l.Get("a"); !ok {
+            t.Fatalf("sliding entry should still be cached")
+        }
+    }
+
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Peek("a"); ok {
+        t.Fatalf("sliding entry should have expired")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if err := l.check(); err != nil {
+        t.Fatalf("bad: %v", err)
+    }
+}
+
+func TestLIRS_TTL(t *testing.T) {
+    l, err := NewLIRS(2, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Entries of the LIR set and of Q alike
+    l.AddWithExpire("a", 1, 10*time.Millisecond)
+    l.Add("b", 2)
+    if l.queue.Len() != 1 {
+        t.Fatalf("b should be HIR")
+    }
+    if !l.Touch("a", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("a"); !ok || d <= 59*time.Second {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Updates honor the new duration
+    l.Add("a", 10)
+    if d, ok := l.TTL("a"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Expired entries are not remembered as ghosts
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("b"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if l.ghosts.Len() != 0 {
+        t.Fatalf("b should be forgotten")
+    }
+    if err := l.check(); err != nil {
+        t.Fatalf("bad: %v", err)
+    }
+}
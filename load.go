@@ -0,0 +1,100 @@
+package go_lru
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// LoaderFunc loads the value of a key missing from the cache. It returns
+// the value along with how long it should be cached, which follows the
+// same rules as AddWithExpire. Errors are returned to the callers and
+// nothing is cached.
+type LoaderFunc[K comparable, V any] func(ctx context.Context, key K) (V, time.Duration, error)
+
+// loadCall is a load in flight, or finished once done is closed.
+// panicked holds the value the loader panicked with, if it did.
+type loadCall[V any] struct {
+    done     chan struct{}
+    val      V
+    err      error
+    panicked interface{}
+}
+
+// loadGroup coalesces concurrent loads of the same key so the loader
+// runs once while the other callers wait for its result. The zero value
+// is ready to use.
+type loadGroup[K comparable, V any] struct {
+    mu    sync.Mutex
+    calls map[K]*loadCall[V]
+}
+
+// load returns the cached value of key, or loads it into c with loader.
+// The cache lock is never held while the loader runs. The loader runs on
+// a context detached from the callers' cancellation, so the first caller
+// giving up does not fail the load for those still waiting.
+func (g *loadGroup[K, V]) load(ctx context.Context, c Interface[K, V], key K, loader LoaderFunc[K, V]) (V, error) {
+    if val, ok := c.Get(key); ok {
+        return val, nil
+    }
+
+    g.mu.Lock()
+    if g.calls == nil {
+        g.calls = make(map[K]*loadCall[V])
+    }
+    if call, ok := g.calls[key]; ok {
+        g.mu.Unlock()
+        return call.wait(ctx)
+    }
+    call := &loadCall[V]{done: make(chan struct{})}
+    g.calls[key] = call
+    g.mu.Unlock()
+
+    go g.run(context.WithoutCancel(ctx), c, call, key, loader)
+    return call.wait(ctx)
+}
+
+// run calls the loader for key and publishes the result to the waiters.
+// A panic in the loader is recovered here and raised again by wait, in
+// the goroutine of each caller.
+func (g *loadGroup[K, V]) run(ctx context.Context, c Interface[K, V], call *loadCall[V], key K, loader LoaderFunc[K, V]) {
+    defer func() {
+        if r := recover(); r != nil {
+            call.panicked = r
+        }
+        g.mu.Lock()
+        delete(g.calls, key)
+        g.mu.Unlock()
+        close(call.done)
+    }()
+
+    // A load that finished between our miss and taking the key
+    // already filled the cache
+    if val, ok := c.Peek(key); ok {
+        call.val = val
+        return
+    }
+
+    val, d, err := loader(ctx, key)
+    if err != nil {
+        call.err = err
+        return
+    }
+    c.AddWithExpire(key, val, d)
+    call.val = val
+}
+
+// wait blocks until the load finishes or ctx is done, and panics if
+// the loader did.
+func (call *loadCall[V]) wait(ctx context.Context) (V, error) {
+    select {
+    case <-call.done:
+        if call.panicked != nil {
+            panic(call.panicked)
+        }
+        return call.val, call.err
+    case <-ctx.Done():
+        var empty V
+        return empty, ctx.Err()
+    }
+}
@@ -0,0 +1,158 @@
+package go_lru
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func testGetOrLoad(t *testing.T, l Interface[string, int], getOrLoad func(context.Context, string, LoaderFunc[string, int]) (int, error)) {
+    var calls int32
+    release := make(chan struct{})
+    loader := func(ctx context.Context, key string) (int, time.Duration, error) {
+        atomic.AddInt32(&calls, 1)
+        // The cache must not be locked while loading
+        l.Len()
+        <-release
+        return 42, NoExpiration, nil
+    }
+
+    // Concurrent misses share a single load
+    var wg sync.WaitGroup
+    for i := 0; i < 50; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if v, err := getOrLoad(context.Background(), "a", loader); err != nil || v != 42 {
+                t.Errorf("bad load: %v, %v", v, err)
+            }
+        }()
+    }
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    wg.Wait()
+    if n := atomic.LoadInt32(&calls); n != 1 {
+        t.Fatalf("loader should run once: %d", n)
+    }
+
+    // Hits do not call the loader
+    if v, err := getOrLoad(context.Background(), "a", loader); err != nil || v != 42 {
+        t.Fatalf("bad hit: %v, %v", v, err)
+    }
+    if n := atomic.LoadInt32(&calls); n != 1 {
+        t.Fatalf("loader should not run on a hit: %d", n)
+    }
+
+    // Errors are returned but not cached
+    errLoad := errors.New("load failed")
+    failing := func(ctx context.Context, key string) (int, time.Duration, error) {
+        return 0, NoExpiration, errLoad
+    }
+    if _, err := getOrLoad(context.Background(), "b", failing); err != errLoad {
+        t.Fatalf("bad err: %v", err)
+    }
+    if l.Contains("b") {
+        t.Fatalf("failed load should not be cached")
+    }
+
+    // The loader picks the TTL
+    expiring := func(ctx context.Context, key string) (int, time.Duration, error) {
+        return 7, 10 * time.Millisecond, nil
+    }
+    if v, err := getOrLoad(context.Background(), "c", expiring); err != nil || v != 7 {
+        t.Fatalf("bad load: %v, %v", v, err)
+    }
+    time.Sleep(20 * time.Millisecond)
+    if l.Contains("c") {
+        t.Fatalf("loaded value should have expired")
+    }
+
+    // Waiters give up with their context
+    block := make(chan struct{})
+    slow := func(ctx context.Context, key string) (int, time.Duration, error) {
+        <-block
+        return 1, NoExpiration, nil
+    }
+    go getOrLoad(context.Background(), "d", slow)
+    time.Sleep(5 * time.Millisecond)
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+    defer cancel()
+    if _, err := getOrLoad(ctx, "d", slow); err != context.DeadlineExceeded {
+        t.Fatalf("bad err: %v", err)
+    }
+    close(block)
+
+    // The first caller giving up does not cancel the shared load
+    release = make(chan struct{})
+    detached := func(ctx context.Context, key string) (int, time.Duration, error) {
+        <-release
+        return 2, NoExpiration, ctx.Err()
+    }
+    ctx, cancel = context.WithCancel(context.Background())
+    errc := make(chan error, 1)
+    go func() {
+        _, err := getOrLoad(ctx, "e", detached)
+        errc <- err
+    }()
+    time.Sleep(5 * time.Millisecond)
+    cancel()
+    if err := <-errc; err != context.Canceled {
+        t.Fatalf("bad err: %v", err)
+    }
+    close(release)
+    if v, err := getOrLoad(context.Background(), "e", detached); err != nil || v != 2 {
+        t.Fatalf("bad load: %v, %v", v, err)
+    }
+
+    // A panic in the loader is raised again in every caller
+    release = make(chan struct{})
+    panicking := func(ctx context.Context, key string) (int, time.Duration, error) {
+        <-release
+        panic("boom")
+    }
+    for i := 0; i < 2; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            defer func() {
+                if r := recover(); r != "boom" {
+                    t.Errorf("bad panic: %v", r)
+                }
+            }()
+            getOrLoad(context.Background(), "f", panicking)
+        }()
+    }
+    time.Sleep(5 * time.Millisecond)
+    close(release)
+    wg.Wait()
+    if v, err := getOrLoad(context.Background(), "f", detached); err != nil || v != 2 {
+        t.Fatalf("bad load after a panic: %v, %v", v, err)
+    }
+}
+
+func TestLRU_GetOrLoad(t *testing.T) {
+    l, err := NewOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testGetOrLoad(t, l, l.GetOrLoad)
+}
+
+func Test2Q_GetOrLoad(t *testing.T) {
+    l, err := New2QOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testGetOrLoad(t, l, l.GetOrLoad)
+}
+
+func TestARC_GetOrLoad(t *testing.T) {
+    l, err := NewARCOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testGetOrLoad(t, l, l.GetOrLoad)
+}
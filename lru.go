@@ -4,117 +4,87 @@
 package go_lru
 
 import (
-	"sync"
+	"errors"
 	"time"
 )
 
-// Cache is a thread-safe fixed size LRU cache.
-type Cache struct {
-	lru  *BASELRU
-	lock sync.RWMutex
+// Cache is a thread-safe fixed size LRU cache, a PolicyCache evicting
+// with an LRUPolicy. Its writes can also be logged with OpenLog.
+type Cache[K comparable, V any] struct {
+	*PolicyCache[K, V]
 }
 
-// New creates an LRU of the given size
-func New(size int, defaultExpiration time.Duration) (*Cache, error) {
-	return NewWithEvict(size, defaultExpiration,nil)
+// newCache creates an LRU bounded either by size entries or by maxCost
+// total cost.
+func newCache[K comparable, V any](size int, maxCost int64, costFunc CostFunc[K, V], onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{newPolicyCache[K, V](size, maxCost, costFunc, NewLRUPolicy[K](), onEvict, defaultExpiration)}
 }
 
-// NewWithEvict constructs a fixed size cache with the given eviction
-// callback.
-func NewWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*Cache, error) {
-	lru, err := NewBaseLRU(size, EvictCallback(onEvicted), defaultExpiration)
-	if err != nil {
-		return nil, err
-	}
-	c := &Cache{
-		lru: lru,
-	}
-	return c, nil
+// New creates an LRU of the given size with string keys and untyped values.
+func New(size int, defaultExpiration time.Duration) (*Cache[string, interface{}], error) {
+	return NewWithEvict(size, defaultExpiration, nil)
 }
 
-// Purge is used to completely clear the cache
-func (c *Cache) Purge() {
-	c.lock.Lock()
-	c.lru.Purge()
-	c.lock.Unlock()
+// NewWithEvict constructs a fixed size cache with string keys, untyped
+// values and the given eviction callback.
+func NewWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*Cache[string, interface{}], error) {
+	return NewWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
 }
 
-// Add adds a value to the cache with expiration.  Returns true if an eviction occurred.
-func (c *Cache) AddWithExpire(key string, value interface{}, d time.Duration) bool {
-    c.lock.Lock()
-    defer c.lock.Unlock()
-    return c.lru.AddWithExpire(key, value, d)
+// NewWithEvictReason constructs a fixed size cache with string keys,
+// untyped values and an eviction callback that also learns why an entry
+// left the cache.
+func NewWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*Cache[string, interface{}], error) {
+	return NewWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
 }
 
-// Add adds a value to the cache.  Returns true if an eviction occurred.
-func (c *Cache) Add(key string, value interface{}) bool {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	return c.lru.Add(key, value)
+// NewOf creates an LRU of the given size for any comparable key type and
+// value type.
+func NewOf[K comparable, V any](size int, defaultExpiration time.Duration) (*Cache[K, V], error) {
+	return NewWithEvictOf[K, V](size, defaultExpiration, nil)
 }
 
-// Get looks up a key's value from the cache.
-func (c *Cache) Get(key string) (interface{}, bool) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	return c.lru.Get(key)
+// NewWithEvictOf constructs a fixed size cache with the given eviction
+// callback for any comparable key type and value type.
+func NewWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*Cache[K, V], error) {
+	if size <= 0 {
+		return nil, errors.New("Must provide a positive size")
+	}
+	return newCache[K, V](size, 0, nil, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration), nil
 }
 
-// Check if a key is in the cache, without updating the recent-ness
-// or deleting it for being stale.
-func (c *Cache) Contains(key string) bool {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-	return c.lru.Contains(key)
+// NewWithEvictReasonOf constructs a fixed size cache whose eviction
+// callback also learns why an entry left the cache, including the old
+// value of an updated entry.
+func NewWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*Cache[K, V], error) {
+	if size <= 0 {
+		return nil, errors.New("Must provide a positive size")
+	}
+	return newCache[K, V](size, 0, nil, onEvicted, defaultExpiration), nil
 }
 
-// Returns the key value (or undefined if not found) without updating
-// the "recently used"-ness of the key.
-func (c *Cache) Peek(key string) (interface{}, bool) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-	return c.lru.Peek(key)
+// NewWithCost constructs a cache with string keys and untyped values
+// that keeps the total cost of its entries within maxCost.
+func NewWithCost(maxCost int64, defaultExpiration time.Duration, costFunc func(key string, value interface{}) int64, onEvicted func(key string, value interface{})) (*Cache[string, interface{}], error) {
+	return NewWithCostOf[string, interface{}](maxCost, defaultExpiration, costFunc, onEvicted)
 }
 
-// ContainsOrAdd checks if a key is in the cache  without updating the
-// recent-ness or deleting it for being stale,  and if not, adds the value.
-// Returns whether found and whether an eviction occurred.
-func (c *Cache) ContainsOrAdd(key string, value interface{}) (ok, evict bool) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	if c.lru.Contains(key) {
-		return true, false
-	} else {
-		evict := c.lru.Add(key, value)
-		return false, evict
+// NewWithCostOf constructs a cache that keeps the total cost of its
+// entries within maxCost instead of limiting their number. Entries added
+// without an explicit cost are weighed by costFunc, or cost 1 if it is nil.
+func NewWithCostOf[K comparable, V any](maxCost int64, defaultExpiration time.Duration, costFunc func(key K, value V) int64, onEvicted func(key K, value V)) (*Cache[K, V], error) {
+	if maxCost <= 0 {
+		return nil, errors.New("Must provide a positive max cost")
 	}
-}
-
-// Remove removes the provided key from the cache.
-func (c *Cache) Remove(key string) {
-	c.lock.Lock()
-	c.lru.Remove(key)
-	c.lock.Unlock()
+	return newCache[K, V](0, maxCost, costFunc, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration), nil
 }
 
 // RemoveOldest removes the oldest item from the cache.
-func (c *Cache) RemoveOldest() {
-	c.lock.Lock()
-	c.lru.RemoveOldest()
-	c.lock.Unlock()
-}
-
-// Keys returns a slice of the keys in the cache, from oldest to newest.
-func (c *Cache) Keys() []string {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-	return c.lru.Keys()
-}
-
-// Len returns the number of items in the cache.
-func (c *Cache) Len() int {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-	return c.lru.Len()
+func (c *Cache[K, V]) RemoveOldest() {
+	c.writeLock()
+	defer c.lock.Unlock()
+	if key, ok := c.policy.Victim(); ok {
+		c.entries.removeKey(key, EvictRemoved)
+		c.logRemove(key)
+	}
 }
@@ -3,6 +3,7 @@ package go_lru
 import (
 	"testing"
     "fmt"
+    "sync"
     "time"
 )
 
@@ -168,3 +169,250 @@ func TestLRUPeek(t *testing.T) {
 		t.Errorf("should not have updated recent-ness of 1")
 	}
 }
+
+// test that the cache works with typed keys and values
+func TestLRUOf(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k int, v string) {
+		if fmt.Sprint(k) != v {
+			t.Fatalf("Evict values not equal (%v!=%v)", k, v)
+		}
+		evictCounter += 1
+	}
+	l, err := NewWithEvictOf[int, string](128, NoExpiration, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 256; i++ {
+		l.Add(i, fmt.Sprint(i))
+	}
+	if l.Len() != 128 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if evictCounter != 128 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+
+	for i, k := range l.Keys() {
+		if v, ok := l.Get(k); !ok || v != fmt.Sprint(k) || k != i+128 {
+			t.Fatalf("bad key: %v", k)
+		}
+	}
+	if v, ok := l.Get(0); ok || v != "" {
+		t.Fatalf("should be evicted: %v", v)
+	}
+}
+
+// test that the cache keeps the total cost within budget
+func TestLRUCost(t *testing.T) {
+	l, err := NewWithCost(100, NoExpiration, func(k string, v interface{}) int64 {
+		return int64(len(v.(string)))
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		l.Add(fmt.Sprint(i), "0123456789")
+	}
+	if l.Len() != 10 || l.Cost() != 100 {
+		t.Fatalf("bad len: %v cost: %v", l.Len(), l.Cost())
+	}
+	if l.Contains("9") || !l.Contains("10") {
+		t.Fatalf("should have evicted the oldest entries")
+	}
+
+	if !l.AddWithCost("big", nil, 50, NoExpiration) {
+		t.Fatalf("should have an eviction")
+	}
+	if l.Len() != 6 || l.Cost() != 100 {
+		t.Fatalf("bad len: %v cost: %v", l.Len(), l.Cost())
+	}
+}
+
+// test that Resize evicts the least recently used entries
+func TestLRUResize(t *testing.T) {
+	var evicted []string
+	l, err := NewWithEvictOf[string, int](4, NoExpiration, func(k string, v int) {
+		evicted = append(evicted, k)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Add(fmt.Sprint(i), i)
+	}
+	l.Get("0")
+	if n := l.Resize(2); n != 2 {
+		t.Fatalf("bad evicted: %v", n)
+	}
+	if fmt.Sprint(evicted) != "[1 2]" || fmt.Sprint(l.Keys()) != "[3 0]" {
+		t.Fatalf("bad evicted: %v keys: %v", evicted, l.Keys())
+	}
+
+	// Growing evicts nothing, and lets more entries in
+	if n := l.Resize(4); n != 0 {
+		t.Fatalf("bad evicted: %v", n)
+	}
+	l.Add("4", 4)
+	l.Add("5", 5)
+	if l.Len() != 4 || len(evicted) != 2 {
+		t.Fatalf("bad len: %v evicted: %v", l.Len(), evicted)
+	}
+	if n := l.Resize(0); n != 0 || l.Len() != 4 {
+		t.Fatalf("should ignore a zero size: %v, %v", n, l.Len())
+	}
+
+	// A cache bounded by cost is resized in cost
+	c, err := NewWithCostOf[string, string](100, NoExpiration, func(k string, v string) int64 {
+		return int64(len(v))
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprint(i), "0123456789")
+	}
+	if n := c.Resize(45); n != 6 || c.Cost() != 40 || !c.Contains("6") {
+		t.Fatalf("bad evicted: %v cost: %v", n, c.Cost())
+	}
+}
+
+// test that the janitor reclaims expired entries in the background
+func TestLRUJanitor(t *testing.T) {
+	var lock sync.Mutex
+	evicted := make(map[string]bool)
+	onEvicted := func(k string, v interface{}) {
+		lock.Lock()
+		evicted[k] = true
+		lock.Unlock()
+	}
+	l, err := NewWithEvict(128, NoExpiration, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	l.AddWithExpire("1", 1, 10*time.Millisecond)
+	l.Add("2", 2)
+	l.StartJanitor(5 * time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	if l.Len() != 1 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if keys := l.Keys(); len(keys) != 1 || keys[0] != "2" {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	lock.Lock()
+	if !evicted["1"] || evicted["2"] {
+		t.Fatalf("bad evictions: %v", evicted)
+	}
+	lock.Unlock()
+
+	// Once closed nothing is reclaimed anymore
+	l.Close()
+	l.Close()
+	l.AddWithExpire("3", 3, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if l.Len() != 2 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+// test that every cache ignores a non-positive janitor interval, keeping
+// the janitor started before
+func TestJanitorInterval(t *testing.T) {
+	caches := map[string]func() (Shard[string, int], error){
+		"LRU":     func() (Shard[string, int], error) { return NewOf[string, int](8, NoExpiration) },
+		"2Q":      func() (Shard[string, int], error) { return New2QOf[string, int](8, NoExpiration) },
+		"ARC":     func() (Shard[string, int], error) { return NewARCOf[string, int](8, NoExpiration) },
+		"CAR":     func() (Shard[string, int], error) { return NewCAROf[string, int](8, NoExpiration) },
+		"LIRS":    func() (Shard[string, int], error) { return NewLIRSOf[string, int](8, NoExpiration) },
+		"LFU":     func() (Shard[string, int], error) { return NewLFUOf[string, int](8, NoExpiration) },
+		"TinyLFU": func() (Shard[string, int], error) { return NewTinyLFUOf[string, int](8, NoExpiration) },
+		"Sieve":   func() (Shard[string, int], error) { return NewSieveOf[string, int](8, NoExpiration) },
+		"S3FIFO":  func() (Shard[string, int], error) { return NewS3FIFOOf[string, int](8, NoExpiration) },
+		"Sharded": func() (Shard[string, int], error) {
+			return NewShardedOf[string, int](2, 8, func(size int) (Shard[string, int], error) {
+				return NewOf[string, int](size, NoExpiration)
+			})
+		},
+	}
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			l, err := newCache()
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			defer l.Close()
+
+			l.AddWithExpire("1", 1, 10*time.Millisecond)
+			l.StartJanitor(5 * time.Millisecond)
+			l.StartJanitor(0)
+			l.StartJanitor(-time.Second)
+			time.Sleep(50 * time.Millisecond)
+			if l.Len() != 0 {
+				t.Fatalf("the janitor should still run: %v", l.Keys())
+			}
+		})
+	}
+}
+
+func TestLRUSliding(t *testing.T) {
+	l, err := New(128, NoExpiration)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.AddWithSliding("a", 1, 50*time.Millisecond, NoExpiration)
+	for i := 0; i < 3; i++ {
+		time.Sleep(30 * time.Millisecond)
+		if _, ok := l.Get("a"); !ok {
+			t.Fatalf("sliding entry should still be cached")
+		}
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := l.Peek("a"); ok {
+		t.Fatalf("sliding entry should have expired")
+	}
+	if n := l.RemoveExpired(); n != 1 {
+		t.Fatalf("bad removed: %d", n)
+	}
+}
+
+func TestLRUTTL(t *testing.T) {
+	l, err := New(128, NoExpiration)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Recent and frequent entries alike
+	l.AddWithExpire("a", 1, 10*time.Millisecond)
+	l.Add("b", 2)
+	l.Get("b")
+	if !l.Touch("a", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+		t.Fatalf("should set expiration")
+	}
+	if d, ok := l.TTL("a"); !ok || d <= 59*time.Second {
+		t.Fatalf("bad ttl: %v, %v", d, ok)
+	}
+
+	// Updates honor the new duration
+	l.Add("a", 10)
+	if d, ok := l.TTL("a"); !ok || d != NoExpiration {
+		t.Fatalf("bad ttl: %v, %v", d, ok)
+	}
+
+	l.AddWithDeadline("c", 3, time.Now().Add(10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := l.TTL("b"); ok {
+		t.Fatalf("stale key should have no ttl")
+	}
+	if n := l.RemoveExpired(); n != 2 {
+		t.Fatalf("bad removed: %d", n)
+	}
+}
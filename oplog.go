@@ -0,0 +1,639 @@
+package go_lru
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// SyncPolicy tells when the operation log of a cache is synced to disk.
+type SyncPolicy int
+
+const (
+    // SyncAlways syncs the log after every operation, which then survives
+    // a crash of the machine. The sync runs under the exclusive lock of
+    // the cache, so every write waits for the disk, and so do the reads
+    // and writes of the other goroutines meanwhile: the cache serves at
+    // most one write per sync latency, a few milliseconds on most disks.
+    // Use SyncInterval where that is too slow.
+    SyncAlways SyncPolicy = iota
+    // SyncInterval syncs the log periodically, losing at most the last
+    // interval of operations if the machine crashes.
+    SyncInterval
+    // SyncNever leaves syncing to the operating system. Operations survive
+    // a crash of the process but not of the machine.
+    SyncNever
+)
+
+// LogOptions configures the operation log of a cache.
+type LogOptions struct {
+    // Sync is when the log is synced to disk, SyncAlways by default, which
+    // blocks the whole cache for a sync on every write.
+    Sync SyncPolicy
+    // SyncInterval is the period of SyncInterval, a second if zero.
+    SyncInterval time.Duration
+    // SegmentSize is the size past which a segment is closed and the next
+    // one started, 64 MiB if zero.
+    SegmentSize int64
+    // CompactInterval is the period at which the segments are folded into
+    // a new snapshot in the background. Zero leaves compaction to Compact.
+    // A background compaction failing stops the log like a failed write.
+    CompactInterval time.Duration
+}
+
+// The log of a cache is a directory of segments and snapshots, named
+// after their sequence number with 16 digits, like 0000000000000003.log.
+// The snapshot of sequence number N, written with the format of
+// SnapshotFile, holds the cache as it was before segment N. The cache is
+// opened by restoring the latest snapshot and replaying the segments from
+// its sequence number on. Segments are written in the following format,
+// all integers being little-endian:
+//
+//	header   magic    [8]byte  "GOLRUWAL"
+//	         version  uint16   logVersion
+//	         reserved uint16   0
+//	         crc      uint32   CRC32C of the 12 bytes above
+//	record   length   uint32   length of the payload
+//	         crc      uint32   CRC32C of the payload
+//	         payload  op uint8, then unless logPurge a snapshot record
+//	                  encoded on its own with the codec
+//	...
+//
+// A crash can leave a torn record at the end of the last segment, which
+// is dropped when the log is opened. Anywhere else, a record failing its
+// checksum makes the log fail to open.
+const (
+    logMagic   = "GOLRUWAL"
+    logVersion = 1
+
+    logHeaderSize = 16
+
+    logSegmentExt  = ".log"
+    logSnapshotExt = ".snap"
+
+    defaultSegmentSize  = 64 << 20
+    defaultSyncInterval = time.Second
+)
+
+// The operations of the log.
+const (
+    logSet    uint8 = iota + 1 // logSet records the entry of a key after a write
+    logUpdate                  // logUpdate records an entry changed in place
+    logRemove                  // logRemove records the removal of a key
+    logPurge                   // logPurge records the removal of every key
+)
+
+var (
+    // ErrLogCorrupt is reported for an operation log failing its checksums
+    // or holding invalid data before its last record.
+    ErrLogCorrupt = errors.New("operation log corrupt")
+    // ErrLogClosed is returned by Compact when no log is open.
+    ErrLogClosed = errors.New("operation log not open")
+)
+
+// LogError is returned when the operation log cannot be replayed, with
+// the segment and the offset in it where the problem was found. Err wraps
+// ErrLogCorrupt or ErrSnapshotVersion.
+type LogError struct {
+    Path   string
+    Offset int64
+    Err    error
+}
+
+func (e *LogError) Error() string {
+    return fmt.Sprintf("go_lru: %v in %s at offset %d", e.Err, e.Path, e.Offset)
+}
+
+func (e *LogError) Unwrap() error {
+    return e.Err
+}
+
+// logOp is an operation read back from the log.
+type logOp[K comparable, V any] struct {
+    op  uint8
+    rec snapshotRecord[K, V]
+}
+
+// opLog appends the operations of a cache to its current segment. The
+// cache holds its lock while appending, so that the log follows the order
+// of the operations.
+type opLog[K comparable, V any] struct {
+    dir   string
+    opts  LogOptions
+    codec Codec
+
+    mu   sync.Mutex // mu guards the fields below, and the segment file
+    f    *os.File
+    seq  uint64 // seq is the sequence number of the current segment
+    base uint64 // base is the sequence number of the latest snapshot
+    size int64  // size is the length of the current segment
+    err  error  // err is the first write error, which stops the log
+    buf  bytes.Buffer
+
+    compactMu sync.Mutex // compactMu serializes compactions and closing
+    syncer    *janitor
+    compactor *janitor
+}
+
+// path returns the path of the segment or snapshot of sequence number seq.
+func (l *opLog[K, V]) path(seq uint64, ext string) string {
+    return filepath.Join(l.dir, fmt.Sprintf("%016d%s", seq, ext))
+}
+
+// append appends op and rec, if not nil, to the log. Errors are kept for
+// Compact and CloseLog to report, and stop the log.
+func (l *opLog[K, V]) append(op uint8, rec *snapshotRecord[K, V]) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.err != nil {
+        return
+    }
+
+    // The frame is filled in once the payload is encoded
+    l.buf.Reset()
+    l.buf.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0, op})
+    if rec != nil {
+        if err := l.codec.NewEncoder(&l.buf).Encode(rec); err != nil {
+            l.err = err
+            return
+        }
+    }
+    b := l.buf.Bytes()
+    binary.LittleEndian.PutUint32(b, uint32(len(b)-8))
+    binary.LittleEndian.PutUint32(b[4:], crc32.Checksum(b[8:], castagnoli))
+    if _, err := l.f.Write(b); err != nil {
+        l.err = err
+        return
+    }
+    l.size += int64(len(b))
+
+    if l.opts.Sync == SyncAlways {
+        l.err = l.f.Sync()
+    }
+    if l.err == nil && l.size >= l.opts.SegmentSize {
+        l.err = l.rotate()
+    }
+}
+
+// rotate closes the current segment, if any, and starts the next one.
+// l.mu must be held.
+func (l *opLog[K, V]) rotate() error {
+    if l.f != nil {
+        if err := l.f.Sync(); err != nil {
+            return err
+        }
+        if err := l.f.Close(); err != nil {
+            return err
+        }
+        l.f = nil
+    }
+    f, err := os.OpenFile(l.path(l.seq+1, logSegmentExt), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+    if err != nil {
+        return err
+    }
+    var hdr [logHeaderSize]byte
+    copy(hdr[:8], logMagic)
+    binary.LittleEndian.PutUint16(hdr[8:], logVersion)
+    binary.LittleEndian.PutUint32(hdr[12:], crc32.Checksum(hdr[:12], castagnoli))
+    if _, err := f.Write(hdr[:]); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Sync(); err != nil {
+        f.Close()
+        return err
+    }
+    if err := syncDir(l.dir); err != nil {
+        f.Close()
+        return err
+    }
+    l.f = f
+    l.seq++
+    l.size = logHeaderSize
+    return nil
+}
+
+// fail stops the log with err, unless it already failed.
+func (l *opLog[K, V]) fail(err error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.err == nil {
+        l.err = err
+    }
+}
+
+// sync syncs the current segment for SyncInterval.
+func (l *opLog[K, V]) sync() {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.err == nil && l.f != nil {
+        l.err = l.f.Sync()
+    }
+}
+
+// pending tells whether operations were logged since the latest snapshot.
+func (l *opLog[K, V]) pending() bool {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.seq > l.base || l.size > logHeaderSize
+}
+
+// close syncs and closes the current segment, returning the first error
+// of the log.
+func (l *opLog[K, V]) close() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.f != nil {
+        if err := l.f.Sync(); l.err == nil {
+            l.err = err
+        }
+        if err := l.f.Close(); l.err == nil {
+            l.err = err
+        }
+        l.f = nil
+    }
+    return l.err
+}
+
+// removeBefore removes the segments and snapshots that come before the
+// snapshot of sequence number seq, then syncs the directory. Returns the
+// first error met, after trying to remove every file.
+func (l *opLog[K, V]) removeBefore(seq uint64) error {
+    snaps, segs, err := listLog(l.dir)
+    if err != nil {
+        return err
+    }
+    remove := func(path string) {
+        if rerr := os.Remove(path); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+            err = rerr
+        }
+    }
+    for _, s := range snaps {
+        if s < seq {
+            remove(l.path(s, logSnapshotExt))
+        }
+    }
+    for _, s := range segs {
+        if s < seq {
+            remove(l.path(s, logSegmentExt))
+        }
+    }
+    if err != nil {
+        return err
+    }
+    return syncDir(l.dir)
+}
+
+// listLog returns the sequence numbers of the snapshots and of the
+// segments in dir, in increasing order.
+func listLog(dir string) (snaps, segs []uint64, err error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, nil, err
+    }
+    for _, e := range entries {
+        name := e.Name()
+        ext := filepath.Ext(name)
+        if ext != logSegmentExt && ext != logSnapshotExt {
+            continue
+        }
+        seq, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+        if err != nil || len(name) != 16+len(ext) {
+            continue
+        }
+        if ext == logSegmentExt {
+            segs = append(segs, seq)
+        } else {
+            snaps = append(snaps, seq)
+        }
+    }
+    sort.Slice(snaps, func(i, j int) bool { return snaps[i] < snaps[j] })
+    sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+    return snaps, segs, nil
+}
+
+// readSegment appends the operations of the segment at path to ops. A
+// torn record at the end of the last segment is cut off the file.
+func readSegment[K comparable, V any](path string, codec Codec, ops []logOp[K, V], last bool) ([]logOp[K, V], error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return ops, err
+    }
+    corrupt := func(off int, format string, args ...interface{}) error {
+        return &LogError{Path: path, Offset: int64(off), Err: fmt.Errorf("%w: "+format, append([]interface{}{ErrLogCorrupt}, args...)...)}
+    }
+
+    if len(b) < logHeaderSize || string(b[:8]) != logMagic ||
+        crc32.Checksum(b[:12], castagnoli) != binary.LittleEndian.Uint32(b[12:]) {
+        if last {
+            // The segment was being created
+            return ops, os.Remove(path)
+        }
+        return ops, corrupt(0, "bad header")
+    }
+    if v := binary.LittleEndian.Uint16(b[8:]); v != logVersion {
+        return ops, &LogError{Path: path, Err: fmt.Errorf("%w %d", ErrSnapshotVersion, v)}
+    }
+
+    off := logHeaderSize
+    for off < len(b) {
+        if len(b)-off < 9 {
+            break
+        }
+        n := int(binary.LittleEndian.Uint32(b[off:]))
+        if n < 1 || n > len(b)-off-8 {
+            break
+        }
+        payload := b[off+8 : off+8+n]
+        if crc32.Checksum(payload, castagnoli) != binary.LittleEndian.Uint32(b[off+4:]) {
+            break
+        }
+        op := logOp[K, V]{op: payload[0]}
+        switch op.op {
+        case logSet, logUpdate, logRemove:
+            if err := codec.NewDecoder(bytes.NewReader(payload[1:])).Decode(&op.rec); err != nil {
+                return ops, corrupt(off, "%v", err)
+            }
+        case logPurge:
+        default:
+            return ops, corrupt(off, "bad operation %d", op.op)
+        }
+        ops = append(ops, op)
+        off += 8 + n
+    }
+    if off < len(b) {
+        if !last {
+            return ops, corrupt(off, "bad record")
+        }
+        if err := os.Truncate(path, int64(off)); err != nil {
+            return ops, err
+        }
+    }
+    return ops, nil
+}
+
+// replay applies op to the cache. c.lock must be held.
+func (c *PolicyCache[K, V]) replay(op *logOp[K, V], now int64) {
+    rec := &op.rec
+    switch op.op {
+    case logSet:
+        if rec.expired(now) {
+            c.remove(rec.Key, EvictExpired)
+            return
+        }
+        if ent, ok := c.entries.peekEntry(rec.Key); ok {
+            c.entries.update(ent, rec.Value, rec.ttl(), rec.Cost)
+        } else {
+            c.entries.put(newEntry(rec.Key, rec.Value, rec.ttl(), rec.Cost))
+        }
+        c.policy.Insert(rec.Key, rec.Cost)
+        c.makeRoom()
+    case logUpdate:
+        if rec.expired(now) {
+            c.remove(rec.Key, EvictExpired)
+            return
+        }
+        ent, ok := c.entries.peekEntry(rec.Key)
+        if !ok {
+            return
+        }
+        ent.value = rec.Value
+        ent.setTTL(rec.ttl())
+        c.entries.expiry.update(ent, rec.Expiration)
+        if rec.Cost != ent.cost {
+            // The policy only learns about the new cost, the entry keeps its place otherwise
+            c.entries.cost += rec.Cost - ent.cost
+            ent.cost = rec.Cost
+            c.policy.Insert(rec.Key, rec.Cost)
+            c.makeRoom()
+        }
+    case logRemove:
+        c.remove(rec.Key, EvictRemoved)
+    case logPurge:
+        c.entries.Purge()
+        c.policy.Purge()
+    }
+}
+
+// readLog reads the latest snapshot of dir and the operations logged
+// since, returning the sequence numbers of the snapshot and of the last
+// segment, 0 if none.
+func readLog[K comparable, V any](dir string, codec Codec) (recs []snapshotRecord[K, V], ops []logOp[K, V], base, last uint64, err error) {
+    snaps, segs, err := listLog(dir)
+    if err != nil {
+        return nil, nil, 0, 0, err
+    }
+    if len(snaps) > 0 {
+        base = snaps[len(snaps)-1]
+        f, err := os.Open(filepath.Join(dir, fmt.Sprintf("%016d%s", base, logSnapshotExt)))
+        if err != nil {
+            return nil, nil, 0, 0, err
+        }
+        _, recs, err = readSnapshot[K, V](f, codec, snapshotLRU, 1)
+        f.Close()
+        if err != nil {
+            return nil, nil, 0, 0, err
+        }
+    }
+    for i, seq := range segs {
+        if seq < base {
+            continue
+        }
+        path := filepath.Join(dir, fmt.Sprintf("%016d%s", seq, logSegmentExt))
+        if ops, err = readSegment(path, codec, ops, i == len(segs)-1); err != nil {
+            return nil, nil, 0, 0, err
+        }
+        last = seq
+    }
+    return recs, ops, base, last, nil
+}
+
+// OpenLog makes the cache durable with an operation log in dir, created
+// if needed. The entries of the cache are replaced by those of the latest
+// snapshot of the log and the operations logged since, then every write
+// is appended to the log: Add and its variants, Touch, SetExpiration,
+// refreshes, Remove, RemoveOldest, Purge and Restore. Reads are not
+// logged, so the order of the entries is only saved by compactions.
+// The eviction callback is called for the entries purged, and for those
+// the replay replaces, removes or evicts. Nothing is changed if the log
+// cannot be read. The codec set with SetSnapshotCodec beforehand is used
+// for the log too.
+func (c *Cache[K, V]) OpenLog(dir string, opts LogOptions) error {
+    if opts.SyncInterval <= 0 {
+        opts.SyncInterval = defaultSyncInterval
+    }
+    if opts.SegmentSize <= 0 {
+        opts.SegmentSize = defaultSegmentSize
+    }
+    c.lock.RLock()
+    codec, open := c.codec, c.log != nil
+    c.lock.RUnlock()
+    if open {
+        return errors.New("Log already open")
+    }
+    if codec == nil {
+        codec = GobCodec
+    }
+
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return err
+    }
+    recs, ops, base, last, err := readLog[K, V](dir, codec)
+    if err != nil {
+        return err
+    }
+    // Writes go to a new segment, after those of the snapshot
+    l := &opLog[K, V]{dir: dir, opts: opts, codec: codec, seq: last, base: base}
+    if base > 0 && l.seq < base {
+        l.seq = base - 1
+    }
+    if err := l.rotate(); err != nil {
+        return err
+    }
+
+    c.writeLock()
+    if c.log != nil {
+        c.lock.Unlock()
+        l.close()
+        os.Remove(l.path(l.seq, logSegmentExt))
+        return errors.New("Log already open")
+    }
+    c.restore(snapshotHeader{Policy: snapshotLRU}, recs)
+    now := time.Now().UnixNano()
+    for i := range ops {
+        c.replay(&ops[i], now)
+    }
+    c.log = l
+    c.lock.Unlock()
+
+    if opts.Sync == SyncInterval {
+        l.syncer = startJanitor(opts.SyncInterval, l.sync)
+    }
+    if opts.CompactInterval > 0 {
+        l.compactor = startJanitor(opts.CompactInterval, func() {
+            if !l.pending() {
+                return
+            }
+            if err := c.Compact(); err != nil && err != ErrLogClosed {
+                l.fail(err)
+            }
+        })
+    }
+    return nil
+}
+
+// Compact folds the segments of the operation log into a new snapshot of
+// the cache and removes them. Writes go on while the snapshot is written.
+func (c *Cache[K, V]) Compact() error {
+    c.lock.RLock()
+    l := c.log
+    c.lock.RUnlock()
+    if l == nil {
+        return ErrLogClosed
+    }
+    l.compactMu.Lock()
+    defer l.compactMu.Unlock()
+
+    c.writeLock()
+    if c.log != l {
+        c.lock.Unlock()
+        return ErrLogClosed
+    }
+    l.mu.Lock()
+    err := l.err
+    if err == nil {
+        err = l.rotate()
+        l.err = err
+    }
+    seq := l.seq
+    l.mu.Unlock()
+    h, recs, _ := c.records()
+    c.lock.Unlock()
+    if err != nil {
+        return err
+    }
+
+    err = writeFileAtomic(l.path(seq, logSnapshotExt), func(w io.Writer) error {
+        return writeSnapshot(w, l.codec, h, recs)
+    })
+    if err != nil {
+        return err
+    }
+    l.mu.Lock()
+    l.base = seq
+    l.mu.Unlock()
+    return l.removeBefore(seq)
+}
+
+// CloseLog stops logging the writes of the cache, syncing and closing the
+// operation log. Returns the first error met while writing the log.
+func (c *Cache[K, V]) CloseLog() error {
+    c.writeLock()
+    l := c.log
+    c.log = nil
+    c.lock.Unlock()
+    if l == nil {
+        return nil
+    }
+    l.syncer.Stop()
+    l.compactor.Stop()
+    l.compactMu.Lock()
+    defer l.compactMu.Unlock()
+    return l.close()
+}
+
+// logSet logs the entry of key after a write, or its removal if the
+// write left it out of the cache. c.lock must be held.
+func (c *PolicyCache[K, V]) logSet(key K) {
+    c.logEntry(logSet, key)
+}
+
+// logUpdate logs the entry of key after it was changed in place. c.lock
+// must be held.
+func (c *PolicyCache[K, V]) logUpdate(key K) {
+    c.logEntry(logUpdate, key)
+}
+
+func (c *PolicyCache[K, V]) logEntry(op uint8, key K) {
+    if c.log == nil {
+        return
+    }
+    if ent, ok := c.entries.peekEntry(key); ok {
+        rec := entryRecord(0, ent)
+        c.log.append(op, &rec)
+        return
+    }
+    c.log.append(logRemove, &snapshotRecord[K, V]{Key: key})
+}
+
+// logRemove logs the removal of key. c.lock must be held.
+func (c *PolicyCache[K, V]) logRemove(key K) {
+    if c.log != nil {
+        c.log.append(logRemove, &snapshotRecord[K, V]{Key: key})
+    }
+}
+
+// logPurge logs the removal of every key, followed by the entries left,
+// if any, in the order of the policy. c.lock must be held.
+func (c *PolicyCache[K, V]) logPurge() {
+    if c.log == nil {
+        return
+    }
+    c.log.append(logPurge, nil)
+    for _, key := range c.policy.Keys() {
+        if ent, ok := c.entries.peekEntry(key); ok {
+            rec := entryRecord(0, ent)
+            c.log.append(logSet, &rec)
+        }
+    }
+}
@@ -0,0 +1,269 @@
+package go_lru
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// logFiles returns the names of the files of the log in dir.
+func logFiles(t *testing.T, dir string) []string {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    var names []string
+    for _, e := range entries {
+        names = append(names, e.Name())
+    }
+    return names
+}
+
+// openLogged creates a cache of the given size with a log in dir.
+func openLogged(t *testing.T, dir string, size int, opts LogOptions) *Cache[string, int] {
+    l, err := NewOf[string, int](size, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := l.OpenLog(dir, opts); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    return l
+}
+
+func TestCache_Log(t *testing.T) {
+    dir := t.TempDir()
+    l := openLogged(t, dir, 4, LogOptions{})
+    l.Add("a", 1)
+    l.AddWithExpire("b", 2, time.Hour)
+    l.AddWithExpire("c", 3, 20*time.Millisecond)
+    l.Add("d", 4)
+    l.Add("a", 10)
+    l.Remove("d")
+    l.Add("e", 5)
+    l.Touch("b", NoExpiration)
+    if err := l.OpenLog(dir, LogOptions{}); err == nil {
+        t.Fatalf("should not open the log twice")
+    }
+    if err := l.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Writes once the log is closed are not logged
+    l.Add("f", 6)
+
+    r := openLogged(t, dir, 4, LogOptions{})
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []string{"b", "c", "a", "e"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+    if v, _ := r.Peek("a"); v != 10 {
+        t.Fatalf("bad value: %v", v)
+    }
+    if d, ok := r.TTL("b"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    want, _ := l.entries.peekEntry("c")
+    if got, _ := r.entries.peekEntry("c"); got.Expiration != want.Expiration {
+        t.Fatalf("bad expiration: %v want %v", got.Expiration, want.Expiration)
+    }
+    r.Purge()
+    r.Add("g", 7)
+    r.RemoveOldest()
+    r.Add("h", 8)
+    if err := r.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Entries that expired in the meantime are skipped
+    time.Sleep(30 * time.Millisecond)
+    r = openLogged(t, dir, 4, LogOptions{})
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []string{"h"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+    if err := r.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if len(logFiles(t, dir)) != 3 {
+        t.Fatalf("each opening should start a segment: %v", logFiles(t, dir))
+    }
+}
+
+func TestCache_LogTorn(t *testing.T) {
+    dir := t.TempDir()
+    l := openLogged(t, dir, 8, LogOptions{Sync: SyncNever})
+    l.Add("a", 1)
+    l.Add("b", 2)
+    l.Add("c", 3)
+    if err := l.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    path := filepath.Join(dir, logFiles(t, dir)[0])
+    b, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // A record torn by a crash is dropped, and cut off the segment
+    if err := os.WriteFile(path, b[:len(b)-3], 0o644); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    r := openLogged(t, dir, 8, LogOptions{})
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+    r.Add("d", 4)
+    if err := r.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    r = openLogged(t, dir, 8, LogOptions{})
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []string{"a", "b", "d"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+    if err := r.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Corruption before the last segment fails the opening, changing
+    // nothing
+    b, err = os.ReadFile(path)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    b[len(b)-2] ^= 0x40
+    if err := os.WriteFile(path, b, 0o644); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    c, err := NewOf[string, int](8, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    c.Add("z", 26)
+    err = c.OpenLog(dir, LogOptions{})
+    var lerr *LogError
+    if !errors.As(err, &lerr) || !errors.Is(err, ErrLogCorrupt) || lerr.Path != path {
+        t.Fatalf("bad error: %v", err)
+    }
+    if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"z"}) {
+        t.Fatalf("should be left unchanged: %v", keys)
+    }
+    if err := c.Compact(); err != ErrLogClosed {
+        t.Fatalf("bad error: %v", err)
+    }
+}
+
+func TestCache_LogCompact(t *testing.T) {
+    dir := t.TempDir()
+    l := openLogged(t, dir, 64, LogOptions{SegmentSize: 256})
+    for i := 0; i < 100; i++ {
+        l.Add(fmt.Sprint(i%50), i)
+    }
+    if segs := len(logFiles(t, dir)); segs < 3 {
+        t.Fatalf("should have rolled segments: %v", segs)
+    }
+    l.Get("0")
+    if err := l.Compact(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if files := logFiles(t, dir); len(files) != 2 || filepath.Ext(files[0]) != ".log" || filepath.Ext(files[1]) != ".snap" {
+        t.Fatalf("should keep the snapshot and the current segment: %v", files)
+    }
+    l.Remove("1")
+    l.Add("x", -1)
+    if err := l.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // The snapshot keeps the order of the entries, unlike the log
+    r := openLogged(t, dir, 64, LogOptions{})
+    if !reflect.DeepEqual(r.Keys(), l.Keys()) {
+        t.Fatalf("bad keys: %v want %v", r.Keys(), l.Keys())
+    }
+    for _, k := range l.Keys() {
+        if v1, _ := l.Peek(k); true {
+            if v2, _ := r.Peek(k); v1 != v2 {
+                t.Fatalf("bad value for %s: %v want %v", k, v2, v1)
+            }
+        }
+    }
+    if err := r.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+}
+
+func TestCache_LogBackground(t *testing.T) {
+    dir := t.TempDir()
+    l := openLogged(t, dir, 16, LogOptions{
+        Sync:            SyncInterval,
+        SyncInterval:    time.Millisecond,
+        CompactInterval: 5 * time.Millisecond,
+        SegmentSize:     512,
+    })
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        for i := 0; i < 2000; i++ {
+            l.Add(fmt.Sprint(i%32), i)
+            if i%7 == 0 {
+                l.Remove(fmt.Sprint(i % 5))
+            }
+        }
+    }()
+    <-done
+    time.Sleep(20 * time.Millisecond)
+    if err := l.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if files := logFiles(t, dir); len(files) > 3 {
+        t.Fatalf("segments should be compacted: %v", files)
+    }
+
+    r := openLogged(t, dir, 16, LogOptions{})
+    defer r.CloseLog()
+    if r.Len() != l.Len() {
+        t.Fatalf("bad len: %v want %v", r.Len(), l.Len())
+    }
+    for _, k := range l.Keys() {
+        v1, _ := l.Peek(k)
+        if v2, ok := r.Peek(k); !ok || v1 != v2 {
+            t.Fatalf("bad value for %s: %v want %v", k, v2, v1)
+        }
+    }
+}
+
+func TestCache_LogCompactErrors(t *testing.T) {
+    dir := t.TempDir()
+    l := openLogged(t, dir, 8, LogOptions{})
+    l.Add("a", 1)
+
+    // A file that cannot be removed fails the compaction, which leaves
+    // the log running
+    stuck := filepath.Join(dir, fmt.Sprintf("%016d%s", 0, logSegmentExt))
+    if err := os.MkdirAll(filepath.Join(stuck, "x"), 0o755); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := l.Compact(); err == nil {
+        t.Fatalf("should fail to remove %s", stuck)
+    }
+    l.Add("b", 2)
+    if err := l.CloseLog(); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := os.RemoveAll(stuck); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // In the background, it stops the log and is reported on closing
+    l = openLogged(t, dir, 8, LogOptions{CompactInterval: time.Millisecond})
+    if err := os.MkdirAll(filepath.Join(stuck, "x"), 0o755); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("c", 3)
+    time.Sleep(20 * time.Millisecond)
+    if err := l.CloseLog(); err == nil {
+        t.Fatalf("should report the failed compaction")
+    }
+}
@@ -0,0 +1,535 @@
+package go_lru
+
+import (
+    "container/list"
+    "fmt"
+)
+
+var (
+    _ Policy[string] = (*LRUPolicy[string])(nil)
+    _ Policy[string] = (*TwoQueuePolicy[string])(nil)
+    _ Policy[string] = (*ARCPolicy[string])(nil)
+
+    _ policySnapshot[string] = (*LRUPolicy[string])(nil)
+    _ policySnapshot[string] = (*TwoQueuePolicy[string])(nil)
+    _ policySnapshot[string] = (*ARCPolicy[string])(nil)
+    _ policyResizer          = (*TwoQueuePolicy[string])(nil)
+    _ policyResizer          = (*ARCPolicy[string])(nil)
+)
+
+// keyList is a list of keys with their cost, newest first, used by the
+// policies in place of the BASELRU the caches keep their values in.
+type keyList[K comparable] struct {
+    ll    *list.List
+    items map[K]*list.Element
+    cost  int64 // cost is the total cost of the keys
+}
+
+// keyNode is a key of a keyList.
+type keyNode[K comparable] struct {
+    key  K
+    cost int64
+}
+
+func newKeyList[K comparable]() *keyList[K] {
+    return &keyList[K]{ll: list.New(), items: make(map[K]*list.Element)}
+}
+
+// push makes key the newest of the list with the given cost, adding it
+// if it is missing.
+func (l *keyList[K]) push(key K, cost int64) {
+    if el, ok := l.items[key]; ok {
+        n := el.Value.(*keyNode[K])
+        l.cost += cost - n.cost
+        n.cost = cost
+        l.ll.MoveToFront(el)
+        return
+    }
+    l.items[key] = l.ll.PushFront(&keyNode[K]{key: key, cost: cost})
+    l.cost += cost
+}
+
+// touch makes key the newest of the list, returning false if it is
+// missing.
+func (l *keyList[K]) touch(key K) bool {
+    el, ok := l.items[key]
+    if ok {
+        l.ll.MoveToFront(el)
+    }
+    return ok
+}
+
+// remove removes key from the list, returning its cost and whether it
+// was there.
+func (l *keyList[K]) remove(key K) (int64, bool) {
+    el, ok := l.items[key]
+    if !ok {
+        return 0, false
+    }
+    n := l.ll.Remove(el).(*keyNode[K])
+    delete(l.items, key)
+    l.cost -= n.cost
+    return n.cost, true
+}
+
+// popOldest removes the oldest key, returning it with its cost.
+func (l *keyList[K]) popOldest() (K, int64, bool) {
+    el := l.ll.Back()
+    if el == nil {
+        var empty K
+        return empty, 0, false
+    }
+    n := el.Value.(*keyNode[K])
+    l.remove(n.key)
+    return n.key, n.cost, true
+}
+
+// trim removes the oldest keys until the list costs at most max.
+func (l *keyList[K]) trim(max int64) {
+    for l.ll.Len() > 0 && l.cost > max {
+        l.popOldest()
+    }
+}
+
+func (l *keyList[K]) contains(key K) bool {
+    _, ok := l.items[key]
+    return ok
+}
+
+func (l *keyList[K]) len() int {
+    return l.ll.Len()
+}
+
+// keys returns the keys from oldest to newest.
+func (l *keyList[K]) keys() []K {
+    keys := make([]K, 0, l.ll.Len())
+    for el := l.ll.Back(); el != nil; el = el.Prev() {
+        keys = append(keys, el.Value.(*keyNode[K]).key)
+    }
+    return keys
+}
+
+func (l *keyList[K]) purge() {
+    l.ll.Init()
+    l.items = make(map[K]*list.Element)
+    l.cost = 0
+}
+
+// LRUPolicy evicts the least recently used key first. It is the policy
+// of Cache.
+type LRUPolicy[K comparable] struct {
+    keys *keyList[K]
+}
+
+// NewLRUPolicy creates a least recently used policy for a PolicyCache.
+func NewLRUPolicy[K comparable]() *LRUPolicy[K] {
+    return &LRUPolicy[K]{keys: newKeyList[K]()}
+}
+
+// Insert makes key the most recently used.
+func (p *LRUPolicy[K]) Insert(key K, cost int64) bool {
+    p.keys.push(key, cost)
+    return false
+}
+
+// Access makes key the most recently used.
+func (p *LRUPolicy[K]) Access(key K) {
+    p.keys.touch(key)
+}
+
+// Victim returns the least recently used key.
+func (p *LRUPolicy[K]) Victim() (K, bool) {
+    key, _, ok := p.keys.popOldest()
+    return key, ok
+}
+
+// Remove stops tracking key.
+func (p *LRUPolicy[K]) Remove(key K) {
+    p.keys.remove(key)
+}
+
+// Keys returns the keys from the least recently used to the most.
+func (p *LRUPolicy[K]) Keys() []K {
+    return p.keys.keys()
+}
+
+// Purge forgets every key.
+func (p *LRUPolicy[K]) Purge() {
+    p.keys.purge()
+}
+
+func (p *LRUPolicy[K]) snapshotLists() (snapshotHeader, []*keyList[K], int) {
+    return snapshotHeader{Policy: snapshotLRU}, []*keyList[K]{p.keys}, 1
+}
+
+func (p *LRUPolicy[K]) restored(h snapshotHeader) {}
+
+// TwoQueuePolicy is the policy of TwoQueueCache: keys seen once wait in
+// a recent queue, which is evicted first while it is over its share, and
+// keys seen again move to a frequent queue. Keys evicted from the recent
+// queue are remembered, and go straight to the frequent queue if they
+// are added again.
+type TwoQueuePolicy[K comparable] struct {
+    recentSize  int64   // recentSize is the share of the recent queue
+    evictSize   int64   // evictSize is the capacity of the ghost queue
+    recentRatio float64 // recentRatio is the share of the capacity given to recentSize
+    ghostRatio  float64 // ghostRatio is the share of the capacity given to evictSize
+
+    recent      *keyList[K]
+    frequent    *keyList[K]
+    recentEvict *keyList[K]
+
+    // pending is the queue of the key just inserted, nil once another
+    // call is made. The victims are chosen among the other keys.
+    pending    *keyList[K]
+    pendingKey K
+    ghost      bool // ghost is true while the pending key is still in recentEvict
+}
+
+// New2QPolicy creates a 2Q policy for a PolicyCache of the given
+// capacity, in entries or cost, using the default values for the
+// parameters.
+func New2QPolicy[K comparable](capacity int64) *TwoQueuePolicy[K] {
+    p, _ := New2QPolicyParams[K](capacity, Default2QRecentRatio, Default2QGhostEntries)
+    return p
+}
+
+// New2QPolicyParams creates a 2Q policy for a PolicyCache of the given
+// capacity, in entries or cost, using the provided parameter values.
+func New2QPolicyParams[K comparable](capacity int64, recentRatio float64, ghostRatio float64) (*TwoQueuePolicy[K], error) {
+    if recentRatio < 0.0 || recentRatio > 1.0 {
+        return nil, fmt.Errorf("invalid recent ratio")
+    }
+    if ghostRatio < 0.0 || ghostRatio > 1.0 {
+        return nil, fmt.Errorf("invalid ghost ratio")
+    }
+    recentSize, evictSize := twoQueueSizes(capacity, recentRatio, ghostRatio)
+    return &TwoQueuePolicy[K]{
+        recentSize:  recentSize,
+        evictSize:   evictSize,
+        recentRatio: recentRatio,
+        ghostRatio:  ghostRatio,
+        recent:      newKeyList[K](),
+        frequent:    newKeyList[K](),
+        recentEvict: newKeyList[K](),
+    }, nil
+}
+
+// Insert adds a new key to the recent queue, or to the frequent queue if
+// it was evicted recently. A key added again moves to the frequent
+// queue.
+func (p *TwoQueuePolicy[K]) Insert(key K, cost int64) bool {
+    p.settle()
+    if p.frequent.contains(key) {
+        p.frequent.push(key, cost)
+        return false
+    }
+    if _, ok := p.recent.remove(key); ok {
+        p.frequent.push(key, cost)
+        return false
+    }
+
+    // The ghost is only forgotten once the victims are chosen, so that
+    // it counts while trimming recentEvict
+    p.pendingKey = key
+    if p.recentEvict.contains(key) {
+        p.pending = p.frequent
+        p.ghost = true
+        p.frequent.push(key, cost)
+        return true
+    }
+    p.pending = p.recent
+    p.recent.push(key, cost)
+    return false
+}
+
+// settle ends the insert of the pending key, forgetting its ghost.
+func (p *TwoQueuePolicy[K]) settle() {
+    if p.ghost {
+        p.recentEvict.remove(p.pendingKey)
+        p.ghost = false
+    }
+    p.pending = nil
+}
+
+// Access moves key to the front of the frequent queue.
+func (p *TwoQueuePolicy[K]) Access(key K) {
+    p.settle()
+    if p.frequent.touch(key) {
+        return
+    }
+    if cost, ok := p.recent.remove(key); ok {
+        p.frequent.push(key, cost)
+    }
+}
+
+// Victim returns the oldest recent key, remembering it, if the recent
+// queue is over its share or there is nothing frequent left, and the
+// oldest frequent key otherwise. The key just inserted is never chosen.
+func (p *TwoQueuePolicy[K]) Victim() (K, bool) {
+    recentLen, frequentLen := p.recent.len(), p.frequent.len()
+    if p.pending == p.recent {
+        recentLen--
+    } else if p.pending == p.frequent {
+        frequentLen--
+    }
+
+    if recentLen > 0 && (p.recent.cost > p.recentSize || frequentLen == 0) {
+        key, cost, _ := p.recent.popOldest()
+        p.recentEvict.push(key, cost)
+        p.recentEvict.trim(p.evictSize)
+        return key, true
+    }
+    if frequentLen > 0 {
+        key, _, _ := p.frequent.popOldest()
+        return key, true
+    }
+    var empty K
+    return empty, false
+}
+
+// Remove stops tracking key, forgetting it if it was evicted recently.
+func (p *TwoQueuePolicy[K]) Remove(key K) {
+    p.settle()
+    if _, ok := p.frequent.remove(key); ok {
+        return
+    }
+    if _, ok := p.recent.remove(key); ok {
+        return
+    }
+    p.recentEvict.remove(key)
+}
+
+// Keys returns the recent keys followed by the frequent ones, each from
+// oldest to newest.
+func (p *TwoQueuePolicy[K]) Keys() []K {
+    return append(p.recent.keys(), p.frequent.keys()...)
+}
+
+// Purge forgets every key, the recently evicted ones included.
+func (p *TwoQueuePolicy[K]) Purge() {
+    p.pending = nil
+    p.ghost = false
+    p.recent.purge()
+    p.frequent.purge()
+    p.recentEvict.purge()
+}
+
+func (p *TwoQueuePolicy[K]) fillStats(s *Stats) {
+    p.settle()
+    s.TwoQueue = &TwoQueueStats{
+        Recent:      p.recent.len(),
+        Frequent:    p.frequent.len(),
+        RecentEvict: p.recentEvict.len(),
+    }
+}
+
+// resize sizes the recent and ghost queues for the new capacity, keeping
+// the original ratios.
+func (p *TwoQueuePolicy[K]) resize(capacity int64) {
+    p.settle()
+    p.recentSize, p.evictSize = twoQueueSizes(capacity, p.recentRatio, p.ghostRatio)
+}
+
+func (p *TwoQueuePolicy[K]) trimGhosts() {
+    p.recentEvict.trim(p.evictSize)
+}
+
+func (p *TwoQueuePolicy[K]) snapshotLists() (snapshotHeader, []*keyList[K], int) {
+    return snapshotHeader{Policy: snapshot2Q}, []*keyList[K]{p.recent, p.frequent, p.recentEvict}, 2
+}
+
+func (p *TwoQueuePolicy[K]) restored(h snapshotHeader) {}
+
+// ARCPolicy is the policy of ARCCache: keys seen once are kept in T1 and keys
+// seen again in T2, the keys evicted from each being remembered in B1
+// and B2. A key added again while remembered in B1 grows the target
+// size P of T1, in B2 shrinks it, and victims are taken from T1 while
+// it is over P.
+type ARCPolicy[K comparable] struct {
+    size int64 // size is the capacity of the cache, in entries or cost
+    p    int64 // p is the target size of T1
+
+    t1 *keyList[K] // t1 holds the keys seen once
+    t2 *keyList[K] // t2 holds the keys seen again
+    b1 *keyList[K] // b1 remembers the keys evicted from t1
+    b2 *keyList[K] // b2 remembers the keys evicted from t2
+
+    // pending is the list of the key just inserted, nil once another
+    // call is made. The victims are chosen among the other keys.
+    pending    *keyList[K]
+    pendingKey K
+    fromB2     bool // fromB2 is true if the pending key was remembered in b2
+}
+
+// NewARCPolicy creates an ARC policy for a PolicyCache of the given
+// capacity, in entries or cost.
+func NewARCPolicy[K comparable](capacity int64) *ARCPolicy[K] {
+    return &ARCPolicy[K]{
+        size: capacity,
+        t1:   newKeyList[K](),
+        t2:   newKeyList[K](),
+        b1:   newKeyList[K](),
+        b2:   newKeyList[K](),
+    }
+}
+
+// Insert adds a new key to T1, or to T2 if it is remembered in B1 or
+// B2, adapting P. A key added again moves to T2.
+func (p *ARCPolicy[K]) Insert(key K, cost int64) bool {
+    p.pending = nil
+    if _, ok := p.t1.remove(key); ok {
+        p.t2.push(key, cost)
+        return false
+    }
+    if p.t2.contains(key) {
+        p.t2.push(key, cost)
+        return false
+    }
+
+    p.pendingKey = key
+    p.pending = p.t2
+    p.fromB2 = false
+    if ghost, ok := p.b1.remove(key); ok {
+        // T1 is too small, increase P appropriately
+        delta := arcDelta(ghost, p.b2.cost, p.b1.cost+ghost)
+        if p.p+delta >= p.size {
+            p.p = p.size
+        } else {
+            p.p += delta
+        }
+        p.t2.push(key, cost)
+        return true
+    }
+    if ghost, ok := p.b2.remove(key); ok {
+        // T2 is too small, decrease P appropriately
+        delta := arcDelta(ghost, p.b1.cost, p.b2.cost+ghost)
+        if delta >= p.p {
+            p.p = 0
+        } else {
+            p.p -= delta
+        }
+        p.fromB2 = true
+        p.t2.push(key, cost)
+        return true
+    }
+
+    p.pending = p.t1
+    p.trimGhosts()
+    p.t1.push(key, cost)
+    return false
+}
+
+// trimGhosts keeps B1 and B2 within the sizes left to them by P.
+func (p *ARCPolicy[K]) trimGhosts() {
+    p.b1.trim(p.size - p.p)
+    p.b2.trim(p.p)
+}
+
+// Access moves key to the front of T2.
+func (p *ARCPolicy[K]) Access(key K) {
+    p.pending = nil
+    if p.t2.touch(key) {
+        return
+    }
+    if cost, ok := p.t1.remove(key); ok {
+        p.t2.push(key, cost)
+    }
+}
+
+// Victim returns the oldest key of T1 if it is over P, or of T2
+// otherwise, remembering it in B1 or B2. The key just inserted is never
+// chosen.
+func (p *ARCPolicy[K]) Victim() (K, bool) {
+    t1Len, t1Cost, t2Len := p.t1.len(), p.t1.cost, p.t2.len()
+    if p.pending == p.t1 {
+        t1Len--
+        t1Cost -= p.t1.items[p.pendingKey].Value.(*keyNode[K]).cost
+    } else if p.pending == p.t2 {
+        t2Len--
+    }
+
+    fromB2 := p.pending == p.t2 && p.fromB2
+    if t1Len > 0 && (t1Cost > p.p || (t1Cost == p.p && fromB2) || t2Len == 0) {
+        key, cost, _ := p.t1.popOldest()
+        p.b1.push(key, cost)
+        p.b1.trim(p.size)
+        if p.pending == p.t1 {
+            p.trimGhosts()
+        }
+        return key, true
+    }
+    if t2Len > 0 {
+        key, cost, _ := p.t2.popOldest()
+        p.b2.push(key, cost)
+        p.b2.trim(p.size)
+        if p.pending == p.t1 {
+            p.trimGhosts()
+        }
+        return key, true
+    }
+    var empty K
+    return empty, false
+}
+
+// Remove stops tracking key, forgetting it if it is remembered in B1 or
+// B2.
+func (p *ARCPolicy[K]) Remove(key K) {
+    if p.pending != nil && p.pendingKey == key {
+        p.pending = nil
+    }
+    for _, l := range []*keyList[K]{p.t1, p.t2, p.b1, p.b2} {
+        if _, ok := l.remove(key); ok {
+            return
+        }
+    }
+}
+
+// Keys returns the keys of T1 followed by those of T2, each from oldest
+// to newest.
+func (p *ARCPolicy[K]) Keys() []K {
+    return append(p.t1.keys(), p.t2.keys()...)
+}
+
+// Purge forgets every key, the remembered ones included. P is kept.
+func (p *ARCPolicy[K]) Purge() {
+    p.pending = nil
+    p.t1.purge()
+    p.t2.purge()
+    p.b1.purge()
+    p.b2.purge()
+}
+
+func (p *ARCPolicy[K]) fillStats(s *Stats) {
+    s.ARC = &ARCStats{
+        P:  p.p,
+        T1: p.t1.len(),
+        T2: p.t2.len(),
+        B1: p.b1.len(),
+        B2: p.b2.len(),
+    }
+}
+
+// resize clamps P to the new capacity.
+func (p *ARCPolicy[K]) resize(capacity int64) {
+    p.pending = nil
+    p.size = capacity
+    if p.p > p.size {
+        p.p = p.size
+    }
+}
+
+func (p *ARCPolicy[K]) snapshotLists() (snapshotHeader, []*keyList[K], int) {
+    return snapshotHeader{Policy: snapshotARC, P: p.p}, []*keyList[K]{p.t1, p.t2, p.b1, p.b2}, 2
+}
+
+// restored sets P to that of the snapshot, within the capacity.
+func (p *ARCPolicy[K]) restored(h snapshotHeader) {
+    p.p = h.P
+    if p.p < 0 {
+        p.p = 0
+    }
+    if p.p > p.size {
+        p.p = p.size
+    }
+}
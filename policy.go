@@ -0,0 +1,708 @@
+package go_lru
+
+import (
+    "context"
+    "errors"
+    "io"
+    "os"
+    "sync"
+    "time"
+)
+
+// Policy decides which entries a PolicyCache evicts. The cache owns the
+// values, their expiration, the locking and the eviction callbacks, and
+// tells the policy about every key it holds; the policy only orders the
+// keys, and may remember evicted ones. Policies need not be safe for
+// concurrent use, the cache only calls them under its exclusive lock.
+type Policy[K comparable] interface {
+    // Insert records that key was added with the given cost. Adding a
+    // cached key again updates its cost and counts as an access. Returns
+    // true if the policy remembered the key as recently evicted.
+    Insert(key K, cost int64) bool
+
+    // Access records a successful lookup of a cached key.
+    Access(key K)
+
+    // Victim chooses the cached key to evict next and stops tracking it.
+    // It is called after Insert until the cache is back within capacity.
+    // The cache never inserts a key costing more than its capacity, it
+    // evicts such an entry at once instead, so the key just inserted
+    // always fits alone and other keys are left to choose from. ok is
+    // false if the policy would rather leave the cache over capacity.
+    Victim() (key K, ok bool)
+
+    // Remove stops tracking a key removed from the cache, or forgets it
+    // if it is only remembered as recently evicted. Unknown keys are
+    // ignored.
+    Remove(key K)
+
+    // Keys returns the cached keys, from the first to be evicted to the
+    // last.
+    Keys() []K
+
+    // Purge forgets every key, the recently evicted ones included.
+    Purge()
+}
+
+// policyStats is implemented by the policies of this package whose lists
+// are described in Stats.
+type policyStats interface {
+    fillStats(s *Stats)
+}
+
+// policyResizer is implemented by the policies of this package whose
+// lists are sized after the capacity of the cache.
+type policyResizer interface {
+    // resize adapts the policy to a new capacity, before the cache evicts
+    // the entries that no longer fit.
+    resize(capacity int64)
+
+    // trimGhosts forgets the oldest remembered keys past the room left
+    // to them, once the cache is back within capacity.
+    trimGhosts()
+}
+
+// policySnapshot is implemented by the policies of this package, whose
+// lists are saved in snapshots along with the entries.
+type policySnapshot[K comparable] interface {
+    // snapshotLists returns the header of the snapshots of the policy and
+    // its lists in the order they are saved: the first live lists hold
+    // the cached keys, the others the remembered ones.
+    snapshotLists() (h snapshotHeader, lists []*keyList[K], live int)
+
+    // restored is called with the header of a snapshot once its lists
+    // are restored.
+    restored(h snapshotHeader)
+}
+
+// PolicyCache is a thread-safe fixed size cache whose eviction order is
+// decided by a pluggable Policy. It handles the values, expiration,
+// loading, refreshing and statistics the same way as the other caches of
+// this package, so that adding a policy only takes implementing Policy.
+type PolicyCache[K comparable, V any] struct {
+    size int64 // size is the total capacity of the cache, in entries or cost
+
+    policy  Policy[K]      // policy orders the keys for eviction
+    entries *BASELRU[K, V] // entries holds the cached values, in no particular order
+
+    lock      sync.RWMutex
+    janitor   *janitor
+    loads     loadGroup[K, V]
+    refresher refresher[K, V]
+    stats     *counters
+    reads     readBuffer[K, V]
+    codec     Codec
+    log       *opLog[K, V] // log is the operation log of a Cache, nil if not open
+}
+
+// NewPolicyCache creates a cache of the given size with string keys and
+// untyped values, evicting in the order chosen by policy.
+func NewPolicyCache(size int, policy Policy[string], defaultExpiration time.Duration) (*PolicyCache[string, interface{}], error) {
+    return NewPolicyCacheOf[string, interface{}](size, policy, defaultExpiration)
+}
+
+// NewPolicyCacheWithCost creates a cache with string keys and untyped
+// values that keeps the total cost of its entries within maxCost,
+// evicting in the order chosen by policy.
+func NewPolicyCacheWithCost(maxCost int64, policy Policy[string], defaultExpiration time.Duration, costFunc func(key string, value interface{}) int64) (*PolicyCache[string, interface{}], error) {
+    return NewPolicyCacheWithCostOf[string, interface{}](maxCost, policy, defaultExpiration, costFunc)
+}
+
+// NewPolicyCacheWithEvict creates a cache of the given size with string
+// keys, untyped values and the given eviction callback, evicting in the
+// order chosen by policy.
+func NewPolicyCacheWithEvict(size int, policy Policy[string], defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*PolicyCache[string, interface{}], error) {
+    return NewPolicyCacheWithEvictOf[string, interface{}](size, policy, defaultExpiration, onEvicted)
+}
+
+// NewPolicyCacheWithEvictReason creates a cache of the given size with
+// string keys and untyped values whose eviction callback also learns why
+// an entry left the cache, evicting in the order chosen by policy.
+func NewPolicyCacheWithEvictReason(size int, policy Policy[string], defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*PolicyCache[string, interface{}], error) {
+    return NewPolicyCacheWithEvictReasonOf[string, interface{}](size, policy, defaultExpiration, onEvicted)
+}
+
+// NewPolicyCacheOf creates a cache of the given size for any comparable
+// key type and value type, evicting in the order chosen by policy. The
+// policy should be created for the same capacity.
+func NewPolicyCacheOf[K comparable, V any](size int, policy Policy[K], defaultExpiration time.Duration) (*PolicyCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    if policy == nil {
+        return nil, errors.New("Must provide a policy")
+    }
+    return newPolicyCache[K, V](size, 0, nil, policy, nil, defaultExpiration), nil
+}
+
+// NewPolicyCacheWithEvictOf creates a cache of the given size with the
+// given eviction callback, evicting in the order chosen by policy. It is
+// called once for every entry dropped, never for keys the policy only
+// remembers.
+func NewPolicyCacheWithEvictOf[K comparable, V any](size int, policy Policy[K], defaultExpiration time.Duration, onEvicted func(key K, value V)) (*PolicyCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    if policy == nil {
+        return nil, errors.New("Must provide a policy")
+    }
+    return newPolicyCache[K, V](size, 0, nil, policy, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration), nil
+}
+
+// NewPolicyCacheWithEvictReasonOf creates a cache of the given size whose
+// eviction callback also learns why an entry left the cache, evicting in
+// the order chosen by policy.
+func NewPolicyCacheWithEvictReasonOf[K comparable, V any](size int, policy Policy[K], defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*PolicyCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    if policy == nil {
+        return nil, errors.New("Must provide a policy")
+    }
+    return newPolicyCache[K, V](size, 0, nil, policy, onEvicted, defaultExpiration), nil
+}
+
+// NewPolicyCacheWithCostOf creates a cache that keeps the total cost of
+// its entries within maxCost, evicting in the order chosen by policy,
+// which should be created for maxCost. Entries added without an explicit
+// cost are weighed by costFunc, or cost 1 if it is nil.
+func NewPolicyCacheWithCostOf[K comparable, V any](maxCost int64, policy Policy[K], defaultExpiration time.Duration, costFunc func(key K, value V) int64) (*PolicyCache[K, V], error) {
+    if maxCost <= 0 {
+        return nil, errors.New("Must provide a positive max cost")
+    }
+    if policy == nil {
+        return nil, errors.New("Must provide a policy")
+    }
+    return newPolicyCache[K, V](0, maxCost, costFunc, policy, nil, defaultExpiration), nil
+}
+
+// newPolicyCache creates a cache bounded either by size entries or by
+// maxCost total cost.
+func newPolicyCache[K comparable, V any](size int, maxCost int64, costFunc CostFunc[K, V], policy Policy[K], onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) *PolicyCache[K, V] {
+    capacity := int64(size)
+    if maxCost > 0 {
+        capacity = maxCost
+    }
+
+    // The cache enforces the capacity, the entries are unbounded
+    c := &PolicyCache[K, V]{
+        size:    capacity,
+        policy:  policy,
+        entries: newBaseLRU[K, V](0, 0, costFunc, onEvict, defaultExpiration),
+        stats:   new(counters),
+    }
+    c.entries.stats = c.stats
+    return c
+}
+
+// Get looks up a key's value from the cache. Hits are served under the
+// shared lock, the policy learns about them on the next call that takes
+// the exclusive lock.
+func (c *PolicyCache[K, V]) Get(key K) (V, bool) {
+    c.lock.RLock()
+    ent, ok := c.entries.peekEntry(key)
+    if ok && ent.deferrable() {
+        value := ent.value
+        recorded := c.reads.record(ent)
+        c.lock.RUnlock()
+        c.stats.hit()
+        if !recorded {
+            c.drainReads(ent)
+        }
+        return value, true
+    }
+    c.lock.RUnlock()
+    if !ok {
+        c.stats.miss()
+        var empty V
+        return empty, false
+    }
+    return c.getExclusive(key)
+}
+
+// getExclusive does the work of Get under the exclusive lock, for the
+// entries that a read updates.
+func (c *PolicyCache[K, V]) getExclusive(key K) (V, bool) {
+    c.writeLock()
+    defer c.lock.Unlock()
+    if ent, ok := c.entries.peekEntry(key); ok {
+        if !ent.Expired() {
+            c.policy.Access(key)
+            c.entries.slide(ent)
+            return c.hit(ent)
+        }
+        c.remove(key, EvictExpired)
+    }
+
+    // No hit
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// GetOrLoad looks up a key's value from the cache, or loads it with
+// loader and adds it with the duration the loader returns. Concurrent
+// calls for the same key share a single load; callers waiting on it
+// give up when their ctx is done, but the load carries on for the
+// others. A panic in the loader is raised again in each of them. The
+// cache is not locked while the loader runs.
+func (c *PolicyCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+    return c.loads.load(ctx, c, key, loader)
+}
+
+// writeLock takes the exclusive lock and applies the buffered reads, so
+// the caller sees the policy as left by every Get.
+func (c *PolicyCache[K, V]) writeLock() {
+    c.lock.Lock()
+    c.reads.drain(c.applyRead)
+}
+
+// drainReads applies the buffered reads and the read of ent that did
+// not fit in the buffer. The read is lost if the lock is busy.
+func (c *PolicyCache[K, V]) drainReads(ent *entry[K, V]) {
+    if c.lock.TryLock() {
+        c.reads.drain(c.applyRead)
+        c.applyRead(ent)
+        c.lock.Unlock()
+    }
+}
+
+// applyRead tells the policy about a buffered read of ent, if it is
+// still cached.
+func (c *PolicyCache[K, V]) applyRead(ent *entry[K, V]) {
+    if cur, ok := c.entries.peekEntry(ent.key); ok && cur == ent {
+        c.policy.Access(ent.key)
+    }
+}
+
+// hit returns the value of a cache hit, starting a background refresh
+// if it is past its soft TTL.
+func (c *PolicyCache[K, V]) hit(ent *entry[K, V]) (V, bool) {
+    c.stats.hit()
+    if loader, ok := c.refresher.due(ent, time.Now().UnixNano()); ok {
+        go c.refresh(ent, loader)
+    }
+    return ent.value, true
+}
+
+// refresh reloads ent, dropping the result if the entry was updated or
+// removed in the meantime.
+func (c *PolicyCache[K, V]) refresh(ent *entry[K, V], loader LoaderFunc[K, V]) {
+    value, d, err := loader(context.Background(), ent.key)
+
+    c.writeLock()
+    defer c.lock.Unlock()
+    cur, ok := c.entries.peekEntry(ent.key)
+    if !ok || cur != ent || !ent.refreshing {
+        return
+    }
+    if err != nil {
+        ent.refreshing = false
+        return
+    }
+    c.entries.refreshEntry(ent, value, d)
+    c.policy.Insert(ent.key, ent.cost)
+    c.makeRoom()
+    c.logUpdate(ent.key)
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+func (c *PolicyCache[K, V]) Add(key K, value V) bool {
+    return c.AddWithExpire(key, value, NoExpiration)
+}
+
+// AddWithExpire adds a value to the cache that expires after d.
+// Returns true if an eviction occurred.
+func (c *PolicyCache[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.entries.costOf(key, value), c.entries.ttlOf(d))
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *PolicyCache[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, cost, c.entries.ttlOf(d))
+}
+
+// AddWithSliding adds a value that expires after d, each successful Get
+// pushing its deadline d further. A positive max caps its lifetime from
+// now regardless of accesses. Returns true if an eviction occurred.
+func (c *PolicyCache[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.entries.costOf(key, value), c.entries.slidingTTL(d, max))
+}
+
+// AddWithRefresh adds a value that expires after d. Once the soft TTL
+// refresh has passed, Get keeps returning it but also reloads it in the
+// background with the loader registered by SetRefreshLoader.
+// Returns true if an eviction occurred.
+func (c *PolicyCache[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.entries.costOf(key, value), ttl{expiration: c.entries.expireAt(d), refresh: refresh})
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *PolicyCache[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.entries.costOf(key, value), deadlineTTL(deadline))
+}
+
+// SetRefreshLoader registers the loader used to refresh entries added
+// with AddWithRefresh. The duration it returns restarts the hard TTL.
+func (c *PolicyCache[K, V]) SetRefreshLoader(loader LoaderFunc[K, V]) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.refresher.loader = loader
+}
+
+// ContainsOrAdd checks if a key is in the cache without telling the
+// policy, and if not, adds the value. Returns whether found and whether
+// an eviction occurred.
+func (c *PolicyCache[K, V]) ContainsOrAdd(key K, value V) (ok, evict bool) {
+    c.writeLock()
+    defer c.lock.Unlock()
+    if c.entries.Contains(key) {
+        return true, false
+    }
+    return false, c.add(key, value, c.entries.costOf(key, value), c.entries.ttlOf(NoExpiration))
+}
+
+// add does the work of AddWithCost, the caller must hold the lock.
+func (c *PolicyCache[K, V]) add(key K, value V, cost int64, t ttl) bool {
+    // An entry costing more than the capacity is evicted at once, along
+    // with the previous value of the key, without telling the policy
+    if cost > c.size {
+        if c.remove(key, EvictReplaced) {
+            c.logRemove(key)
+        }
+        c.entries.notify(newEntry(key, value, t, cost), EvictCapacity)
+        return true
+    }
+
+    if ent, ok := c.entries.peekEntry(key); ok {
+        // Update the value in place, which the policy counts as an access
+        c.entries.update(ent, value, t, cost)
+        c.policy.Insert(key, cost)
+    } else {
+        c.stats.add()
+        c.entries.put(newEntry(key, value, t, cost))
+        if c.policy.Insert(key, cost) {
+            c.stats.ghostHit()
+        }
+    }
+    evicted := c.makeRoom()
+    c.logSet(key)
+    return evicted
+}
+
+// makeRoom evicts the victims chosen by the policy until the cache is
+// within capacity, or the policy has none. Returns true if an entry was
+// evicted.
+func (c *PolicyCache[K, V]) makeRoom() bool {
+    evicted := false
+    for c.entries.Cost() > c.size {
+        key, ok := c.policy.Victim()
+        if !ok {
+            break
+        }
+        if c.entries.removeKey(key, EvictCapacity) {
+            evicted = true
+        }
+    }
+    return evicted
+}
+
+// remove removes the key's entry for the given reason, and stops the
+// policy tracking it. Returns true if the key was contained.
+func (c *PolicyCache[K, V]) remove(key K, reason EvictReason) bool {
+    c.policy.Remove(key)
+    return c.entries.removeKey(key, reason)
+}
+
+// Resize changes the capacity of the cache, in entries or in cost if it
+// was created with a max cost. The policies of this package resize their
+// lists with it, others are left as they are. The entries that no longer
+// fit are evicted in the order of the policy, through the eviction
+// callback. Returns how many were evicted. A non-positive size is
+// ignored.
+func (c *PolicyCache[K, V]) Resize(size int) (evicted int) {
+    if size <= 0 {
+        return 0
+    }
+    c.writeLock()
+    defer c.lock.Unlock()
+
+    c.size = int64(size)
+    r, ok := c.policy.(policyResizer)
+    if ok {
+        r.resize(c.size)
+    }
+    before := c.entries.Len()
+    c.makeRoom()
+    if ok {
+        r.trimGhosts()
+    }
+    return before - c.entries.Len()
+}
+
+// SetSnapshotCodec sets the codec used by Snapshot and Restore, GobCodec
+// if nil.
+func (c *PolicyCache[K, V]) SetSnapshotCodec(codec Codec) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.codec = codec
+}
+
+// Snapshot writes the live entries of the cache to w with their absolute
+// expiration, keeping the list of the policy each one is in and its place
+// there, along with the keys the policy remembers and its adaptive state.
+// Only the policies of this package can be saved, ErrSnapshotUnsupported
+// is returned for others. The cache is not locked while w is written.
+func (c *PolicyCache[K, V]) Snapshot(w io.Writer) error {
+    c.writeLock()
+    h, recs, err := c.records()
+    codec := c.codec
+    c.lock.Unlock()
+    if err != nil {
+        return err
+    }
+    return writeSnapshot(w, codec, h, recs)
+}
+
+// Restore replaces the entries of the cache with those of a snapshot
+// written by Snapshot, skipping the entries that expired since. The
+// current entries are purged and the restored ones that do not fit are
+// evicted, through the eviction callback. The cache is left unchanged if
+// the snapshot cannot be read.
+func (c *PolicyCache[K, V]) Restore(r io.Reader) error {
+    p, ok := c.policy.(policySnapshot[K])
+    if !ok {
+        return ErrSnapshotUnsupported
+    }
+    c.lock.RLock()
+    codec := c.codec
+    h, lists, _ := p.snapshotLists()
+    c.lock.RUnlock()
+    h, recs, err := readSnapshot[K, V](r, codec, h.Policy, len(lists))
+    if err != nil {
+        return err
+    }
+
+    c.writeLock()
+    defer c.lock.Unlock()
+    c.restore(h, recs)
+    c.logPurge()
+    return nil
+}
+
+// SnapshotFile writes a snapshot of the cache to the file at path. The
+// snapshot goes to a temporary file that is synced and renamed over path,
+// which never holds a partial snapshot.
+func (c *PolicyCache[K, V]) SnapshotFile(path string) error {
+    return writeFileAtomic(path, c.Snapshot)
+}
+
+// RestoreFile restores the cache from the snapshot file at path, written by
+// SnapshotFile.
+func (c *PolicyCache[K, V]) RestoreFile(path string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    return c.Restore(f)
+}
+
+// records returns the header and the records of a snapshot of the cache.
+// c.lock must be held.
+func (c *PolicyCache[K, V]) records() (snapshotHeader, []snapshotRecord[K, V], error) {
+    p, ok := c.policy.(policySnapshot[K])
+    if !ok {
+        return snapshotHeader{}, nil, ErrSnapshotUnsupported
+    }
+    h, lists, live := p.snapshotLists()
+    var recs []snapshotRecord[K, V]
+    for i, l := range lists {
+        recs = snapshotRecords(recs, i, l, c.entries, i >= live)
+    }
+    return h, recs, nil
+}
+
+// restore replaces the entries of the cache and the lists of its policy
+// with the records of a snapshot, then evicts the entries that do not
+// fit. The policy must support snapshots, and c.lock be held.
+func (c *PolicyCache[K, V]) restore(h snapshotHeader, recs []snapshotRecord[K, V]) {
+    p := c.policy.(policySnapshot[K])
+    c.entries.Purge()
+    c.policy.Purge()
+    _, lists, live := p.snapshotLists()
+    restoreRecords(recs, c.entries, lists, live)
+    p.restored(h)
+    c.makeRoom()
+    if r, ok := c.policy.(policyResizer); ok {
+        r.trimGhosts()
+    }
+}
+
+// Stats returns a snapshot of the statistics of the cache. The policies
+// of this package also describe their lists.
+func (c *PolicyCache[K, V]) Stats() Stats {
+    s := c.stats.snapshot()
+    c.writeLock()
+    defer c.lock.Unlock()
+    s.Len = c.entries.Len()
+    s.Cost = c.entries.Cost()
+    if p, ok := c.policy.(policyStats); ok {
+        p.fillStats(&s)
+    }
+    return s
+}
+
+// ResetStats zeroes the counters of the cache, to report statistics per
+// time window.
+func (c *PolicyCache[K, V]) ResetStats() {
+    c.stats.reset()
+}
+
+// RemoveExpired removes every expired entry from the cache in the order
+// they expired, returning how many were removed.
+func (c *PolicyCache[K, V]) RemoveExpired() int {
+    c.writeLock()
+    defer c.lock.Unlock()
+    now := time.Now().UnixNano()
+    removed := 0
+    for {
+        kv, ok := c.entries.expiry.peek()
+        if !ok || kv.Expiration >= now {
+            return removed
+        }
+        c.remove(kv.key, EvictExpired)
+        removed++
+    }
+}
+
+// StartJanitor starts a background goroutine that removes expired
+// entries every interval, replacing any janitor started before. Call
+// Close to stop it. A non-positive interval is ignored.
+func (c *PolicyCache[K, V]) StartJanitor(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    j := startJanitor(interval, func() { c.RemoveExpired() })
+    c.lock.Lock()
+    old := c.janitor
+    c.janitor = j
+    c.lock.Unlock()
+    old.Stop()
+}
+
+// Close stops the janitor, if any. The cache remains usable.
+func (c *PolicyCache[K, V]) Close() {
+    c.lock.Lock()
+    j := c.janitor
+    c.janitor = nil
+    c.lock.Unlock()
+    j.Stop()
+}
+
+// Len returns the number of cached entries
+func (c *PolicyCache[K, V]) Len() int {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.entries.Len()
+}
+
+// Cost returns the total cost of the cached entries
+func (c *PolicyCache[K, V]) Cost() int64 {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.entries.Cost()
+}
+
+// Keys returns all the cached keys, from the first to be evicted to the
+// last as ordered by the policy.
+func (c *PolicyCache[K, V]) Keys() []K {
+    c.writeLock()
+    defer c.lock.Unlock()
+    return c.policy.Keys()
+}
+
+// Remove is used to purge a key from the cache, returning if the key
+// was contained. Forgetting a key the policy only remembers does not
+// count as containing it.
+func (c *PolicyCache[K, V]) Remove(key K) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    if !c.remove(key, EvictRemoved) {
+        return false
+    }
+    c.logRemove(key)
+    return true
+}
+
+// Purge is used to clear the cache, the policy forgetting the evicted
+// keys too.
+func (c *PolicyCache[K, V]) Purge() {
+    c.writeLock()
+    defer c.lock.Unlock()
+    c.entries.Purge()
+    c.policy.Purge()
+    c.logPurge()
+}
+
+// Contains is used to check if the cache contains a key without telling
+// the policy.
+func (c *PolicyCache[K, V]) Contains(key K) bool {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.entries.Contains(key)
+}
+
+// Peek is used to inspect the cache value of a key without telling the
+// policy.
+func (c *PolicyCache[K, V]) Peek(key K) (V, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.entries.Peek(key)
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *PolicyCache[K, V]) TTL(key K) (time.Duration, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.entries.TTL(key)
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration,
+// without updating its value or telling the policy. Returns false if the
+// key is missing or stale.
+func (c *PolicyCache[K, V]) Touch(key K, d time.Duration) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    if !c.entries.Touch(key, d) {
+        return false
+    }
+    c.logUpdate(key)
+    return true
+}
+
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time, without updating its value or telling the policy. Returns
+// false if the key is missing or stale.
+func (c *PolicyCache[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    c.writeLock()
+    defer c.lock.Unlock()
+    if !c.entries.SetExpiration(key, deadline) {
+        return false
+    }
+    c.logUpdate(key)
+    return true
+}
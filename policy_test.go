@@ -0,0 +1,317 @@
+package go_lru
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "math/rand"
+    "reflect"
+    "sort"
+    "testing"
+    "time"
+)
+
+// fifoPolicy evicts in insertion order, ignoring accesses, as a policy
+// written outside of the package would.
+type fifoPolicy struct {
+    keys []string
+}
+
+func (p *fifoPolicy) Insert(key string, cost int64) bool {
+    for _, k := range p.keys {
+        if k == key {
+            return false
+        }
+    }
+    p.keys = append(p.keys, key)
+    return false
+}
+
+func (p *fifoPolicy) Access(key string) {}
+
+func (p *fifoPolicy) Victim() (string, bool) {
+    if len(p.keys) == 0 {
+        return "", false
+    }
+    key := p.keys[0]
+    p.keys = p.keys[1:]
+    return key, true
+}
+
+func (p *fifoPolicy) Remove(key string) {
+    for i, k := range p.keys {
+        if k == key {
+            p.keys = append(p.keys[:i], p.keys[i+1:]...)
+            return
+        }
+    }
+}
+
+func (p *fifoPolicy) Keys() []string {
+    return append([]string(nil), p.keys...)
+}
+
+func (p *fifoPolicy) Purge() {
+    p.keys = nil
+}
+
+// testPolicyMatches runs the same random operations on want and on l,
+// checking they evict the same keys in the same order.
+func testPolicyMatches(t *testing.T, want interface {
+    Add(key string, value int) bool
+    Get(key string) (int, bool)
+    Remove(key string) bool
+    Keys() []string
+}, l *PolicyCache[string, int]) {
+    for i := 0; i < 50000; i++ {
+        key := fmt.Sprintf("%d", rand.Int63()%512)
+        switch rand.Int63() % 3 {
+        case 0:
+            if e1, e2 := want.Add(key, i), l.Add(key, i); e1 != e2 {
+                t.Fatalf("%d: bad evicted for %s: %v want %v", i, key, e2, e1)
+            }
+        case 1:
+            v1, ok1 := want.Get(key)
+            v2, ok2 := l.Get(key)
+            if v1 != v2 || ok1 != ok2 {
+                t.Fatalf("%d: bad get for %s: %v, %v want %v, %v", i, key, v2, ok2, v1, ok1)
+            }
+        case 2:
+            if ok1, ok2 := want.Remove(key), l.Remove(key); ok1 != ok2 {
+                t.Fatalf("%d: bad remove for %s: %v want %v", i, key, ok2, ok1)
+            }
+        }
+        if i%100 == 0 {
+            if k1, k2 := want.Keys(), l.Keys(); !reflect.DeepEqual(k1, k2) {
+                t.Fatalf("%d: bad keys: %v want %v", i, k2, k1)
+            }
+        }
+    }
+}
+
+func TestPolicyCache_LRU(t *testing.T) {
+    want, err := NewBaseLRUOf[string, int](128, nil, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l, err := NewPolicyCacheOf[string, int](128, NewLRUPolicy[string](), NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testPolicyMatches(t, want, l)
+}
+
+func TestPolicyCache(t *testing.T) {
+    if _, err := NewPolicyCacheOf[string, int](0, NewLRUPolicy[string](), NoExpiration); err == nil {
+        t.Fatalf("should reject a zero size")
+    }
+    if _, err := NewPolicyCacheOf[string, int](1, nil, NoExpiration); err == nil {
+        t.Fatalf("should reject a nil policy")
+    }
+    if _, err := New2QPolicyParams[string](8, 1.5, 0.5); err == nil {
+        t.Fatalf("should reject a bad recent ratio")
+    }
+
+    var evicted []string
+    l, err := NewPolicyCacheWithEvictReasonOf[string, int](3, &fifoPolicy{}, NoExpiration, func(k string, v int, reason EvictReason) {
+        evicted = append(evicted, fmt.Sprintf("%s:%v", k, reason))
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("a", 1)
+    l.Add("b", 2)
+    l.Add("c", 3)
+    l.Get("a")
+    if !l.Add("d", 4) {
+        t.Fatalf("should have evicted")
+    }
+    if l.Contains("a") {
+        t.Fatalf("a should be evicted despite the hit")
+    }
+    if keys := l.Keys(); !reflect.DeepEqual(keys, []string{"b", "c", "d"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+
+    l.Add("b", 20)
+    if v, ok := l.Peek("b"); !ok || v != 20 {
+        t.Fatalf("bad value: %v, %v", v, ok)
+    }
+    if !l.Remove("c") || l.Remove("c") {
+        t.Fatalf("bad remove")
+    }
+    l.Purge()
+    if l.Len() != 0 || len(l.Keys()) != 0 {
+        t.Fatalf("bad len after purge: %v", l.Len())
+    }
+    // Purge drops the entries in no particular order
+    sort.Strings(evicted[3:])
+    want := []string{"a:capacity", "b:replaced", "c:removed", "b:purged", "d:purged"}
+    if !reflect.DeepEqual(evicted, want) {
+        t.Fatalf("bad evictions: %v want %v", evicted, want)
+    }
+}
+
+func TestPolicyCache_Cost(t *testing.T) {
+    l, err := NewPolicyCacheWithCostOf[string, string](100, NewARCPolicy[string](100), NoExpiration, func(k string, v string) int64 {
+        return int64(len(v))
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.Add("a", string(make([]byte, 40)))
+    l.Add("b", string(make([]byte, 40)))
+    l.Get("a")
+    if l.Cost() != 80 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // The recent entry goes first, keeping the frequent one
+    if !l.Add("c", string(make([]byte, 30))) {
+        t.Fatalf("should have evicted")
+    }
+    if l.Contains("b") || !l.Contains("a") || l.Cost() != 70 {
+        t.Fatalf("bad state: %v cost %v", l.Keys(), l.Cost())
+    }
+
+    // Growing an entry evicts others
+    l.AddWithCost("c", "", 70, NoExpiration)
+    if l.Contains("a") || l.Cost() != 70 {
+        t.Fatalf("bad state: %v cost %v", l.Keys(), l.Cost())
+    }
+    if s := l.Stats().ARC; s == nil || s.B1 != 1 || s.B2 != 1 {
+        t.Fatalf("bad ARC stats: %+v", s)
+    }
+}
+
+// Test that an entry costing more than the capacity is evicted at once
+// whatever the policy, leaving the other entries and the policy alone
+func TestPolicyCache_Oversize(t *testing.T) {
+    policies := map[string]func() Policy[string]{
+        "lru": func() Policy[string] { return NewLRUPolicy[string]() },
+        "2q":  func() Policy[string] { return New2QPolicy[string](4) },
+        "arc": func() Policy[string] { return NewARCPolicy[string](4) },
+    }
+    for name, newPolicy := range policies {
+        t.Run(name, func(t *testing.T) {
+            var evicted []string
+            l, err := NewPolicyCacheWithEvictReasonOf[string, int](4, newPolicy(), NoExpiration, func(k string, v int, reason EvictReason) {
+                evicted = append(evicted, fmt.Sprintf("%s=%d %v", k, v, reason))
+            })
+            if err != nil {
+                t.Fatalf("err: %v", err)
+            }
+            l.Add("a", 1)
+            l.Add("b", 2)
+
+            if !l.AddWithCost("big", 3, 5, NoExpiration) {
+                t.Fatalf("big should be evicted")
+            }
+            if l.Contains("big") || l.Len() != 2 || l.Cost() != 2 {
+                t.Fatalf("bad state: %v cost %v", l.Keys(), l.Cost())
+            }
+
+            // Growing a key past the capacity drops its previous value
+            l.AddWithCost("a", 4, 5, NoExpiration)
+            if l.Contains("a") || !reflect.DeepEqual(l.Keys(), []string{"b"}) || l.Cost() != 1 {
+                t.Fatalf("bad state: %v cost %v", l.Keys(), l.Cost())
+            }
+            want := []string{
+                fmt.Sprintf("big=3 %v", EvictCapacity),
+                fmt.Sprintf("a=1 %v", EvictReplaced),
+                fmt.Sprintf("a=4 %v", EvictCapacity),
+            }
+            if !reflect.DeepEqual(evicted, want) {
+                t.Fatalf("bad evicted: %v want %v", evicted, want)
+            }
+
+            // Entries that fit are still cached
+            for _, k := range []string{"c", "d", "e"} {
+                l.Add(k, 0)
+            }
+            if l.Len() != 4 || l.Cost() != 4 {
+                t.Fatalf("bad state: %v cost %v", l.Keys(), l.Cost())
+            }
+        })
+    }
+}
+
+func TestPolicyCache_Sliding(t *testing.T) {
+    l, err := NewPolicyCacheOf[string, int](2, New2QPolicy[string](2), NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithSliding("a", 1, 40*time.Millisecond, 0)
+    for i := 0; i < 4; i++ {
+        time.Sleep(20 * time.Millisecond)
+        if _, ok := l.Get("a"); !ok {
+            t.Fatalf("%d: should be kept alive by its reads", i)
+        }
+    }
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Get("a"); ok {
+        t.Fatalf("should have expired")
+    }
+    if l.Len() != 0 || len(l.Keys()) != 0 {
+        t.Fatalf("expired entry should be dropped by the policy too")
+    }
+}
+
+func TestPolicyCache_TTL(t *testing.T) {
+    l, err := NewPolicyCacheOf[string, int](2, NewLRUPolicy[string](), time.Hour)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithExpire("a", 1, DefaultExpiration)
+    l.AddWithExpire("b", 2, time.Millisecond)
+    if d, ok := l.TTL("a"); !ok || d <= 59*time.Minute {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    if !l.Touch("a", NoExpiration) {
+        t.Fatalf("should touch a")
+    }
+    if d, ok := l.TTL("a"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    time.Sleep(5 * time.Millisecond)
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %v", n)
+    }
+    if keys := l.Keys(); !reflect.DeepEqual(keys, []string{"a"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+}
+
+func TestPolicyCache_Resize(t *testing.T) {
+    var evicted []string
+    l, err := NewPolicyCacheWithEvictOf[string, int](4, &fifoPolicy{}, NoExpiration, func(k string, v int) {
+        evicted = append(evicted, k)
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    for i := 0; i < 4; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+
+    // Shrinking evicts in the order of the policy
+    if n := l.Resize(2); n != 2 || fmt.Sprint(evicted) != "[0 1]" {
+        t.Fatalf("bad evicted: %v, %v", n, evicted)
+    }
+    if n := l.Resize(0); n != 0 || l.Len() != 2 {
+        t.Fatalf("should ignore a zero size: %v, %v", n, l.Len())
+    }
+
+    // Only the policies of the package can be saved
+    var buf bytes.Buffer
+    if err := l.Snapshot(&buf); !errors.Is(err, ErrSnapshotUnsupported) {
+        t.Fatalf("bad error: %v", err)
+    }
+    if err := l.Restore(&buf); !errors.Is(err, ErrSnapshotUnsupported) {
+        t.Fatalf("bad error: %v", err)
+    }
+}
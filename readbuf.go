@@ -0,0 +1,99 @@
+package go_lru
+
+import (
+    "cmp"
+    "math/rand/v2"
+    "slices"
+    "sync/atomic"
+)
+
+const (
+    readStripes  = 16 // readStripes is the number of rings reads are spread over
+    readRingSize = 32 // readRingSize is the capacity of a ring, a power of two
+)
+
+// readBuffer records the entries read under the shared lock, so that
+// their recency update can be applied later, in a batch, under the
+// exclusive lock. Reads are spread over striped rings to limit
+// contention and stamped so they are applied in the order they
+// happened. It is lossy: a read is dropped if its ring is contended, or
+// full until the next drain.
+type readBuffer[K comparable, V any] struct {
+    seq     atomic.Uint64 // seq stamps the reads
+    stripes [readStripes]readRing[K, V]
+    pending []readEvent[K, V] // pending collects the reads being drained
+}
+
+// readRing is a bounded ring with many producers and a single consumer,
+// which holds the exclusive lock of the cache.
+type readRing[K comparable, V any] struct {
+    head atomic.Uint64 // head is the next slot to drain
+    tail atomic.Uint64 // tail is the next slot to record into
+    buf  [readRingSize]readSlot[K, V]
+    _    [64]byte // keep the rings on separate cache lines
+}
+
+// readSlot holds a read, its entry is stored last to publish it.
+type readSlot[K comparable, V any] struct {
+    seq atomic.Uint64
+    ent atomic.Pointer[entry[K, V]]
+}
+
+// readEvent is a read taken out of its slot.
+type readEvent[K comparable, V any] struct {
+    seq uint64
+    ent *entry[K, V]
+}
+
+// record records a read of ent. It returns false if the ring was full,
+// telling the caller to drain the buffer and apply the read itself.
+func (b *readBuffer[K, V]) record(ent *entry[K, V]) bool {
+    r := &b.stripes[rand.Uint32()%readStripes]
+    tail := r.tail.Load()
+    if tail-r.head.Load() >= readRingSize {
+        return false
+    }
+    if r.tail.CompareAndSwap(tail, tail+1) {
+        slot := &r.buf[tail%readRingSize]
+        slot.seq.Store(b.seq.Add(1))
+        slot.ent.Store(ent)
+    }
+    return true
+}
+
+// drain applies every recorded read in order with apply. The caller
+// must hold the exclusive lock of the cache.
+func (b *readBuffer[K, V]) drain(apply func(ent *entry[K, V])) {
+    pending := b.pending[:0]
+    for i := range b.stripes {
+        r := &b.stripes[i]
+        head, tail := r.head.Load(), r.tail.Load()
+        for ; head != tail; head++ {
+            // The slot is still empty if its producer has not stored
+            // into it yet, pick it up on the next drain
+            slot := &r.buf[head%readRingSize]
+            ent := slot.ent.Swap(nil)
+            if ent == nil {
+                break
+            }
+            pending = append(pending, readEvent[K, V]{seq: slot.seq.Load(), ent: ent})
+        }
+        r.head.Store(head)
+    }
+
+    slices.SortFunc(pending, func(a, b readEvent[K, V]) int {
+        return cmp.Compare(a.seq, b.seq)
+    })
+    for i := range pending {
+        apply(pending[i].ent)
+        pending[i].ent = nil
+    }
+    b.pending = pending[:0]
+}
+
+// deferrable reports whether a read of the entry can be served under the
+// shared lock with its recency update deferred. Expired entries have to
+// be removed and sliding or refreshed ones updated right away.
+func (item *entry[K, V]) deferrable() bool {
+    return item.sliding == 0 && item.refreshAt == 0 && !item.Expired()
+}
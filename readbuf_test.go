@@ -0,0 +1,111 @@
+package go_lru
+
+import (
+    "fmt"
+    "sync"
+    "testing"
+)
+
+// flushReads applies the buffered reads, for the tests that inspect the
+// lists directly.
+func (c *TwoQueueCache[K, V]) flushReads() {
+    c.writeLock()
+    c.lock.Unlock()
+}
+
+// flushReads applies the buffered reads, for the tests that inspect the
+// lists directly.
+func (c *ARCCache[K, V]) flushReads() {
+    c.writeLock()
+    c.lock.Unlock()
+}
+
+// flushReads applies the buffered reads, for the tests that inspect the
+// lists directly.
+func (c *LIRSCache[K, V]) flushReads() {
+    c.writeLock()
+    c.lock.Unlock()
+}
+
+func TestReadBuffer(t *testing.T) {
+    var b readBuffer[string, int]
+    ents := make([]*entry[string, int], readStripes*readRingSize)
+    for i := range ents {
+        ents[i] = &entry[string, int]{key: fmt.Sprint(i), value: i}
+    }
+
+    // Reads are applied in the order they were recorded
+    for _, ent := range ents[:100] {
+        if !b.record(ent) {
+            t.Fatalf("should have room")
+        }
+    }
+    var applied []int
+    b.drain(func(ent *entry[string, int]) {
+        applied = append(applied, ent.value)
+    })
+    if len(applied) != 100 {
+        t.Fatalf("bad applied: %d", len(applied))
+    }
+    for i, v := range applied {
+        if v != i {
+            t.Fatalf("out of order read: %v at %v", v, i)
+        }
+    }
+
+    // A full ring refuses reads until the next drain
+    full := false
+    for _, ent := range ents {
+        if !b.record(ent) {
+            full = true
+            break
+        }
+    }
+    if !full {
+        t.Fatalf("should fill up")
+    }
+    applied = nil
+    b.drain(func(ent *entry[string, int]) {
+        applied = append(applied, ent.value)
+    })
+    if len(applied) == 0 || len(applied) > readStripes*readRingSize {
+        t.Fatalf("bad applied: %d", len(applied))
+    }
+}
+
+// Test that concurrent readers and writers agree on the values
+func TestConcurrentReads(t *testing.T) {
+    caches := map[string]Interface[int, int]{}
+    caches["LRU"], _ = NewOf[int, int](64, NoExpiration)
+    caches["2Q"], _ = New2QOf[int, int](64, NoExpiration)
+    caches["ARC"], _ = NewARCOf[int, int](64, NoExpiration)
+    caches["CAR"], _ = NewCAROf[int, int](64, NoExpiration)
+    caches["LIRS"], _ = NewLIRSOf[int, int](64, NoExpiration)
+    caches["Policy"], _ = NewPolicyCacheOf[int, int](64, NewARCPolicy[int](64), NoExpiration)
+    caches["Sieve"], _ = NewSieveOf[int, int](64, NoExpiration)
+    caches["S3FIFO"], _ = NewS3FIFOOf[int, int](64, NoExpiration)
+    for name, l := range caches {
+        t.Run(name, func(t *testing.T) {
+            var wg sync.WaitGroup
+            for g := 0; g < 8; g++ {
+                wg.Add(1)
+                go func(g int) {
+                    defer wg.Done()
+                    for i := 0; i < 2000; i++ {
+                        k := (i * (g + 1)) % 128
+                        if i%4 == 0 {
+                            l.Add(k, k*10)
+                        } else if v, ok := l.Get(k); ok && v != k*10 {
+                            t.Errorf("bad value for %d: %d", k, v)
+                            return
+                        }
+                    }
+                }(g)
+            }
+            wg.Wait()
+            if l.Len() > 64 || len(l.Keys()) != l.Len() {
+                t.Fatalf("bad len: %v keys: %v", l.Len(), len(l.Keys()))
+            }
+        })
+    }
+}
@@ -0,0 +1,17 @@
+package go_lru
+
+// refresher holds the loader used to refresh entries past their soft
+// TTL in the background. It is guarded by the cache lock.
+type refresher[K comparable, V any] struct {
+    loader LoaderFunc[K, V]
+}
+
+// due reports whether ent is past its soft TTL and should be refreshed
+// now, marking it so a single refresh runs at a time.
+func (r *refresher[K, V]) due(ent *entry[K, V], now int64) (LoaderFunc[K, V], bool) {
+    if r.loader == nil || ent.refreshAt == 0 || ent.refreshing || now <= ent.refreshAt {
+        return nil, false
+    }
+    ent.refreshing = true
+    return r.loader, true
+}
@@ -0,0 +1,158 @@
+package go_lru
+
+import (
+    "context"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// refreshCache is implemented by the caches supporting refresh-ahead.
+type refreshCache interface {
+    Interface[string, int]
+    AddWithRefresh(key string, value int, refresh time.Duration, d time.Duration) bool
+    SetRefreshLoader(loader LoaderFunc[string, int])
+}
+
+func testRefresh(t *testing.T, l refreshCache) {
+    var calls int32
+    release := make(chan struct{})
+    l.SetRefreshLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+        atomic.AddInt32(&calls, 1)
+        <-release
+        return 2, 200 * time.Millisecond, nil
+    })
+
+    l.AddWithRefresh("a", 1, 10*time.Millisecond, 100*time.Millisecond)
+    if v, ok := l.Get("a"); !ok || v != 1 {
+        t.Fatalf("bad: %v, %v", v, ok)
+    }
+    if n := atomic.LoadInt32(&calls); n != 0 {
+        t.Fatalf("fresh entry should not be refreshed: %d", n)
+    }
+
+    // Stale entries are served while a single refresh runs
+    time.Sleep(20 * time.Millisecond)
+    for i := 0; i < 10; i++ {
+        if v, ok := l.Get("a"); !ok || v != 1 {
+            t.Fatalf("bad: %v, %v", v, ok)
+        }
+    }
+    close(release)
+    time.Sleep(20 * time.Millisecond)
+    if n := atomic.LoadInt32(&calls); n != 1 {
+        t.Fatalf("loader should run once: %d", n)
+    }
+    if v, ok := l.Get("a"); !ok || v != 2 {
+        t.Fatalf("bad: %v, %v", v, ok)
+    }
+
+    // The refreshed value gets the loader's TTL
+    time.Sleep(100 * time.Millisecond)
+    if v, ok := l.Peek("a"); !ok || v != 2 {
+        t.Fatalf("refresh should restart the hard TTL: %v, %v", v, ok)
+    }
+
+    // Entries are not served past the hard TTL
+    l.AddWithRefresh("b", 1, 10*time.Millisecond, 20*time.Millisecond)
+    time.Sleep(30 * time.Millisecond)
+    if _, ok := l.Get("b"); ok {
+        t.Fatalf("should have expired")
+    }
+
+    // Updating a key drops a refresh in flight
+    block := make(chan struct{})
+    l.SetRefreshLoader(func(ctx context.Context, key string) (int, time.Duration, error) {
+        <-block
+        return 3, NoExpiration, nil
+    })
+    l.AddWithRefresh("c", 1, 10*time.Millisecond, NoExpiration)
+    time.Sleep(20 * time.Millisecond)
+    l.Get("c")
+    l.Add("c", 4)
+    close(block)
+    time.Sleep(20 * time.Millisecond)
+    if v, ok := l.Peek("c"); !ok || v != 4 {
+        t.Fatalf("stale refresh should be dropped: %v, %v", v, ok)
+    }
+}
+
+func TestLRU_Refresh(t *testing.T) {
+    l, err := NewOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func Test2Q_Refresh(t *testing.T) {
+    l, err := New2QOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func TestARC_Refresh(t *testing.T) {
+    l, err := NewARCOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func TestCAR_Refresh(t *testing.T) {
+    l, err := NewCAROf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func TestLIRS_Refresh(t *testing.T) {
+    l, err := NewLIRSOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func TestSieve_Refresh(t *testing.T) {
+    l, err := NewSieveOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func TestS3FIFO_Refresh(t *testing.T) {
+    l, err := NewS3FIFOOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func TestLFU_Refresh(t *testing.T) {
+    l, err := NewLFUOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func TestTinyLFU_Refresh(t *testing.T) {
+    l, err := NewTinyLFUOf[string, int](128, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
+
+func TestPolicyCache_Refresh(t *testing.T) {
+    l, err := NewPolicyCacheOf[string, int](128, NewARCPolicy[string](128), NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testRefresh(t, l)
+}
@@ -0,0 +1,508 @@
+package go_lru
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+const (
+    // DefaultS3FIFOSmallRatio is the ratio of the S3-FIFO cache dedicated
+    // to the small queue of new entries.
+    DefaultS3FIFOSmallRatio = 0.10
+
+    // s3fifoMaxFreq caps the hits counted on an entry, that is how many
+    // times it can go around the main queue without a new hit.
+    s3fifoMaxFreq = 3
+)
+
+// S3FIFOCache is a thread-safe fixed size S3-FIFO cache, made of three
+// FIFO queues. New entries go to a small queue, and move on to the main
+// queue only if they were hit while in it; the others are evicted and
+// their keys remembered in a ghost queue, sending them straight to the
+// main queue if added again soon. The main queue reinserts the entries
+// hit since they were last queued instead of evicting them. One-hit
+// wonders thus leave quickly, and as hits never reorder the queues, Get
+// only takes the shared lock. The size of the cache bounds the total
+// cost of its entries, one each unless added with AddWithCost.
+type S3FIFOCache[K comparable, V any] struct {
+    size      int64 // size is the capacity of the small and main queues together
+    smallSize int64 // smallSize is the target capacity of the small queue
+
+    small *BASELRU[K, V]        // small holds the new entries, oldest at the back
+    main  *BASELRU[K, V]        // main holds the entries hit while small
+    ghost *BASELRU[K, struct{}] // ghost remembers the keys evicted from small
+
+    lock      sync.RWMutex
+    janitor   *janitor
+    loads     loadGroup[K, V]
+    refresher refresher[K, V]
+    stats     *counters
+}
+
+// NewS3FIFO creates an S3-FIFO cache of the given size with string keys
+// and untyped values.
+func NewS3FIFO(size int, defaultExpiration time.Duration) (*S3FIFOCache[string, interface{}], error) {
+    return NewS3FIFOOf[string, interface{}](size, defaultExpiration)
+}
+
+// NewS3FIFOWithEvict creates an S3-FIFO cache of the given size with
+// string keys, untyped values and the given eviction callback.
+func NewS3FIFOWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*S3FIFOCache[string, interface{}], error) {
+    return NewS3FIFOWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewS3FIFOWithEvictReason creates an S3-FIFO cache of the given size
+// with string keys, untyped values and an eviction callback that also
+// learns why an entry left the cache.
+func NewS3FIFOWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*S3FIFOCache[string, interface{}], error) {
+    return NewS3FIFOWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewS3FIFOOf creates an S3-FIFO cache of the given size for any
+// comparable key type and value type.
+func NewS3FIFOOf[K comparable, V any](size int, defaultExpiration time.Duration) (*S3FIFOCache[K, V], error) {
+    return newS3FIFO[K, V](size, nil, defaultExpiration)
+}
+
+// NewS3FIFOWithEvictOf creates an S3-FIFO cache of the given size with
+// the given eviction callback. It is never called for entries moving
+// from the small queue to the main one.
+func NewS3FIFOWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*S3FIFOCache[K, V], error) {
+    return newS3FIFO[K, V](size, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration)
+}
+
+// NewS3FIFOWithEvictReasonOf creates an S3-FIFO cache of the given size
+// whose eviction callback also learns why an entry left the cache,
+// including the old value of an updated entry.
+func NewS3FIFOWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*S3FIFOCache[K, V], error) {
+    return newS3FIFO[K, V](size, onEvicted, defaultExpiration)
+}
+
+// newS3FIFO creates an S3-FIFO cache holding at most size entries.
+func newS3FIFO[K comparable, V any](size int, onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) (*S3FIFOCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+
+    // Determine the sub-sizes, the small queue holds at least one entry
+    // and the ghost queue remembers as many keys as the main one holds
+    smallSize := int(float64(size) * DefaultS3FIFOSmallRatio)
+    if smallSize < 1 {
+        smallSize = 1
+    }
+    ghostSize := size - smallSize
+    if ghostSize < 1 {
+        ghostSize = 1
+    }
+
+    // The small and main queues are unbounded, the cache moves entries
+    // between them
+    c := &S3FIFOCache[K, V]{
+        size:      int64(size),
+        smallSize: int64(smallSize),
+        small:     newBaseLRU[K, V](0, 0, nil, onEvict, defaultExpiration),
+        main:      newBaseLRU[K, V](0, 0, nil, onEvict, defaultExpiration),
+        ghost:     newBaseLRU[K, struct{}](ghostSize, 0, nil, nil, 0),
+        stats:     new(counters),
+    }
+    c.small.stats = c.stats
+    c.main.stats = c.stats
+    return c, nil
+}
+
+// Get looks up a key's value from the cache, counting a hit. Only
+// sliding, refreshed or expired entries take the exclusive lock.
+func (c *S3FIFOCache[K, V]) Get(key K) (V, bool) {
+    c.lock.RLock()
+    ent, ok := c.peekEntry(key)
+    if ok && ent.deferrable() {
+        value := ent.value
+        ent.visit(s3fifoMaxFreq)
+        c.lock.RUnlock()
+        c.stats.hit()
+        return value, true
+    }
+    c.lock.RUnlock()
+    if ok {
+        return c.getExclusive(key)
+    }
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// getExclusive does the work of Get under the exclusive lock, for the
+// entries that a read updates.
+func (c *S3FIFOCache[K, V]) getExclusive(key K) (V, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, q := range c.queues() {
+        if ent, ok := q.peekEntry(key); ok {
+            if !ent.Expired() {
+                ent.visit(s3fifoMaxFreq)
+                q.slide(ent)
+                return c.hit(ent)
+            }
+            q.removeKey(key, EvictExpired)
+        }
+    }
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// hit returns the value of a cache hit, starting a background refresh
+// if it is past its soft TTL.
+func (c *S3FIFOCache[K, V]) hit(ent *entry[K, V]) (V, bool) {
+    c.stats.hit()
+    if loader, ok := c.refresher.due(ent, time.Now().UnixNano()); ok {
+        go c.refresh(ent, loader)
+    }
+    return ent.value, true
+}
+
+// refresh reloads ent, dropping the result if the entry was updated or
+// removed in the meantime.
+func (c *S3FIFOCache[K, V]) refresh(ent *entry[K, V], loader LoaderFunc[K, V]) {
+    value, d, err := loader(context.Background(), ent.key)
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, q := range c.queues() {
+        if cur, ok := q.peekEntry(ent.key); ok {
+            if cur != ent || !ent.refreshing {
+                return
+            }
+            if err != nil {
+                ent.refreshing = false
+                return
+            }
+            q.refreshEntry(ent, value, d)
+            c.makeRoom(0)
+            return
+        }
+    }
+}
+
+// GetOrLoad looks up a key's value from the cache, or loads it with
+// loader and adds it with the duration the loader returns. Concurrent
+// calls for the same key share a single load; callers waiting on it
+// give up when their ctx is done, but the load carries on for the
+// others. A panic in the loader is raised again in each of them. The
+// cache is not locked while the loader runs.
+func (c *S3FIFOCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+    return c.loads.load(ctx, c, key, loader)
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+func (c *S3FIFOCache[K, V]) Add(key K, value V) bool {
+    return c.AddWithExpire(key, value, NoExpiration)
+}
+
+// AddWithExpire adds a value to the cache that expires after d. Updating
+// a key counts as a hit without moving it. Returns true if an eviction
+// occurred.
+func (c *S3FIFOCache[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.small.costOf(key, value), c.small.ttlOf(d))
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *S3FIFOCache[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, cost, c.small.ttlOf(d))
+}
+
+// AddWithSliding adds a value that expires after d, each successful Get
+// pushing its deadline d further. A positive max caps its lifetime from
+// now regardless of accesses. Returns true if an eviction occurred.
+func (c *S3FIFOCache[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.small.costOf(key, value), c.small.slidingTTL(d, max))
+}
+
+// AddWithRefresh adds a value that expires after d. Once the soft TTL
+// refresh has passed, Get keeps returning it but also reloads it in the
+// background with the loader registered by SetRefreshLoader.
+// Returns true if an eviction occurred.
+func (c *S3FIFOCache[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.small.costOf(key, value), ttl{expiration: c.small.expireAt(d), refresh: refresh})
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *S3FIFOCache[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.small.costOf(key, value), deadlineTTL(deadline))
+}
+
+// SetRefreshLoader registers the loader used to refresh entries added
+// with AddWithRefresh. The duration it returns restarts the hard TTL.
+func (c *S3FIFOCache[K, V]) SetRefreshLoader(loader LoaderFunc[K, V]) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.refresher.loader = loader
+}
+
+// ContainsOrAdd checks if a key is in the cache without counting a hit
+// or deleting it for being stale, and if not, adds the value. Returns
+// whether found and whether an eviction occurred.
+func (c *S3FIFOCache[K, V]) ContainsOrAdd(key K, value V) (ok, evict bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if c.small.Contains(key) || c.main.Contains(key) {
+        return true, false
+    }
+    return false, c.add(key, value, c.small.costOf(key, value), c.small.ttlOf(NoExpiration))
+}
+
+// add does the work of AddWithCost, the caller must hold the lock.
+func (c *S3FIFOCache[K, V]) add(key K, value V, cost int64, t ttl) bool {
+    // Update the value in place in whichever queue holds the key
+    for _, q := range c.queues() {
+        if ent, ok := q.peekEntry(key); ok {
+            q.update(ent, value, t, cost)
+            ent.visit(s3fifoMaxFreq)
+            return c.makeRoom(0)
+        }
+    }
+
+    // Keys evicted recently skip the small queue
+    q := c.small
+    if _, ok := c.ghost.take(key); ok {
+        c.stats.ghostHit()
+        q = c.main
+    }
+
+    // Make room before adding, so the new entry is not the victim
+    evict := c.makeRoom(cost)
+
+    c.stats.add()
+    q.put(newEntry(key, value, t, cost))
+    return evict
+}
+
+// makeRoom evicts from the small or the main queue until an entry of the
+// given cost fits. Returns true if an entry was evicted.
+func (c *S3FIFOCache[K, V]) makeRoom(cost int64) bool {
+    evicted := false
+    for c.small.Len()+c.main.Len() > 0 && c.small.Cost()+c.main.Cost()+cost > c.size {
+        if c.small.Cost() >= c.smallSize || c.main.Len() == 0 {
+            if c.evictSmall() {
+                evicted = true
+            }
+        } else if c.evictMain() {
+            evicted = true
+        }
+    }
+    return evicted
+}
+
+// evictSmall moves the oldest entry of the small queue to the main queue
+// if it was hit, or evicts it and remembers its key. Returns true if an
+// entry was evicted.
+func (c *S3FIFOCache[K, V]) evictSmall() bool {
+    ent, _ := c.small.takeOldest()
+    if atomic.LoadUint32(&ent.visits) > 0 {
+        atomic.StoreUint32(&ent.visits, 0)
+        c.main.put(ent)
+        return false
+    }
+    c.ghost.Add(ent.key, struct{}{})
+    c.small.notify(ent, EvictCapacity)
+    return true
+}
+
+// evictMain evicts the oldest entry of the main queue not hit since it
+// was last queued, queueing again the ones on the way with one hit less.
+// Returns true, as an entry is always evicted.
+func (c *S3FIFOCache[K, V]) evictMain() bool {
+    for {
+        ent, _ := c.main.takeOldest()
+        if v := atomic.LoadUint32(&ent.visits); v > 0 {
+            atomic.StoreUint32(&ent.visits, v-1)
+            c.main.put(ent)
+            continue
+        }
+        c.main.notify(ent, EvictCapacity)
+        return true
+    }
+}
+
+// queues returns the small and main queues.
+func (c *S3FIFOCache[K, V]) queues() [2]*BASELRU[K, V] {
+    return [2]*BASELRU[K, V]{c.small, c.main}
+}
+
+// peekEntry returns the key's entry regardless of its expiration.
+func (c *S3FIFOCache[K, V]) peekEntry(key K) (*entry[K, V], bool) {
+    if ent, ok := c.small.peekEntry(key); ok {
+        return ent, true
+    }
+    return c.main.peekEntry(key)
+}
+
+// RemoveExpired removes every expired entry from the cache, returning
+// how many were removed.
+func (c *S3FIFOCache[K, V]) RemoveExpired() int {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.small.RemoveExpired() + c.main.RemoveExpired()
+}
+
+// StartJanitor starts a background goroutine that removes expired
+// entries every interval, replacing any janitor started before. Call
+// Close to stop it. A non-positive interval is ignored.
+func (c *S3FIFOCache[K, V]) StartJanitor(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    j := startJanitor(interval, func() { c.RemoveExpired() })
+    c.lock.Lock()
+    old := c.janitor
+    c.janitor = j
+    c.lock.Unlock()
+    old.Stop()
+}
+
+// Close stops the janitor, if any. The cache remains usable.
+func (c *S3FIFOCache[K, V]) Close() {
+    c.lock.Lock()
+    j := c.janitor
+    c.janitor = nil
+    c.lock.Unlock()
+    j.Stop()
+}
+
+// Len returns the number of cached entries.
+func (c *S3FIFOCache[K, V]) Len() int {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.small.Len() + c.main.Len()
+}
+
+// Cost returns the total cost of the cached entries.
+func (c *S3FIFOCache[K, V]) Cost() int64 {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.small.Cost() + c.main.Cost()
+}
+
+// Keys returns all the cached keys, roughly from the first to be evicted
+// to the last: the entries not hit since they were queued before the
+// others, each group going through the small queue then the main one,
+// from oldest to newest.
+func (c *S3FIFOCache[K, V]) Keys() []K {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    keys := make([]K, 0, c.small.Len()+c.main.Len())
+    var hit []K
+    for _, q := range c.queues() {
+        for el := q.evictList.Back(); el != nil; el = el.Prev() {
+            ent := el.Value.(*entry[K, V])
+            if atomic.LoadUint32(&ent.visits) == 0 {
+                keys = append(keys, ent.key)
+            } else {
+                hit = append(hit, ent.key)
+            }
+        }
+    }
+    return append(keys, hit...)
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *S3FIFOCache[K, V]) Remove(key K) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.small.Remove(key) || c.main.Remove(key)
+}
+
+// Purge is used to completely clear the cache, forgetting the ghost keys
+// too.
+func (c *S3FIFOCache[K, V]) Purge() {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.small.Purge()
+    c.main.Purge()
+    c.ghost.Purge()
+}
+
+// Contains is used to check if the cache contains a key without counting
+// a hit.
+func (c *S3FIFOCache[K, V]) Contains(key K) bool {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.small.Contains(key) || c.main.Contains(key)
+}
+
+// Peek is used to inspect the cache value of a key without counting a
+// hit.
+func (c *S3FIFOCache[K, V]) Peek(key K) (V, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    if val, ok := c.small.Peek(key); ok {
+        return val, ok
+    }
+    return c.main.Peek(key)
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *S3FIFOCache[K, V]) TTL(key K) (time.Duration, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    if d, ok := c.small.TTL(key); ok {
+        return d, ok
+    }
+    return c.main.TTL(key)
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration,
+// without updating its value or counting a hit. Returns false if the key
+// is missing or stale.
+func (c *S3FIFOCache[K, V]) Touch(key K, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.small.Touch(key, d) || c.main.Touch(key, d)
+}
+
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time, without updating its value or counting a hit. Returns false
+// if the key is missing or stale.
+func (c *S3FIFOCache[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.small.SetExpiration(key, deadline) || c.main.SetExpiration(key, deadline)
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (c *S3FIFOCache[K, V]) Stats() Stats {
+    s := c.stats.snapshot()
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    s.S3FIFO = &S3FIFOStats{
+        Small: c.small.Len(),
+        Main:  c.main.Len(),
+        Ghost: c.ghost.Len(),
+    }
+    s.Len = s.S3FIFO.Small + s.S3FIFO.Main
+    s.Cost = c.small.Cost() + c.main.Cost()
+    return s
+}
+
+// ResetStats zeroes the counters of the cache, to report statistics per
+// time window.
+func (c *S3FIFOCache[K, V]) ResetStats() {
+    c.stats.reset()
+}
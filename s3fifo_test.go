@@ -0,0 +1,227 @@
+package go_lru
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestS3FIFO(t *testing.T) {
+    evictCounter := 0
+    onEvicted := func(k string, v interface{}) {
+        evictCounter++
+    }
+    l, err := NewS3FIFOWithEvict(128, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 256; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if evictCounter != 128 {
+        t.Fatalf("bad evict count: %v", evictCounter)
+    }
+    for i, k := range l.Keys() {
+        if v, ok := l.Get(k); !ok || v != i+128 {
+            t.Fatalf("bad key: %v", k)
+        }
+    }
+    for i := 0; i < 128; i++ {
+        if _, ok := l.Get(fmt.Sprint(i)); ok {
+            t.Fatalf("should be evicted")
+        }
+    }
+
+    l.Add("128", 1000)
+    if v, ok := l.Peek("128"); !ok || v != 1000 {
+        t.Fatalf("update not visible: %v, %v", v, ok)
+    }
+    if !l.Remove("128") || l.Contains("128") {
+        t.Fatalf("should be removed")
+    }
+    l.Purge()
+    if s := l.Stats().S3FIFO; l.Len() != 0 || s.Ghost != 0 {
+        t.Fatalf("bad len: %v, %+v", l.Len(), s)
+    }
+}
+
+// Test how entries move through the small, main and ghost queues
+func TestS3FIFO_Queues(t *testing.T) {
+    var evicted []string
+    onEvicted := func(k string, v int) {
+        evicted = append(evicted, k)
+    }
+    l, err := NewS3FIFOWithEvictOf[string, int](10, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 10; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+
+    // 0 was hit and moves to main, 1 was not and leaves a ghost
+    l.Get("0")
+    l.Add("a", 10)
+    if fmt.Sprint(evicted) != "[1]" {
+        t.Fatalf("bad evictions: %v", evicted)
+    }
+    if s := l.Stats().S3FIFO; *s != (S3FIFOStats{Small: 9, Main: 1, Ghost: 1}) {
+        t.Fatalf("bad queues: %+v", s)
+    }
+
+    // A ghost goes straight to main
+    l.Add("1", 1)
+    if s := l.Stats(); *s.S3FIFO != (S3FIFOStats{Small: 8, Main: 2, Ghost: 1}) || s.GhostHits != 1 {
+        t.Fatalf("bad stats: %+v %+v", s, s.S3FIFO)
+    }
+    if fmt.Sprint(evicted) != "[1 2]" {
+        t.Fatalf("bad evictions: %v", evicted)
+    }
+}
+
+// Test that main reinserts the entries hit since they were queued
+func TestS3FIFO_Main(t *testing.T) {
+    var evicted []string
+    onEvicted := func(k string, v int) {
+        evicted = append(evicted, k)
+    }
+    l, err := NewS3FIFOWithEvictOf[string, int](2, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Get a and b into main through the ghost queue
+    l.Add("a", 1)
+    l.Add("b", 2)
+    l.Add("c", 3)
+    l.Add("a", 1)
+    l.Get("a")
+    l.Add("b", 2)
+    if s := l.Stats().S3FIFO; s.Small != 0 || s.Main != 2 {
+        t.Fatalf("bad queues: %+v", s)
+    }
+    if fmt.Sprint(evicted) != "[a b c]" {
+        t.Fatalf("bad evictions: %v", evicted)
+    }
+
+    // a is older, but was hit
+    l.Add("d", 4)
+    if fmt.Sprint(evicted) != "[a b c b]" {
+        t.Fatalf("bad evictions: %v", evicted)
+    }
+    if keys := fmt.Sprint(l.Keys()); keys != "[d a]" {
+        t.Fatalf("bad keys: %v", keys)
+    }
+}
+
+func TestS3FIFO_Expiration(t *testing.T) {
+    reasons := make(map[string]EvictReason)
+    onEvicted := func(k string, v int, reason EvictReason) {
+        reasons[k] = reason
+    }
+    l, err := NewS3FIFOWithEvictReasonOf[string, int](4, 10*time.Millisecond, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithExpire("a", 1, DefaultExpiration)
+    l.AddWithExpire("b", 2, DefaultExpiration)
+    l.AddWithExpire("c", 3, NoExpiration)
+    l.AddWithExpire("d", 4, time.Hour)
+    time.Sleep(20 * time.Millisecond)
+
+    if l.Contains("a") {
+        t.Fatalf("a should have expired")
+    }
+    if _, ok := l.Get("a"); ok {
+        t.Fatalf("a should have expired")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if l.Len() != 2 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if reasons["a"] != EvictExpired || reasons["b"] != EvictExpired {
+        t.Fatalf("bad reasons: %v", reasons)
+    }
+
+    // Updating an entry replaces its expiration
+    l.AddWithExpire("c", 5, 10*time.Millisecond)
+    time.Sleep(20 * time.Millisecond)
+    if l.Contains("c") || reasons["c"] != EvictReplaced {
+        t.Fatalf("c should have expired: %v", reasons)
+    }
+}
+
+func TestS3FIFO_Cost(t *testing.T) {
+    l, err := NewS3FIFOOf[string, int](10, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithCost("a", 1, 4, NoExpiration)
+    l.AddWithCost("b", 2, 4, NoExpiration)
+    l.Get("a")
+
+    // a moves to the main queue, b is evicted to make room
+    if !l.AddWithCost("c", 3, 4, NoExpiration) {
+        t.Fatalf("should have an eviction")
+    }
+    if l.Contains("b") || !l.main.Contains("a") || !l.small.Contains("c") {
+        t.Fatalf("b should have been evicted: %v", l.Keys())
+    }
+    if l.Cost() != 8 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // b comes back to the main queue, with a new cost
+    if l.AddWithCost("b", 2, 2, NoExpiration) || !l.main.Contains("b") {
+        t.Fatalf("b should be added to main")
+    }
+    if l.Cost() != 10 || l.Stats().Cost != 10 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+}
+
+func TestS3FIFO_TTL(t *testing.T) {
+    l, err := NewS3FIFOOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithExpire("a", 1, 10*time.Millisecond)
+    l.Add("b", 2)
+    if !l.Touch("a", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("a"); !ok || d <= 59*time.Second {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    l.AddWithDeadline("c", 3, time.Now().Add(time.Hour))
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("b"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if d, ok := l.TTL("c"); !ok || d <= 59*time.Minute {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Sliding entries are pushed forward by every hit
+    l.AddWithSliding("d", 4, 50*time.Millisecond, NoExpiration)
+    for i := 0; i < 3; i++ {
+        time.Sleep(30 * time.Millisecond)
+        if _, ok := l.Get("d"); !ok {
+            t.Fatalf("sliding entry should still be cached")
+        }
+    }
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Peek("d"); ok {
+        t.Fatalf("sliding entry should have expired")
+    }
+}
@@ -0,0 +1,281 @@
+package go_lru
+
+import (
+    "context"
+    "errors"
+    "hash/maphash"
+    "time"
+)
+
+// Shard is the API a ShardedCache needs from each of its shards. It is
+// implemented by Cache, TwoQueueCache, ARCCache, CARCache, LIRSCache,
+// LFUCache, TinyLFUCache, SieveCache, S3FIFOCache and PolicyCache.
+type Shard[K comparable, V any] interface {
+    Interface[K, V]
+    AddWithCost(key K, value V, cost int64, d time.Duration) bool
+    AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool
+    AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool
+    AddWithDeadline(key K, value V, deadline time.Time) bool
+    GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error)
+    SetRefreshLoader(loader LoaderFunc[K, V])
+    TTL(key K) (time.Duration, bool)
+    Touch(key K, d time.Duration) bool
+    SetExpiration(key K, deadline time.Time) bool
+    RemoveExpired() int
+    StartJanitor(interval time.Duration)
+    Close()
+    Cost() int64
+    Stats() Stats
+    ResetStats()
+}
+
+var (
+    _ Shard[string, int] = (*Cache[string, int])(nil)
+    _ Shard[string, int] = (*TwoQueueCache[string, int])(nil)
+    _ Shard[string, int] = (*ARCCache[string, int])(nil)
+    _ Shard[string, int] = (*CARCache[string, int])(nil)
+    _ Shard[string, int] = (*LIRSCache[string, int])(nil)
+    _ Shard[string, int] = (*LFUCache[string, int])(nil)
+    _ Shard[string, int] = (*TinyLFUCache[string, int])(nil)
+    _ Shard[string, int] = (*SieveCache[string, int])(nil)
+    _ Shard[string, int] = (*S3FIFOCache[string, int])(nil)
+    _ Shard[string, int] = (*PolicyCache[string, int])(nil)
+    _ Shard[string, int] = (*ShardedCache[string, int])(nil)
+)
+
+// ShardFunc creates one shard of a ShardedCache, holding at most size
+// entries.
+type ShardFunc[K comparable, V any] func(size int) (Shard[K, V], error)
+
+// ShardedCache is a thread-safe cache that spreads keys across
+// independent shards by their hash, each with its own lock, so that
+// concurrent callers rarely contend. Every shard runs the same policy,
+// which only sees the keys of its own shard.
+type ShardedCache[K comparable, V any] struct {
+    seed   maphash.Seed
+    shards []Shard[K, V]
+}
+
+// NewSharded creates a cache with string keys and untyped values split
+// into the given number of shards created by newShard, which share size
+// between them.
+func NewSharded(shards int, size int, newShard ShardFunc[string, interface{}]) (*ShardedCache[string, interface{}], error) {
+    return NewShardedOf[string, interface{}](shards, size, newShard)
+}
+
+// NewShardedOf creates a cache split into the given number of shards
+// created by newShard, for any comparable key type and value type. The
+// shards split size exactly between them, the first size%shards shards
+// holding one entry more than the others, so size must be at least the
+// number of shards. newShard may also bound them by cost instead.
+func NewShardedOf[K comparable, V any](shards int, size int, newShard ShardFunc[K, V]) (*ShardedCache[K, V], error) {
+    if shards <= 0 {
+        return nil, errors.New("Must provide a positive number of shards")
+    }
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    if size < shards {
+        return nil, errors.New("Must provide a size of at least the number of shards")
+    }
+    c := &ShardedCache[K, V]{
+        seed:   maphash.MakeSeed(),
+        shards: make([]Shard[K, V], shards),
+    }
+    for i := range c.shards {
+        shardSize := size / shards
+        if i < size%shards {
+            shardSize++
+        }
+        s, err := newShard(shardSize)
+        if err != nil {
+            return nil, err
+        }
+        c.shards[i] = s
+    }
+    return c, nil
+}
+
+// shard returns the shard holding key.
+func (c *ShardedCache[K, V]) shard(key K) Shard[K, V] {
+    return c.shards[maphash.Comparable(c.seed, key)%uint64(len(c.shards))]
+}
+
+// Add adds a value to the cache.  Returns true if an eviction occurred.
+func (c *ShardedCache[K, V]) Add(key K, value V) bool {
+    return c.shard(key).Add(key, value)
+}
+
+// AddWithExpire adds a value to the cache that expires after d.
+// Returns true if an eviction occurred.
+func (c *ShardedCache[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    return c.shard(key).AddWithExpire(key, value, d)
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *ShardedCache[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    return c.shard(key).AddWithCost(key, value, cost, d)
+}
+
+// AddWithRefresh adds a value that expires after d and is refreshed in
+// the background after refresh. Returns true if an eviction occurred.
+func (c *ShardedCache[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    return c.shard(key).AddWithRefresh(key, value, refresh, d)
+}
+
+// AddWithSliding adds a value that expires after d of inactivity and,
+// if max is positive, at most max from now. Returns true if an eviction
+// occurred.
+func (c *ShardedCache[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    return c.shard(key).AddWithSliding(key, value, d, max)
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *ShardedCache[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    return c.shard(key).AddWithDeadline(key, value, deadline)
+}
+
+// Get looks up a key's value from the cache.
+func (c *ShardedCache[K, V]) Get(key K) (V, bool) {
+    return c.shard(key).Get(key)
+}
+
+// GetOrLoad looks up a key's value from the cache, or loads it with
+// loader. Concurrent calls for the same key share a single load.
+func (c *ShardedCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+    return c.shard(key).GetOrLoad(ctx, key, loader)
+}
+
+// SetRefreshLoader registers the loader used to refresh entries added
+// with AddWithRefresh, in every shard.
+func (c *ShardedCache[K, V]) SetRefreshLoader(loader LoaderFunc[K, V]) {
+    for _, s := range c.shards {
+        s.SetRefreshLoader(loader)
+    }
+}
+
+// Contains checks if a key is in the cache, without updating the
+// recent-ness or deleting it for being stale.
+func (c *ShardedCache[K, V]) Contains(key K) bool {
+    return c.shard(key).Contains(key)
+}
+
+// Peek returns the key value (or undefined if not found) without
+// updating the "recently used"-ness of the key.
+func (c *ShardedCache[K, V]) Peek(key K) (V, bool) {
+    return c.shard(key).Peek(key)
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating the
+// recent-ness or deleting it for being stale, and if not, adds the value.
+// Returns whether found and whether an eviction occurred.
+func (c *ShardedCache[K, V]) ContainsOrAdd(key K, value V) (ok, evict bool) {
+    return c.shard(key).ContainsOrAdd(key, value)
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *ShardedCache[K, V]) TTL(key K) (time.Duration, bool) {
+    return c.shard(key).TTL(key)
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration.
+// Returns false if the key is missing or stale.
+func (c *ShardedCache[K, V]) Touch(key K, d time.Duration) bool {
+    return c.shard(key).Touch(key, d)
+}
+
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time. Returns false if the key is missing or stale.
+func (c *ShardedCache[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    return c.shard(key).SetExpiration(key, deadline)
+}
+
+// Remove removes the provided key from the cache, returning if the
+// key was contained.
+func (c *ShardedCache[K, V]) Remove(key K) bool {
+    return c.shard(key).Remove(key)
+}
+
+// RemoveExpired removes every expired item from the cache, returning
+// how many were removed.
+func (c *ShardedCache[K, V]) RemoveExpired() int {
+    removed := 0
+    for _, s := range c.shards {
+        removed += s.RemoveExpired()
+    }
+    return removed
+}
+
+// StartJanitor starts a background janitor in every shard, removing
+// expired items every interval. Call Close to stop them. A non-positive
+// interval is ignored.
+func (c *ShardedCache[K, V]) StartJanitor(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    for _, s := range c.shards {
+        s.StartJanitor(interval)
+    }
+}
+
+// Close stops the janitors, if any. The cache remains usable.
+func (c *ShardedCache[K, V]) Close() {
+    for _, s := range c.shards {
+        s.Close()
+    }
+}
+
+// Purge is used to completely clear the cache.
+func (c *ShardedCache[K, V]) Purge() {
+    for _, s := range c.shards {
+        s.Purge()
+    }
+}
+
+// Keys returns a slice of the keys in the cache, shard by shard, each
+// from oldest to newest.
+func (c *ShardedCache[K, V]) Keys() []K {
+    var keys []K
+    for _, s := range c.shards {
+        keys = append(keys, s.Keys()...)
+    }
+    return keys
+}
+
+// Len returns the number of items in the cache.
+func (c *ShardedCache[K, V]) Len() int {
+    n := 0
+    for _, s := range c.shards {
+        n += s.Len()
+    }
+    return n
+}
+
+// Cost returns the total cost of the items in the cache.
+func (c *ShardedCache[K, V]) Cost() int64 {
+    var cost int64
+    for _, s := range c.shards {
+        cost += s.Cost()
+    }
+    return cost
+}
+
+// Stats returns the statistics of the shards added together. The
+// snapshots of the shards are not taken atomically.
+func (c *ShardedCache[K, V]) Stats() Stats {
+    var total Stats
+    for _, s := range c.shards {
+        total.add(s.Stats())
+    }
+    return total
+}
+
+// ResetStats zeroes the counters of every shard.
+func (c *ShardedCache[K, V]) ResetStats() {
+    for _, s := range c.shards {
+        s.ResetStats()
+    }
+}
@@ -0,0 +1,106 @@
+package go_lru
+
+import (
+    "fmt"
+    "sort"
+    "testing"
+    "time"
+)
+
+func TestSharded(t *testing.T) {
+    policies := map[string]ShardFunc[string, int]{
+        "LRU": func(size int) (Shard[string, int], error) { return NewOf[string, int](size, NoExpiration) },
+        "2Q":  func(size int) (Shard[string, int], error) { return New2QOf[string, int](size, NoExpiration) },
+        "ARC": func(size int) (Shard[string, int], error) { return NewARCOf[string, int](size, NoExpiration) },
+        "LFU": func(size int) (Shard[string, int], error) { return NewLFUOf[string, int](size, NoExpiration) },
+        "TinyLFU": func(size int) (Shard[string, int], error) {
+            return NewTinyLFUOf[string, int](size, NoExpiration)
+        },
+        "Sieve": func(size int) (Shard[string, int], error) {
+            return NewSieveOf[string, int](size, NoExpiration)
+        },
+        "S3FIFO": func(size int) (Shard[string, int], error) {
+            return NewS3FIFOOf[string, int](size, NoExpiration)
+        },
+    }
+    for name, newShard := range policies {
+        t.Run(name, func(t *testing.T) {
+            l, err := NewShardedOf[string, int](4, 64, newShard)
+            if err != nil {
+                t.Fatalf("err: %v", err)
+            }
+
+            for i := 0; i < 32; i++ {
+                l.Add(fmt.Sprintf("%d", i), i)
+            }
+            for i := 0; i < 32; i++ {
+                if v, ok := l.Get(fmt.Sprintf("%d", i)); !ok || v != i {
+                    t.Fatalf("bad: %v, %v", v, ok)
+                }
+            }
+            if l.Len() != 32 || l.Cost() != 32 {
+                t.Fatalf("bad len: %v cost: %v", l.Len(), l.Cost())
+            }
+            keys := l.Keys()
+            sort.Slice(keys, func(i, j int) bool { return len(keys[i]) < len(keys[j]) || len(keys[i]) == len(keys[j]) && keys[i] < keys[j] })
+            for i, k := range keys {
+                if k != fmt.Sprintf("%d", i) {
+                    t.Fatalf("bad key: %v", k)
+                }
+            }
+
+            // Capacity is shared by the shards
+            for i := 32; i < 1000; i++ {
+                l.Add(fmt.Sprintf("%d", i), i)
+            }
+            if l.Len() > 64 || l.Len() < 32 {
+                t.Fatalf("bad len: %v", l.Len())
+            }
+
+            s := l.Stats()
+            if s.Hits != 32 || s.Adds != 1000 || s.Len != l.Len() || s.Evictions != uint64(1000-l.Len()) {
+                t.Fatalf("bad stats: %+v", s)
+            }
+            if name == "2Q" && s.TwoQueue == nil || name == "ARC" && s.ARC == nil {
+                t.Fatalf("should report policy internals: %+v", s)
+            }
+            l.ResetStats()
+            if s := l.Stats(); s.Hits != 0 || s.Len != l.Len() {
+                t.Fatalf("bad stats after reset: %+v", s)
+            }
+
+            if !l.Remove("999") || l.Contains("999") {
+                t.Fatalf("should be removed")
+            }
+            l.AddWithExpire("a", 1, 10*time.Millisecond)
+            time.Sleep(20 * time.Millisecond)
+            if n := l.RemoveExpired(); n != 1 {
+                t.Fatalf("bad removed: %d", n)
+            }
+            l.Purge()
+            if l.Len() != 0 {
+                t.Fatalf("bad len: %v", l.Len())
+            }
+        })
+    }
+
+    if _, err := NewSharded(0, 64, nil); err == nil {
+        t.Fatalf("should fail without shards")
+    }
+    if _, err := NewSharded(8, 7, nil); err == nil {
+        t.Fatalf("should fail with fewer entries than shards")
+    }
+
+    // The shards split the size exactly
+    var sizes []int
+    _, err := NewShardedOf[string, int](4, 10, func(size int) (Shard[string, int], error) {
+        sizes = append(sizes, size)
+        return NewOf[string, int](size, NoExpiration)
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if fmt.Sprint(sizes) != "[3 3 2 2]" {
+        t.Fatalf("bad shard sizes: %v", sizes)
+    }
+}
@@ -0,0 +1,537 @@
+package go_lru
+
+import (
+    "container/list"
+    "context"
+    "errors"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// SieveCache is a thread-safe fixed size SIEVE cache. Entries sit in a
+// FIFO queue with a visited bit that a hit sets. To make room, a hand
+// moves from the oldest entry towards the newest, clearing the bits it
+// passes, and evicts the first entry not visited since the hand last
+// went by. Hits never reorder the queue, so Get only takes the shared
+// lock. The size of the cache bounds the total cost of its entries, one
+// each unless added with AddWithCost.
+type SieveCache[K comparable, V any] struct {
+    size              int64
+    cost              int64         // cost is the total cost of the entries
+    queue             *list.List    // queue holds the entries, from newest to oldest
+    hand              *list.Element // hand is the next entry to inspect, nil for the oldest
+    items             map[K]*list.Element
+    expiry            expiryHeap[K, V] // expiry indexes the expiring entries
+    onEvict           EvictReasonCallback[K, V]
+    defaultExpiration time.Duration
+
+    lock      sync.RWMutex
+    janitor   *janitor
+    loads     loadGroup[K, V]
+    refresher refresher[K, V]
+    stats     *counters
+}
+
+// NewSieve creates a SIEVE cache of the given size with string keys and
+// untyped values.
+func NewSieve(size int, defaultExpiration time.Duration) (*SieveCache[string, interface{}], error) {
+    return NewSieveOf[string, interface{}](size, defaultExpiration)
+}
+
+// NewSieveWithEvict creates a SIEVE cache of the given size with string
+// keys, untyped values and the given eviction callback.
+func NewSieveWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*SieveCache[string, interface{}], error) {
+    return NewSieveWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewSieveWithEvictReason creates a SIEVE cache of the given size with
+// string keys, untyped values and an eviction callback that also learns
+// why an entry left the cache.
+func NewSieveWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*SieveCache[string, interface{}], error) {
+    return NewSieveWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewSieveOf creates a SIEVE cache of the given size for any comparable
+// key type and value type.
+func NewSieveOf[K comparable, V any](size int, defaultExpiration time.Duration) (*SieveCache[K, V], error) {
+    return newSieve[K, V](size, nil, defaultExpiration)
+}
+
+// NewSieveWithEvictOf creates a SIEVE cache of the given size with the
+// given eviction callback.
+func NewSieveWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*SieveCache[K, V], error) {
+    return newSieve[K, V](size, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration)
+}
+
+// NewSieveWithEvictReasonOf creates a SIEVE cache of the given size
+// whose eviction callback also learns why an entry left the cache,
+// including the old value of an updated entry.
+func NewSieveWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*SieveCache[K, V], error) {
+    return newSieve[K, V](size, onEvicted, defaultExpiration)
+}
+
+// newSieve creates a SIEVE cache holding at most size entries.
+func newSieve[K comparable, V any](size int, onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) (*SieveCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+    return &SieveCache[K, V]{
+        size:              int64(size),
+        queue:             list.New(),
+        items:             make(map[K]*list.Element),
+        onEvict:           onEvict,
+        defaultExpiration: defaultExpiration,
+        stats:             new(counters),
+    }, nil
+}
+
+// Get looks up a key's value from the cache, marking it visited. Only
+// sliding, refreshed or expired entries take the exclusive lock.
+func (c *SieveCache[K, V]) Get(key K) (V, bool) {
+    c.lock.RLock()
+    el, ok := c.items[key]
+    if ok {
+        ent := el.Value.(*entry[K, V])
+        if ent.deferrable() {
+            value := ent.value
+            ent.visit(1)
+            c.lock.RUnlock()
+            c.stats.hit()
+            return value, true
+        }
+    }
+    c.lock.RUnlock()
+    if ok {
+        return c.getExclusive(key)
+    }
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// getExclusive does the work of Get under the exclusive lock, for the
+// entries that a read updates.
+func (c *SieveCache[K, V]) getExclusive(key K) (V, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if el, ok := c.items[key]; ok {
+        ent := el.Value.(*entry[K, V])
+        if !ent.Expired() {
+            ent.visit(1)
+            c.expiry.slide(ent)
+            return c.hit(ent)
+        }
+        c.removeElement(el, EvictExpired)
+    }
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// hit returns the value of a cache hit, starting a background refresh
+// if it is past its soft TTL.
+func (c *SieveCache[K, V]) hit(ent *entry[K, V]) (V, bool) {
+    c.stats.hit()
+    if loader, ok := c.refresher.due(ent, time.Now().UnixNano()); ok {
+        go c.refresh(ent, loader)
+    }
+    return ent.value, true
+}
+
+// refresh reloads ent, dropping the result if the entry was updated or
+// removed in the meantime.
+func (c *SieveCache[K, V]) refresh(ent *entry[K, V], loader LoaderFunc[K, V]) {
+    value, d, err := loader(context.Background(), ent.key)
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    el, ok := c.items[ent.key]
+    if !ok || el.Value.(*entry[K, V]) != ent || !ent.refreshing {
+        return
+    }
+    if err != nil {
+        ent.refreshing = false
+        return
+    }
+    c.notify(ent, EvictReplaced)
+    ent.value = value
+    ent.refreshing = false
+    ent.refreshAt = time.Now().Add(ent.refresh).UnixNano()
+    c.expiry.update(ent, c.expireAt(d))
+}
+
+// GetOrLoad looks up a key's value from the cache, or loads it with
+// loader and adds it with the duration the loader returns. Concurrent
+// calls for the same key share a single load; callers waiting on it
+// give up when their ctx is done, but the load carries on for the
+// others. A panic in the loader is raised again in each of them. The
+// cache is not locked while the loader runs.
+func (c *SieveCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+    return c.loads.load(ctx, c, key, loader)
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred.
+func (c *SieveCache[K, V]) Add(key K, value V) bool {
+    return c.AddWithExpire(key, value, NoExpiration)
+}
+
+// AddWithExpire adds a value to the cache that expires after d. Updating
+// a key marks it visited without moving it. Returns true if an eviction
+// occurred.
+func (c *SieveCache[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, 1, ttl{expiration: c.expireAt(d)})
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *SieveCache[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, cost, ttl{expiration: c.expireAt(d)})
+}
+
+// AddWithSliding adds a value that expires after d, each successful Get
+// pushing its deadline d further. A positive max caps its lifetime from
+// now regardless of accesses. Returns true if an eviction occurred.
+func (c *SieveCache[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    if d == DefaultExpiration {
+        d = c.defaultExpiration
+    }
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, 1, slidingTTL(d, max))
+}
+
+// AddWithRefresh adds a value that expires after d. Once the soft TTL
+// refresh has passed, Get keeps returning it but also reloads it in the
+// background with the loader registered by SetRefreshLoader.
+// Returns true if an eviction occurred.
+func (c *SieveCache[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, 1, ttl{expiration: c.expireAt(d), refresh: refresh})
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *SieveCache[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, 1, deadlineTTL(deadline))
+}
+
+// SetRefreshLoader registers the loader used to refresh entries added
+// with AddWithRefresh. The duration it returns restarts the hard TTL.
+func (c *SieveCache[K, V]) SetRefreshLoader(loader LoaderFunc[K, V]) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.refresher.loader = loader
+}
+
+// ContainsOrAdd checks if a key is in the cache without marking it
+// visited or deleting it for being stale, and if not, adds the value.
+// Returns whether found and whether an eviction occurred.
+func (c *SieveCache[K, V]) ContainsOrAdd(key K, value V) (ok, evict bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if c.contains(key) {
+        return true, false
+    }
+    return false, c.add(key, value, 1, ttl{expiration: c.expireAt(NoExpiration)})
+}
+
+// add does the work of AddWithCost, the caller must hold the lock.
+func (c *SieveCache[K, V]) add(key K, value V, cost int64, t ttl) bool {
+    // Check for existing item
+    if el, ok := c.items[key]; ok {
+        ent := el.Value.(*entry[K, V])
+        c.notify(ent, EvictReplaced)
+        ent.value = value
+        ent.refreshing = false
+        ent.setTTL(t)
+        c.expiry.update(ent, t.expiration)
+        c.cost += cost - ent.cost
+        ent.cost = cost
+        ent.visit(1)
+        return c.makeRoom(0)
+    }
+
+    // Make room before adding, so the new entry is not the victim
+    evict := c.makeRoom(cost)
+
+    c.stats.add()
+    ent := newEntry(key, value, t, cost)
+    c.items[key] = c.queue.PushFront(ent)
+    c.expiry.track(ent)
+    c.cost += cost
+    return evict
+}
+
+// makeRoom evicts entries until one of the given cost fits. Returns true
+// if an entry was evicted.
+func (c *SieveCache[K, V]) makeRoom(cost int64) bool {
+    evicted := false
+    for len(c.items) > 0 && c.cost+cost > c.size {
+        c.evict()
+        evicted = true
+    }
+    return evicted
+}
+
+// evict moves the hand to the first entry not visited, clearing the bits
+// on the way, and evicts it.
+func (c *SieveCache[K, V]) evict() {
+    el := c.hand
+    if el == nil {
+        el = c.queue.Back()
+    }
+    for {
+        ent := el.Value.(*entry[K, V])
+        if atomic.LoadUint32(&ent.visits) == 0 {
+            break
+        }
+        atomic.StoreUint32(&ent.visits, 0)
+        if el = el.Prev(); el == nil {
+            el = c.queue.Back()
+        }
+    }
+    c.hand = el
+    c.removeElement(el, EvictCapacity)
+}
+
+// expireAt converts a duration into the expiration timestamp of an entry
+// added now, 0 meaning it never expires.
+func (c *SieveCache[K, V]) expireAt(d time.Duration) int64 {
+    if d == DefaultExpiration {
+        d = c.defaultExpiration
+    }
+    if d > 0 {
+        return time.Now().Add(d).UnixNano()
+    }
+    return 0
+}
+
+// RemoveExpired removes every expired entry from the cache in the order
+// they expired, returning how many were removed.
+func (c *SieveCache[K, V]) RemoveExpired() int {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    now := time.Now().UnixNano()
+    removed := 0
+    for {
+        kv, ok := c.expiry.peek()
+        if !ok || kv.Expiration >= now {
+            return removed
+        }
+        c.removeElement(c.items[kv.key], EvictExpired)
+        removed++
+    }
+}
+
+// StartJanitor starts a background goroutine that removes expired
+// entries every interval, replacing any janitor started before. Call
+// Close to stop it. A non-positive interval is ignored.
+func (c *SieveCache[K, V]) StartJanitor(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    j := startJanitor(interval, func() { c.RemoveExpired() })
+    c.lock.Lock()
+    old := c.janitor
+    c.janitor = j
+    c.lock.Unlock()
+    old.Stop()
+}
+
+// Close stops the janitor, if any. The cache remains usable.
+func (c *SieveCache[K, V]) Close() {
+    c.lock.Lock()
+    j := c.janitor
+    c.janitor = nil
+    c.lock.Unlock()
+    j.Stop()
+}
+
+// Len returns the number of cached entries.
+func (c *SieveCache[K, V]) Len() int {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return len(c.items)
+}
+
+// Cost returns the total cost of the cached entries.
+func (c *SieveCache[K, V]) Cost() int64 {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.cost
+}
+
+// Keys returns all the cached keys, from the first to be evicted to the
+// last if no entry is visited in the meantime: the entries not visited
+// in the order the hand reaches them, then the visited ones.
+func (c *SieveCache[K, V]) Keys() []K {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    keys := make([]K, 0, len(c.items))
+    var visited []K
+    start := c.hand
+    if start == nil {
+        start = c.queue.Back()
+    }
+    for el := start; el != nil; {
+        ent := el.Value.(*entry[K, V])
+        if atomic.LoadUint32(&ent.visits) == 0 {
+            keys = append(keys, ent.key)
+        } else {
+            visited = append(visited, ent.key)
+        }
+        if el = el.Prev(); el == nil {
+            el = c.queue.Back()
+        }
+        if el == start {
+            break
+        }
+    }
+    return append(keys, visited...)
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained.
+func (c *SieveCache[K, V]) Remove(key K) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    if el, ok := c.items[key]; ok {
+        c.removeElement(el, EvictRemoved)
+        return true
+    }
+    return false
+}
+
+// Purge is used to completely clear the cache.
+func (c *SieveCache[K, V]) Purge() {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for k, el := range c.items {
+        c.notify(el.Value.(*entry[K, V]), EvictPurged)
+        delete(c.items, k)
+    }
+    c.queue.Init()
+    c.hand = nil
+    c.expiry = nil
+    c.cost = 0
+}
+
+// Contains is used to check if the cache contains a key without marking
+// it visited.
+func (c *SieveCache[K, V]) Contains(key K) bool {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    return c.contains(key)
+}
+
+func (c *SieveCache[K, V]) contains(key K) bool {
+    el, ok := c.items[key]
+    return ok && !el.Value.(*entry[K, V]).Expired()
+}
+
+// Peek is used to inspect the cache value of a key without marking it
+// visited.
+func (c *SieveCache[K, V]) Peek(key K) (V, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    if el, ok := c.items[key]; ok && !el.Value.(*entry[K, V]).Expired() {
+        return el.Value.(*entry[K, V]).value, true
+    }
+    var empty V
+    return empty, false
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *SieveCache[K, V]) TTL(key K) (time.Duration, bool) {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    el, ok := c.items[key]
+    if !ok || el.Value.(*entry[K, V]).Expired() {
+        return 0, false
+    }
+    expiration := el.Value.(*entry[K, V]).Expiration
+    if expiration == 0 {
+        return NoExpiration, true
+    }
+    return time.Duration(expiration - time.Now().UnixNano()), true
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration,
+// without updating its value or marking it visited. A sliding expiration
+// is replaced by the fixed deadline. Returns false if the key is missing
+// or stale.
+func (c *SieveCache[K, V]) Touch(key K, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.setExpiration(key, c.expireAt(d))
+}
+
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time, without updating its value or marking it visited. A sliding
+// expiration is replaced by the fixed deadline. Returns false if the key
+// is missing or stale.
+func (c *SieveCache[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.setExpiration(key, deadlineTTL(deadline).expiration)
+}
+
+func (c *SieveCache[K, V]) setExpiration(key K, expiration int64) bool {
+    el, ok := c.items[key]
+    if !ok || el.Value.(*entry[K, V]).Expired() {
+        return false
+    }
+    ent := el.Value.(*entry[K, V])
+    ent.sliding = 0
+    ent.maxExpiration = 0
+    c.expiry.update(ent, expiration)
+    return true
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (c *SieveCache[K, V]) Stats() Stats {
+    s := c.stats.snapshot()
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+    s.Len = len(c.items)
+    s.Cost = c.cost
+    return s
+}
+
+// ResetStats zeroes the counters of the cache, to report statistics per
+// time window.
+func (c *SieveCache[K, V]) ResetStats() {
+    c.stats.reset()
+}
+
+// removeElement removes el from the cache for the given reason, moving
+// the hand past it.
+func (c *SieveCache[K, V]) removeElement(el *list.Element, reason EvictReason) {
+    if c.hand == el {
+        c.hand = el.Prev()
+    }
+    ent := el.Value.(*entry[K, V])
+    c.queue.Remove(el)
+    delete(c.items, ent.key)
+    c.expiry.untrack(ent)
+    c.cost -= ent.cost
+    c.notify(ent, reason)
+}
+
+// notify counts kv leaving for the given reason and fires the eviction
+// callback, if there is one.
+func (c *SieveCache[K, V]) notify(kv *entry[K, V], reason EvictReason) {
+    c.stats.evict(reason)
+    if c.onEvict != nil {
+        c.onEvict(kv.key, kv.value, reason)
+    }
+}
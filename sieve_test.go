@@ -0,0 +1,208 @@
+package go_lru
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestSieve(t *testing.T) {
+    evictCounter := 0
+    onEvicted := func(k string, v interface{}) {
+        evictCounter++
+    }
+    l, err := NewSieveWithEvict(128, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 256; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    if l.Len() != 128 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if evictCounter != 128 {
+        t.Fatalf("bad evict count: %v", evictCounter)
+    }
+    for i, k := range l.Keys() {
+        if v, ok := l.Get(k); !ok || v != i+128 {
+            t.Fatalf("bad key: %v", k)
+        }
+    }
+    for i := 0; i < 128; i++ {
+        if _, ok := l.Get(fmt.Sprint(i)); ok {
+            t.Fatalf("should be evicted")
+        }
+    }
+
+    l.Add("128", 1000)
+    if v, ok := l.Peek("128"); !ok || v != 1000 {
+        t.Fatalf("update not visible: %v, %v", v, ok)
+    }
+    if !l.Remove("128") || l.Contains("128") {
+        t.Fatalf("should be removed")
+    }
+    l.Purge()
+    if l.Len() != 0 || len(l.Keys()) != 0 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+}
+
+// Test that the hand skips the visited entries, clearing their bit, and
+// resumes where it stopped
+func TestSieve_Hand(t *testing.T) {
+    var evicted []string
+    onEvicted := func(k string, v int) {
+        evicted = append(evicted, k)
+    }
+    l, err := NewSieveWithEvictOf[string, int](3, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.Add("a", 1)
+    l.Add("b", 2)
+    l.Add("c", 3)
+    l.Get("a")
+    if keys := fmt.Sprint(l.Keys()); keys != "[b c a]" {
+        t.Fatalf("bad keys: %v", keys)
+    }
+
+    // a is spared, the hand stops on b
+    l.Add("d", 4)
+    // a was passed already, the hand goes on with c, d and e
+    l.Get("a")
+    l.Add("e", 5)
+    l.Add("f", 6)
+    l.Add("g", 7)
+    if fmt.Sprint(evicted) != "[b c d e]" {
+        t.Fatalf("bad evictions: %v", evicted)
+    }
+    if keys := fmt.Sprint(l.Keys()); keys != "[f g a]" {
+        t.Fatalf("bad keys: %v", keys)
+    }
+
+    // Removing the entry under the hand moves it along
+    l.Remove("f")
+    l.Add("h", 8)
+    l.Add("i", 9)
+    if fmt.Sprint(evicted) != "[b c d e f g]" || l.Len() != 3 {
+        t.Fatalf("bad evictions: %v", evicted)
+    }
+}
+
+func TestSieve_Expiration(t *testing.T) {
+    reasons := make(map[string]EvictReason)
+    onEvicted := func(k string, v int, reason EvictReason) {
+        reasons[k] = reason
+    }
+    l, err := NewSieveWithEvictReasonOf[string, int](4, 10*time.Millisecond, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithExpire("a", 1, DefaultExpiration)
+    l.AddWithExpire("b", 2, DefaultExpiration)
+    l.AddWithExpire("c", 3, NoExpiration)
+    l.AddWithExpire("d", 4, time.Hour)
+    time.Sleep(20 * time.Millisecond)
+
+    if l.Contains("a") {
+        t.Fatalf("a should have expired")
+    }
+    if _, ok := l.Get("a"); ok {
+        t.Fatalf("a should have expired")
+    }
+    if n := l.RemoveExpired(); n != 1 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if l.Len() != 2 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    if reasons["a"] != EvictExpired || reasons["b"] != EvictExpired {
+        t.Fatalf("bad reasons: %v", reasons)
+    }
+    if s := l.Stats(); s.Expirations != 2 || s.Misses != 1 || s.Len != 2 {
+        t.Fatalf("bad stats: %+v", s)
+    }
+}
+
+func TestSieve_Cost(t *testing.T) {
+    l, err := NewSieveOf[string, int](10, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithCost("a", 1, 4, NoExpiration)
+    l.AddWithCost("b", 2, 4, NoExpiration)
+    l.Get("a")
+    l.Add("c", 3)
+    if l.Cost() != 9 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // The hand passes the visited a and evicts b, which makes room
+    if !l.AddWithCost("d", 4, 3, NoExpiration) {
+        t.Fatalf("should have an eviction")
+    }
+    if l.Contains("b") || !l.Contains("a") || !l.Contains("c") {
+        t.Fatalf("b should have been evicted: %v", l.Keys())
+    }
+    if l.Cost() != 8 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // Updates change the cost
+    l.AddWithCost("c", 3, 3, NoExpiration)
+    if l.Cost() != 10 || l.Stats().Cost != 10 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+    l.Purge()
+    if l.Cost() != 0 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+}
+
+func TestSieve_TTL(t *testing.T) {
+    l, err := NewSieveOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    l.AddWithExpire("a", 1, 10*time.Millisecond)
+    l.Add("b", 2)
+    if !l.Touch("a", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("a"); !ok || d <= 59*time.Second {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    l.AddWithDeadline("c", 3, time.Now().Add(time.Hour))
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("b"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if d, ok := l.TTL("c"); !ok || d <= 59*time.Minute {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Updates honor the new duration
+    l.Add("a", 10)
+    if d, ok := l.TTL("a"); !ok || d != NoExpiration {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+
+    // Sliding entries are pushed forward by every hit
+    l.AddWithSliding("d", 4, 50*time.Millisecond, NoExpiration)
+    for i := 0; i < 3; i++ {
+        time.Sleep(30 * time.Millisecond)
+        if _, ok := l.Get("d"); !ok {
+            t.Fatalf("sliding entry should still be cached")
+        }
+    }
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Peek("d"); ok {
+        t.Fatalf("sliding entry should have expired")
+    }
+}
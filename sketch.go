@@ -0,0 +1,106 @@
+package go_lru
+
+import (
+    "hash/maphash"
+)
+
+const (
+    sketchDepth    = 4  // sketchDepth is the number of rows of counters
+    sketchMaxCount = 15 // sketchMaxCount caps each counter
+)
+
+// cmSketch is a count-min sketch estimating how often keys were seen.
+// Its counters are halved every resetAt increments so that popularity
+// fades over time.
+type cmSketch[K comparable] struct {
+    seed    maphash.Seed
+    rows    [sketchDepth][]uint8
+    mask    uint64
+    adds    int
+    resetAt int
+}
+
+// newCMSketch creates a sketch sized for a cache of size entries, with
+// rows of four counters per entry to keep collisions rare.
+func newCMSketch[K comparable](size int) *cmSketch[K] {
+    width := 16
+    for width < 4*size {
+        width <<= 1
+    }
+    s := &cmSketch[K]{
+        seed:    maphash.MakeSeed(),
+        mask:    uint64(width - 1),
+        resetAt: 10 * size,
+    }
+    for i := range s.rows {
+        s.rows[i] = make([]uint8, width)
+    }
+    return s
+}
+
+// indexes returns the counter of key in each row. The hash of key is
+// remixed with a different seed for each row, so that two keys sharing
+// a counter in one row are no more likely to share one in the others.
+func (s *cmSketch[K]) indexes(key K) [sketchDepth]uint64 {
+    h := maphash.Comparable(s.seed, key)
+    var idx [sketchDepth]uint64
+    for i := range idx {
+        idx[i] = mix64(h+sketchSeeds[i]) & s.mask
+    }
+    return idx
+}
+
+// sketchSeeds are the seeds of the rows of a sketch.
+var sketchSeeds = [sketchDepth]uint64{
+    0x9e3779b97f4a7c15, 0xbf58476d1ce4e5b9, 0x94d049bb133111eb, 0xd6e8feb86659fd93,
+}
+
+// mix64 is the finalizer of SplitMix64, spreading every bit of x over
+// the whole result.
+func mix64(x uint64) uint64 {
+    x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+    x = (x ^ x>>27) * 0x94d049bb133111eb
+    return x ^ x>>31
+}
+
+// increment records one occurrence of key, aging the sketch when due.
+func (s *cmSketch[K]) increment(key K) {
+    for i, j := range s.indexes(key) {
+        if s.rows[i][j] < sketchMaxCount {
+            s.rows[i][j]++
+        }
+    }
+    s.adds++
+    if s.adds >= s.resetAt {
+        s.reset()
+    }
+}
+
+// estimate returns how often key was seen, possibly overestimated.
+func (s *cmSketch[K]) estimate(key K) uint8 {
+    min := uint8(sketchMaxCount)
+    for i, j := range s.indexes(key) {
+        if s.rows[i][j] < min {
+            min = s.rows[i][j]
+        }
+    }
+    return min
+}
+
+// reset halves every counter.
+func (s *cmSketch[K]) reset() {
+    for _, row := range s.rows {
+        for j := range row {
+            row[j] >>= 1
+        }
+    }
+    s.adds /= 2
+}
+
+// clear forgets every key.
+func (s *cmSketch[K]) clear() {
+    for _, row := range s.rows {
+        clear(row)
+    }
+    s.adds = 0
+}
@@ -0,0 +1,434 @@
+package go_lru
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "runtime"
+    "time"
+)
+
+// Encoder writes the values of a snapshot to a stream.
+type Encoder interface {
+    Encode(v interface{}) error
+}
+
+// Decoder reads the values of a snapshot from a stream.
+type Decoder interface {
+    Decode(v interface{}) error
+}
+
+// Codec creates the encoder and the decoder of the stream of a snapshot,
+// which holds the keys and values of the cache. Codecs are set with
+// SetSnapshotCodec, GobCodec is used by default.
+type Codec interface {
+    NewEncoder(w io.Writer) Encoder
+    NewDecoder(r io.Reader) Decoder
+}
+
+// GobCodec encodes snapshots with encoding/gob. Values held in interface
+// types, like those of the caches with untyped values, must have their
+// concrete types registered with gob.Register.
+var GobCodec Codec = gobCodec{}
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+    return gob.NewEncoder(w)
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+    return gob.NewDecoder(r)
+}
+
+// The policies recorded in snapshots, so that a snapshot is only
+// restored into a cache of the same kind.
+const (
+    snapshotLRU uint8 = iota + 1
+    snapshot2Q
+    snapshotARC
+)
+
+// snapshotPolicyNames names the policies in errors.
+var snapshotPolicyNames = map[uint8]string{
+    snapshotLRU: "lru",
+    snapshot2Q:  "2q",
+    snapshotARC: "arc",
+}
+
+// snapshotHeader starts a snapshot, describing the cache it was taken
+// from.
+type snapshotHeader struct {
+    Policy uint8 // Policy is the eviction policy of the cache
+    P      int64 // P is the target size of T1 of an ARC cache
+}
+
+// snapshotRecord is an entry of a snapshot, or a ghost entry.
+type snapshotRecord[K comparable, V any] struct {
+    List          int // List is the index of the list of the entry in its cache
+    Key           K
+    Value         V
+    Cost          int64
+    Expiration    int64 // Expiration is the absolute deadline, 0 if never
+    Sliding       time.Duration
+    MaxExpiration int64
+    Refresh       time.Duration
+}
+
+// snapshotRecords appends the records of the keys of l to recs, from
+// oldest to newest, as part of the list-th list of the policy. The keys
+// of a list of cached keys are saved with their entry unless it expired,
+// those of a list of ghosts on their own unless they are cached.
+func snapshotRecords[K comparable, V any](recs []snapshotRecord[K, V], list int, l *keyList[K], entries *BASELRU[K, V], ghosts bool) []snapshotRecord[K, V] {
+    for el := l.ll.Back(); el != nil; el = el.Prev() {
+        n := el.Value.(*keyNode[K])
+        ent, ok := entries.peekEntry(n.key)
+        switch {
+        case ghosts && !ok:
+            recs = append(recs, snapshotRecord[K, V]{List: list, Key: n.key, Cost: n.cost})
+        case !ghosts && ok && !ent.Expired():
+            recs = append(recs, entryRecord(list, ent))
+        }
+    }
+    return recs
+}
+
+// entryRecord returns the record of ent as part of the list-th list.
+func entryRecord[K comparable, V any](list int, ent *entry[K, V]) snapshotRecord[K, V] {
+    return snapshotRecord[K, V]{
+        List:          list,
+        Key:           ent.key,
+        Value:         ent.value,
+        Cost:          ent.cost,
+        Expiration:    ent.Expiration,
+        Sliding:       ent.sliding,
+        MaxExpiration: ent.maxExpiration,
+        Refresh:       ent.refresh,
+    }
+}
+
+// ttl returns the expiration settings of the entry of rec.
+func (rec *snapshotRecord[K, V]) ttl() ttl {
+    return ttl{
+        expiration:    rec.Expiration,
+        refresh:       rec.Refresh,
+        sliding:       rec.Sliding,
+        maxExpiration: rec.MaxExpiration,
+    }
+}
+
+// expired tells whether the entry of rec expired before now.
+func (rec *snapshotRecord[K, V]) expired(now int64) bool {
+    return rec.Expiration != 0 && now > rec.Expiration
+}
+
+// Snapshots are written in the following format, all integers being
+// little-endian:
+//
+//	header   magic    [8]byte  "GOLRUSNP"
+//	         version  uint16   snapshotVersion
+//	         policy   uint8    1 for LRUPolicy, 2 for TwoQueuePolicy, 3 for ARCPolicy
+//	         reserved uint8    0
+//	         p        int64    target size of T1 of an ARCPolicy, 0 otherwise
+//	         crc      uint32   CRC32C of the 20 bytes above
+//	block    length   uint32   length of the payload, not 0
+//	         crc      uint32   CRC32C of the payload
+//	         payload  count uint32, then count records encoded with the codec
+//	...
+//	trailer  end      uint32   0
+//	         records  uint64   number of records in all the blocks
+//	         blocks   uint32   number of blocks
+//	         crc      uint32   CRC32C of the 16 bytes above
+//
+// Records are listed from the oldest entry of the first list of the policy
+// to the newest entry of its last list, the ghost lists included. Each
+// block is encoded with a new encoder of the codec, so that it can be
+// decoded on its own.
+const (
+    snapshotMagic   = "GOLRUSNP"
+    snapshotVersion = 1
+
+    snapshotHeaderSize  = 24
+    snapshotTrailerSize = 20
+
+    // snapshotBlockSize is the payload size past which a block is ended.
+    snapshotBlockSize = 64 << 10
+    // snapshotMaxBlock bounds the length read for a block, so that a
+    // corrupt length is not allocated.
+    snapshotMaxBlock = 1 << 30
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+    // ErrSnapshotTruncated is reported for a snapshot that ends before its
+    // trailer.
+    ErrSnapshotTruncated = errors.New("snapshot truncated")
+    // ErrSnapshotCorrupt is reported for a snapshot failing its checksums
+    // or holding invalid data.
+    ErrSnapshotCorrupt = errors.New("snapshot corrupt")
+    // ErrSnapshotVersion is reported for a snapshot in a format version this
+    // package cannot read.
+    ErrSnapshotVersion = errors.New("unsupported snapshot version")
+    // ErrSnapshotPolicy is reported for a snapshot of another kind of cache.
+    ErrSnapshotPolicy = errors.New("snapshot of another policy")
+    // ErrSnapshotUnsupported is returned by the snapshots of a PolicyCache
+    // whose policy is not one of this package.
+    ErrSnapshotUnsupported = errors.New("policy does not support snapshots")
+)
+
+// SnapshotError is returned when a snapshot cannot be restored, with the
+// offset in the stream where the problem was found. Err wraps one of
+// ErrSnapshotTruncated, ErrSnapshotCorrupt, ErrSnapshotVersion and
+// ErrSnapshotPolicy, which errors.Is matches through the SnapshotError.
+type SnapshotError struct {
+    Offset int64
+    Err    error
+}
+
+func (e *SnapshotError) Error() string {
+    return fmt.Sprintf("go_lru: %v at offset %d", e.Err, e.Offset)
+}
+
+func (e *SnapshotError) Unwrap() error {
+    return e.Err
+}
+
+// writeSnapshot encodes h and recs to w with codec, GobCodec if nil.
+func writeSnapshot[K comparable, V any](w io.Writer, codec Codec, h snapshotHeader, recs []snapshotRecord[K, V]) error {
+    if codec == nil {
+        codec = GobCodec
+    }
+    bw := bufio.NewWriter(w)
+
+    var hdr [snapshotHeaderSize]byte
+    copy(hdr[:8], snapshotMagic)
+    binary.LittleEndian.PutUint16(hdr[8:], snapshotVersion)
+    hdr[10] = h.Policy
+    binary.LittleEndian.PutUint64(hdr[12:], uint64(h.P))
+    binary.LittleEndian.PutUint32(hdr[20:], crc32.Checksum(hdr[:20], castagnoli))
+    if _, err := bw.Write(hdr[:]); err != nil {
+        return err
+    }
+
+    var payload bytes.Buffer
+    blocks := 0
+    for i := 0; i < len(recs); {
+        // The count of the block is filled in once it is known
+        payload.Reset()
+        payload.Write([]byte{0, 0, 0, 0})
+        enc := codec.NewEncoder(&payload)
+        n := 0
+        for ; i < len(recs) && payload.Len() < snapshotBlockSize; i++ {
+            if err := enc.Encode(&recs[i]); err != nil {
+                return err
+            }
+            n++
+        }
+        b := payload.Bytes()
+        if len(b) > snapshotMaxBlock {
+            return fmt.Errorf("snapshot block of %d bytes too large", len(b))
+        }
+        binary.LittleEndian.PutUint32(b, uint32(n))
+        var frame [8]byte
+        binary.LittleEndian.PutUint32(frame[:], uint32(len(b)))
+        binary.LittleEndian.PutUint32(frame[4:], crc32.Checksum(b, castagnoli))
+        if _, err := bw.Write(frame[:]); err != nil {
+            return err
+        }
+        if _, err := bw.Write(b); err != nil {
+            return err
+        }
+        blocks++
+    }
+
+    var tr [snapshotTrailerSize]byte
+    binary.LittleEndian.PutUint64(tr[4:], uint64(len(recs)))
+    binary.LittleEndian.PutUint32(tr[12:], uint32(blocks))
+    binary.LittleEndian.PutUint32(tr[16:], crc32.Checksum(tr[:16], castagnoli))
+    if _, err := bw.Write(tr[:]); err != nil {
+        return err
+    }
+    return bw.Flush()
+}
+
+// snapshotReader reads a snapshot, keeping the offset for errors.
+type snapshotReader struct {
+    r      io.Reader
+    offset int64
+}
+
+// read fills b, reporting a short read as a truncated snapshot.
+func (sr *snapshotReader) read(b []byte) error {
+    n, err := io.ReadFull(sr.r, b)
+    sr.offset += int64(n)
+    if err == io.EOF || err == io.ErrUnexpectedEOF {
+        return sr.fail(ErrSnapshotTruncated)
+    }
+    return err
+}
+
+func (sr *snapshotReader) fail(err error) error {
+    return &SnapshotError{Offset: sr.offset, Err: err}
+}
+
+// readSnapshot decodes a snapshot of a cache with the given policy and
+// number of lists from r with codec, GobCodec if nil. Nothing is
+// returned unless the whole snapshot, trailer included, is valid.
+func readSnapshot[K comparable, V any](r io.Reader, codec Codec, policy uint8, lists int) (snapshotHeader, []snapshotRecord[K, V], error) {
+    if codec == nil {
+        codec = GobCodec
+    }
+    sr := &snapshotReader{r: r}
+    var h snapshotHeader
+
+    var hdr [snapshotHeaderSize]byte
+    if err := sr.read(hdr[:]); err != nil {
+        return h, nil, err
+    }
+    if string(hdr[:8]) != snapshotMagic {
+        return h, nil, &SnapshotError{Err: fmt.Errorf("%w: bad magic", ErrSnapshotCorrupt)}
+    }
+    if crc32.Checksum(hdr[:20], castagnoli) != binary.LittleEndian.Uint32(hdr[20:]) {
+        return h, nil, &SnapshotError{Err: fmt.Errorf("%w: header checksum mismatch", ErrSnapshotCorrupt)}
+    }
+    if v := binary.LittleEndian.Uint16(hdr[8:]); v != snapshotVersion {
+        return h, nil, &SnapshotError{Err: fmt.Errorf("%w %d", ErrSnapshotVersion, v)}
+    }
+    h.Policy = hdr[10]
+    h.P = int64(binary.LittleEndian.Uint64(hdr[12:]))
+    if h.Policy != policy {
+        return h, nil, &SnapshotError{Err: fmt.Errorf("%w: %q cache, not %q",
+            ErrSnapshotPolicy, snapshotPolicyNames[h.Policy], snapshotPolicyNames[policy])}
+    }
+
+    var recs []snapshotRecord[K, V]
+    blocks := 0
+    for {
+        start := sr.offset
+        var frame [8]byte
+        if err := sr.read(frame[:4]); err != nil {
+            return h, nil, err
+        }
+        length := binary.LittleEndian.Uint32(frame[:])
+        if length == 0 {
+            break
+        }
+        if err := sr.read(frame[4:]); err != nil {
+            return h, nil, err
+        }
+        if length < 4 || length > snapshotMaxBlock {
+            return h, nil, &SnapshotError{Offset: start, Err: fmt.Errorf("%w: bad block length %d", ErrSnapshotCorrupt, length)}
+        }
+        b := make([]byte, length)
+        if err := sr.read(b); err != nil {
+            return h, nil, err
+        }
+        if crc32.Checksum(b, castagnoli) != binary.LittleEndian.Uint32(frame[4:]) {
+            return h, nil, &SnapshotError{Offset: start, Err: fmt.Errorf("%w: block checksum mismatch", ErrSnapshotCorrupt)}
+        }
+        n := binary.LittleEndian.Uint32(b)
+        dec := codec.NewDecoder(bytes.NewReader(b[4:]))
+        for i := uint32(0); i < n; i++ {
+            var rec snapshotRecord[K, V]
+            if err := dec.Decode(&rec); err != nil {
+                return h, nil, &SnapshotError{Offset: start, Err: fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)}
+            }
+            if rec.List < 0 || rec.List >= lists {
+                return h, nil, &SnapshotError{Offset: start, Err: fmt.Errorf("%w: bad list %d", ErrSnapshotCorrupt, rec.List)}
+            }
+            recs = append(recs, rec)
+        }
+        blocks++
+    }
+
+    start := sr.offset - 4
+    var tr [snapshotTrailerSize]byte
+    if err := sr.read(tr[4:]); err != nil {
+        return h, nil, err
+    }
+    if crc32.Checksum(tr[:16], castagnoli) != binary.LittleEndian.Uint32(tr[16:]) {
+        return h, nil, &SnapshotError{Offset: start, Err: fmt.Errorf("%w: trailer checksum mismatch", ErrSnapshotCorrupt)}
+    }
+    if binary.LittleEndian.Uint64(tr[4:]) != uint64(len(recs)) || binary.LittleEndian.Uint32(tr[12:]) != uint32(blocks) {
+        return h, nil, &SnapshotError{Offset: start, Err: fmt.Errorf("%w: %d records in %d blocks, trailer says %d in %d",
+            ErrSnapshotCorrupt, len(recs), blocks, binary.LittleEndian.Uint64(tr[4:]), binary.LittleEndian.Uint32(tr[12:]))}
+    }
+    return h, recs, nil
+}
+
+// writeFileAtomic writes the file at path with write, through a temporary
+// file of the same directory that is synced and renamed over path, so that
+// path holds either its previous content or the new one after a crash.
+func writeFileAtomic(path string, write func(w io.Writer) error) (err error) {
+    dir, base := filepath.Split(path)
+    if dir == "" {
+        dir = "."
+    }
+    f, err := os.CreateTemp(dir, base+".tmp*")
+    if err != nil {
+        return err
+    }
+    defer func() {
+        if err != nil {
+            f.Close()
+            os.Remove(f.Name())
+        }
+    }()
+    if err = write(f); err != nil {
+        return err
+    }
+    if err = f.Sync(); err != nil {
+        return err
+    }
+    if err = f.Close(); err != nil {
+        return err
+    }
+    if err = os.Rename(f.Name(), path); err != nil {
+        return err
+    }
+    return syncDir(dir)
+}
+
+// syncDir syncs the directory dir so that the files created or renamed in
+// it are durable. Directories cannot be synced on Windows, where it does
+// nothing.
+func syncDir(dir string) error {
+    if runtime.GOOS == "windows" {
+        return nil
+    }
+    d, err := os.Open(dir)
+    if err != nil {
+        return err
+    }
+    err = d.Sync()
+    if cerr := d.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+// restoreRecords adds the keys of the records to their lists as the
+// newest, and the entries of the first live lists to entries, skipping
+// those that expired since the snapshot and the keys seen already.
+func restoreRecords[K comparable, V any](recs []snapshotRecord[K, V], entries *BASELRU[K, V], lists []*keyList[K], live int) {
+    now := time.Now().UnixNano()
+    seen := make(map[K]bool, len(recs))
+    for _, rec := range recs {
+        if rec.expired(now) || seen[rec.Key] {
+            continue
+        }
+        seen[rec.Key] = true
+        if rec.List < live {
+            entries.put(newEntry(rec.Key, rec.Value, rec.ttl(), rec.Cost))
+        }
+        lists[rec.List].push(rec.Key, rec.Cost)
+    }
+}
@@ -0,0 +1,396 @@
+package go_lru
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// jsonCodec encodes snapshots as a stream of JSON values.
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+    return json.NewEncoder(w)
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+    return json.NewDecoder(r)
+}
+
+func TestLRU_Snapshot(t *testing.T) {
+    l, err := NewOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("a", 1)
+    l.AddWithExpire("b", 2, time.Hour)
+    l.AddWithExpire("c", 3, 20*time.Millisecond)
+    l.Add("d", 4)
+    l.Get("a")
+
+    var buf bytes.Buffer
+    if err := l.Snapshot(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    snapshot := buf.Bytes()
+
+    // The order and the absolute expiration are kept
+    r, err := NewOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    r.Add("z", 26)
+    if err := r.Restore(bytes.NewReader(snapshot)); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []string{"b", "c", "d", "a"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+    want, _ := l.entries.peekEntry("b")
+    if got, _ := r.entries.peekEntry("b"); got.Expiration != want.Expiration {
+        t.Fatalf("bad expiration: %v want %v", got.Expiration, want.Expiration)
+    }
+    if v, ok := r.Get("d"); !ok || v != 4 {
+        t.Fatalf("bad value: %v, %v", v, ok)
+    }
+
+    // Entries that expired in the meantime are skipped
+    time.Sleep(30 * time.Millisecond)
+    if err := r.Restore(bytes.NewReader(snapshot)); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []string{"b", "d", "a"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+
+    // A smaller cache keeps the most recent entries
+    var evicted []string
+    s, err := NewWithEvictOf[string, int](2, NoExpiration, func(k string, v int) {
+        evicted = append(evicted, k)
+    })
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := s.Restore(bytes.NewReader(snapshot)); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if keys := s.Keys(); !reflect.DeepEqual(keys, []string{"d", "a"}) || !reflect.DeepEqual(evicted, []string{"b"}) {
+        t.Fatalf("bad keys: %v evicted: %v", keys, evicted)
+    }
+}
+
+func TestLRU_SnapshotUntyped(t *testing.T) {
+    l, err := New(4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("a", "x")
+    l.Add("b", 2)
+
+    var buf bytes.Buffer
+    if err := l.Snapshot(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    r, err := New(4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := r.Restore(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if v, ok := r.Get("a"); !ok || v != "x" {
+        t.Fatalf("bad value: %v, %v", v, ok)
+    }
+    if v, ok := r.Get("b"); !ok || v != 2 {
+        t.Fatalf("bad value: %v, %v", v, ok)
+    }
+}
+
+func TestSnapshot_Codec(t *testing.T) {
+    l, err := NewOf[int, string](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.SetSnapshotCodec(jsonCodec{})
+    l.Add(1, "one")
+    l.AddWithSliding(2, "two", time.Hour, 0)
+
+    var buf bytes.Buffer
+    if err := l.Snapshot(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if !bytes.Contains(buf.Bytes(), []byte(`"Value":"one"`)) {
+        t.Fatalf("should be encoded as JSON: %q", buf.Bytes())
+    }
+
+    r, err := NewOf[int, string](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    r.SetSnapshotCodec(jsonCodec{})
+    if err := r.Restore(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []int{1, 2}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+    if ent, _ := r.entries.peekEntry(2); ent.sliding != time.Hour {
+        t.Fatalf("sliding expiration should be kept: %v", ent.sliding)
+    }
+}
+
+func TestSnapshot_Errors(t *testing.T) {
+    l, err := NewOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("a", 1)
+    l.Add("b", 2)
+    var buf bytes.Buffer
+    if err := l.Snapshot(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    snapshot := buf.Bytes()
+
+    a, err := NewARCOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    a.Add("z", 26)
+    if err := a.Restore(bytes.NewReader(snapshot)); err == nil {
+        t.Fatalf("should reject the snapshot of another policy")
+    }
+    if keys := a.Keys(); !reflect.DeepEqual(keys, []string{"z"}) {
+        t.Fatalf("should be left unchanged: %v", keys)
+    }
+
+    // A truncated snapshot leaves the cache unchanged
+    r, err := NewOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    r.Add("z", 26)
+    if err := r.Restore(bytes.NewReader(snapshot[:len(snapshot)-4])); err == nil {
+        t.Fatalf("should reject a truncated snapshot")
+    }
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []string{"z"}) {
+        t.Fatalf("should be left unchanged: %v", keys)
+    }
+}
+
+func TestSnapshot_Format(t *testing.T) {
+    l, err := NewOf[int, string](10000, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    for i := 0; i < 10000; i++ {
+        l.Add(i, fmt.Sprintf("value %d", i))
+    }
+    var buf bytes.Buffer
+    if err := l.Snapshot(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    snapshot := buf.Bytes()
+    if string(snapshot[:8]) != "GOLRUSNP" || binary.LittleEndian.Uint16(snapshot[8:]) != 1 || snapshot[10] != snapshotLRU {
+        t.Fatalf("bad header: %q", snapshot[:snapshotHeaderSize])
+    }
+    if blocks := binary.LittleEndian.Uint32(snapshot[len(snapshot)-8:]); blocks < 2 {
+        t.Fatalf("should span several blocks: %v", blocks)
+    }
+
+    // Records spanning several blocks are all restored
+    r, err := NewOf[int, string](10000, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := r.Restore(bytes.NewReader(snapshot)); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if !reflect.DeepEqual(r.Keys(), l.Keys()) {
+        t.Fatalf("bad keys")
+    }
+
+    // Any flipped byte is caught, by a checksum or the magic
+    r.Purge()
+    r.Add(-1, "kept")
+    for _, off := range []int{0, 12, snapshotHeaderSize + 2, snapshotHeaderSize + 100, len(snapshot) / 2, len(snapshot) - 10} {
+        b := append([]byte(nil), snapshot...)
+        b[off] ^= 0x40
+        err := r.Restore(bytes.NewReader(b))
+        var serr *SnapshotError
+        if !errors.As(err, &serr) || !(errors.Is(err, ErrSnapshotCorrupt) || errors.Is(err, ErrSnapshotTruncated)) {
+            t.Fatalf("%d: bad error: %v", off, err)
+        }
+    }
+    for _, n := range []int{0, 5, snapshotHeaderSize, snapshotHeaderSize + 6, len(snapshot) / 2, len(snapshot) - 1} {
+        err := r.Restore(bytes.NewReader(snapshot[:n]))
+        if !errors.Is(err, ErrSnapshotTruncated) {
+            t.Fatalf("%d: bad error: %v", n, err)
+        }
+    }
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []int{-1}) {
+        t.Fatalf("should be left unchanged: %v", keys)
+    }
+
+    // Another version or policy is rejected
+    b := append([]byte(nil), snapshot[:snapshotHeaderSize]...)
+    binary.LittleEndian.PutUint16(b[8:], 2)
+    binary.LittleEndian.PutUint32(b[20:], crc32.Checksum(b[:20], castagnoli))
+    if err := r.Restore(bytes.NewReader(b)); !errors.Is(err, ErrSnapshotVersion) {
+        t.Fatalf("bad error: %v", err)
+    }
+    a, err := NewARCOf[int, string](10, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := a.Restore(bytes.NewReader(snapshot)); !errors.Is(err, ErrSnapshotPolicy) {
+        t.Fatalf("bad error: %v", err)
+    }
+}
+
+func TestSnapshot_File(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "cache.snap")
+    l, err := New2QOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("a", 1)
+    if err := l.SnapshotFile(path); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("b", 2)
+    if err := l.SnapshotFile(path); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+        t.Fatalf("temporary files should be gone: %v", entries)
+    }
+
+    r, err := New2QOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := r.RestoreFile(path); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if keys := r.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+        t.Fatalf("bad keys: %v", keys)
+    }
+
+    // A failed write leaves the previous file in place
+    bad := errors.New("bad writer")
+    if err := writeFileAtomic(path, func(w io.Writer) error {
+        w.Write([]byte("partial"))
+        return bad
+    }); err != bad {
+        t.Fatalf("bad error: %v", err)
+    }
+    if err := r.RestoreFile(path); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+        t.Fatalf("temporary files should be gone: %v", entries)
+    }
+    if err := r.RestoreFile(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+        t.Fatalf("bad error: %v", err)
+    }
+}
+
+func Test2Q_Snapshot(t *testing.T) {
+    l, err := New2QOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    for i := 0; i < 6; i++ {
+        l.Add(fmt.Sprint(i), i)
+        l.Get("0")
+    }
+    l.Get("5")
+    l.flushReads()
+    recent, frequent, ghosts := twoQueuePolicy(l).recent.keys(), twoQueuePolicy(l).frequent.keys(), twoQueuePolicy(l).recentEvict.keys()
+    if len(frequent) != 2 || len(ghosts) == 0 {
+        t.Fatalf("bad lists: %v %v %v", recent, frequent, ghosts)
+    }
+
+    var buf bytes.Buffer
+    if err := l.Snapshot(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    r, err := New2QOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := r.Restore(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if !reflect.DeepEqual(twoQueuePolicy(r).recent.keys(), recent) || !reflect.DeepEqual(twoQueuePolicy(r).frequent.keys(), frequent) ||
+        !reflect.DeepEqual(twoQueuePolicy(r).recentEvict.keys(), ghosts) {
+        t.Fatalf("bad lists: %v %v %v want %v %v %v", twoQueuePolicy(r).recent.keys(), twoQueuePolicy(r).frequent.keys(), twoQueuePolicy(r).recentEvict.keys(), recent, frequent, ghosts)
+    }
+
+    // A ghost hit still promotes the key
+    r.Add(ghosts[0], 0)
+    if !twoQueuePolicy(r).frequent.contains(ghosts[0]) {
+        t.Fatalf("ghost should be restored")
+    }
+}
+
+func TestARC_Snapshot(t *testing.T) {
+    l, err := NewARCOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    for i := 0; i < 4; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    l.Get("0")
+    l.Get("1")
+    l.Add("4", 4)
+    l.Add("2", 2)
+    l.Add("5", 5)
+    l.flushReads()
+    lists := [][]string{arcPolicy(l).t1.keys(), arcPolicy(l).t2.keys(), arcPolicy(l).b1.keys(), arcPolicy(l).b2.keys()}
+    if arcPolicy(l).p == 0 || len(lists[2]) == 0 {
+        t.Fatalf("should have adapted: p %v lists %v", arcPolicy(l).p, lists)
+    }
+
+    var buf bytes.Buffer
+    if err := l.Snapshot(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    r, err := NewARCOf[string, int](4, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if err := r.Restore(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    got := [][]string{arcPolicy(r).t1.keys(), arcPolicy(r).t2.keys(), arcPolicy(r).b1.keys(), arcPolicy(r).b2.keys()}
+    if !reflect.DeepEqual(got, lists) || arcPolicy(r).p != arcPolicy(l).p {
+        t.Fatalf("bad lists: %v p %v want %v p %v", got, arcPolicy(r).p, lists, arcPolicy(l).p)
+    }
+
+    // A smaller cache clamps P
+    s, err := NewARCOf[string, int](1, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    buf.Reset()
+    l.Snapshot(&buf)
+    if err := s.Restore(&buf); err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    if s.Len() != 1 || arcPolicy(s).p > 1 {
+        t.Fatalf("bad len: %v p: %v", s.Len(), arcPolicy(s).p)
+    }
+}
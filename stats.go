@@ -0,0 +1,214 @@
+package go_lru
+
+import (
+    "sync/atomic"
+)
+
+// Stats is a snapshot of the statistics of a cache, counted since it was
+// created or since the last call to ResetStats.
+type Stats struct {
+    Hits        uint64 // Hits counts the lookups that found a live entry
+    Misses      uint64 // Misses counts the lookups that did not
+    Adds        uint64 // Adds counts the keys added to the cache
+    Updates     uint64 // Updates counts the values replaced, by Add or a refresh
+    GhostHits   uint64 // GhostHits counts the adds of keys remembered as recently evicted
+    Evictions   uint64 // Evictions counts the entries evicted to make room
+    Expirations uint64 // Expirations counts the expired entries reclaimed
+    Removals    uint64 // Removals counts the entries removed explicitly
+    Purges      uint64 // Purges counts the entries dropped by Purge
+
+    Len  int   // Len is the number of entries in the cache
+    Cost int64 // Cost is the total cost of the entries in the cache
+
+    // TwoQueue describes the lists of a TwoQueueCache, nil otherwise.
+    TwoQueue *TwoQueueStats
+    // ARC describes the adaptive state of an ARCCache, nil otherwise.
+    ARC *ARCStats
+    // CAR describes the adaptive state of a CARCache, whose clocks
+    // mirror the lists of ARC, nil otherwise.
+    CAR *ARCStats
+    // TinyLFU describes the segments of a TinyLFUCache, nil otherwise.
+    TinyLFU *TinyLFUStats
+    // S3FIFO describes the queues of an S3FIFOCache, nil otherwise.
+    S3FIFO *S3FIFOStats
+    // LIRS describes the sets of a LIRSCache, nil otherwise.
+    LIRS *LIRSStats
+}
+
+// TwoQueueStats describes the lists of a TwoQueueCache.
+type TwoQueueStats struct {
+    Recent      int // Recent is the number of entries seen once
+    Frequent    int // Frequent is the number of entries seen again
+    RecentEvict int // RecentEvict is the number of ghost entries
+}
+
+// ARCStats describes the adaptive state of an ARCCache.
+type ARCStats struct {
+    P  int64 // P is the target size of T1, in entries or cost
+    T1 int   // T1 is the number of recently accessed entries
+    T2 int   // T2 is the number of frequently accessed entries
+    B1 int   // B1 is the number of ghost entries evicted from T1
+    B2 int   // B2 is the number of ghost entries evicted from T2
+}
+
+// TinyLFUStats describes the segments of a TinyLFUCache.
+type TinyLFUStats struct {
+    Window    int // Window is the number of entries in the admission window
+    Probation int // Probation is the number of main entries accessed once
+    Protected int // Protected is the number of main entries accessed again
+}
+
+// S3FIFOStats describes the queues of an S3FIFOCache.
+type S3FIFOStats struct {
+    Small int // Small is the number of entries in the small queue
+    Main  int // Main is the number of entries in the main queue
+    Ghost int // Ghost is the number of keys remembered as evicted from small
+}
+
+// LIRSStats describes the sets of a LIRSCache.
+type LIRSStats struct {
+    LIR   int // LIR is the number of entries with a short reuse distance
+    HIR   int // HIR is the number of resident entries with a long one
+    Ghost int // Ghost is the number of non-resident keys remembered in the stack
+    Stack int // Stack is the number of keys in the stack, ghosts included
+}
+
+// HitRatio returns the share of lookups that were hits, 0 if there were
+// none.
+func (s Stats) HitRatio() float64 {
+    total := s.Hits + s.Misses
+    if total == 0 {
+        return 0
+    }
+    return float64(s.Hits) / float64(total)
+}
+
+// add adds the statistics of another cache to s, as if they were a
+// single cache.
+func (s *Stats) add(o Stats) {
+    s.Hits += o.Hits
+    s.Misses += o.Misses
+    s.Adds += o.Adds
+    s.Updates += o.Updates
+    s.GhostHits += o.GhostHits
+    s.Evictions += o.Evictions
+    s.Expirations += o.Expirations
+    s.Removals += o.Removals
+    s.Purges += o.Purges
+    s.Len += o.Len
+    s.Cost += o.Cost
+    if o.TwoQueue != nil {
+        if s.TwoQueue == nil {
+            s.TwoQueue = &TwoQueueStats{}
+        }
+        s.TwoQueue.Recent += o.TwoQueue.Recent
+        s.TwoQueue.Frequent += o.TwoQueue.Frequent
+        s.TwoQueue.RecentEvict += o.TwoQueue.RecentEvict
+    }
+    if o.ARC != nil {
+        if s.ARC == nil {
+            s.ARC = &ARCStats{}
+        }
+        s.ARC.add(o.ARC)
+    }
+    if o.CAR != nil {
+        if s.CAR == nil {
+            s.CAR = &ARCStats{}
+        }
+        s.CAR.add(o.CAR)
+    }
+    if o.TinyLFU != nil {
+        if s.TinyLFU == nil {
+            s.TinyLFU = &TinyLFUStats{}
+        }
+        s.TinyLFU.Window += o.TinyLFU.Window
+        s.TinyLFU.Probation += o.TinyLFU.Probation
+        s.TinyLFU.Protected += o.TinyLFU.Protected
+    }
+    if o.S3FIFO != nil {
+        if s.S3FIFO == nil {
+            s.S3FIFO = &S3FIFOStats{}
+        }
+        s.S3FIFO.Small += o.S3FIFO.Small
+        s.S3FIFO.Main += o.S3FIFO.Main
+        s.S3FIFO.Ghost += o.S3FIFO.Ghost
+    }
+    if o.LIRS != nil {
+        if s.LIRS == nil {
+            s.LIRS = &LIRSStats{}
+        }
+        s.LIRS.LIR += o.LIRS.LIR
+        s.LIRS.HIR += o.LIRS.HIR
+        s.LIRS.Ghost += o.LIRS.Ghost
+        s.LIRS.Stack += o.LIRS.Stack
+    }
+}
+
+// add adds the adaptive state of another cache to s.
+func (s *ARCStats) add(o *ARCStats) {
+    s.P += o.P
+    s.T1 += o.T1
+    s.T2 += o.T2
+    s.B1 += o.B1
+    s.B2 += o.B2
+}
+
+// counters are the lock-free statistics shared by the lists of a cache.
+// Ghost lists have none.
+type counters struct {
+    hits      uint64
+    misses    uint64
+    adds      uint64
+    ghostHits uint64
+    reasons   [EvictReplaced + 1]uint64 // reasons counts notifications by reason
+}
+
+func (s *counters) hit() {
+    atomic.AddUint64(&s.hits, 1)
+}
+
+func (s *counters) miss() {
+    atomic.AddUint64(&s.misses, 1)
+}
+
+func (s *counters) add() {
+    atomic.AddUint64(&s.adds, 1)
+}
+
+func (s *counters) ghostHit() {
+    atomic.AddUint64(&s.ghostHits, 1)
+}
+
+// evict counts an entry leaving the cache, or its value being replaced.
+func (s *counters) evict(reason EvictReason) {
+    if reason >= 0 && int(reason) < len(s.reasons) {
+        atomic.AddUint64(&s.reasons[reason], 1)
+    }
+}
+
+// snapshot returns the counters as Stats, leaving the contents and the
+// policy internals for the caller to fill in.
+func (s *counters) snapshot() Stats {
+    return Stats{
+        Hits:        atomic.LoadUint64(&s.hits),
+        Misses:      atomic.LoadUint64(&s.misses),
+        Adds:        atomic.LoadUint64(&s.adds),
+        Updates:     atomic.LoadUint64(&s.reasons[EvictReplaced]),
+        GhostHits:   atomic.LoadUint64(&s.ghostHits),
+        Evictions:   atomic.LoadUint64(&s.reasons[EvictCapacity]),
+        Expirations: atomic.LoadUint64(&s.reasons[EvictExpired]),
+        Removals:    atomic.LoadUint64(&s.reasons[EvictRemoved]),
+        Purges:      atomic.LoadUint64(&s.reasons[EvictPurged]),
+    }
+}
+
+// reset zeroes the counters.
+func (s *counters) reset() {
+    atomic.StoreUint64(&s.hits, 0)
+    atomic.StoreUint64(&s.misses, 0)
+    atomic.StoreUint64(&s.adds, 0)
+    atomic.StoreUint64(&s.ghostHits, 0)
+    for i := range s.reasons {
+        atomic.StoreUint64(&s.reasons[i], 0)
+    }
+}
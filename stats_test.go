@@ -0,0 +1,165 @@
+package go_lru
+
+import (
+    "testing"
+    "time"
+)
+
+// statsCache is implemented by the caches keeping statistics.
+type statsCache interface {
+    Interface[string, int]
+    Stats() Stats
+    ResetStats()
+}
+
+func testStats(t *testing.T, l statsCache, ghostHits uint64) {
+    l.Get("a")
+    l.Add("a", 1)
+    l.Add("b", 2)
+    l.Get("a")
+    l.Add("a", 10)
+    l.Add("c", 3)
+    l.Add("b", 20)
+    l.Remove("b")
+    l.Purge()
+    l.AddWithExpire("d", 4, time.Millisecond)
+    time.Sleep(5 * time.Millisecond)
+    l.Get("d")
+
+    s := l.Stats()
+    want := Stats{
+        Hits:        1,
+        Misses:      2,
+        Adds:        5,
+        Updates:     1,
+        GhostHits:   ghostHits,
+        Evictions:   2,
+        Expirations: 1,
+        Removals:    1,
+        Purges:      1,
+        TwoQueue:    s.TwoQueue,
+        ARC:         s.ARC,
+        CAR:         s.CAR,
+        LIRS:        s.LIRS,
+    }
+    if s != want {
+        t.Fatalf("bad stats: %+v want %+v", s, want)
+    }
+    if r := s.HitRatio(); r != 1.0/3 {
+        t.Fatalf("bad hit ratio: %v", r)
+    }
+
+    l.Add("e", 5)
+    l.ResetStats()
+    if s := l.Stats(); s.Adds != 0 || s.Misses != 0 || s.Len != 1 || s.Cost != 1 {
+        t.Fatalf("bad stats after reset: %+v", s)
+    }
+}
+
+func TestLRU_Stats(t *testing.T) {
+    l, err := NewOf[string, int](2, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("a", 1)
+    if s := l.Stats(); s.Adds != 1 || s.Len != 1 || s.TwoQueue != nil || s.ARC != nil {
+        t.Fatalf("should not report policy internals: %+v", s)
+    }
+}
+
+func Test2Q_Stats(t *testing.T) {
+    l, err := New2QOf[string, int](2, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testStats(t, l, 1)
+
+    l.Purge()
+    l.Add("a", 1)
+    l.Get("a")
+    l.Add("b", 2)
+    l.Add("c", 3)
+    if s := l.Stats().TwoQueue; s == nil || *s != (TwoQueueStats{Recent: 1, Frequent: 1, RecentEvict: 1}) {
+        t.Fatalf("bad 2Q stats: %+v", s)
+    }
+}
+
+func TestARC_Stats(t *testing.T) {
+    l, err := NewARCOf[string, int](2, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testStats(t, l, 1)
+
+    l.Purge()
+    l.Add("a", 1)
+    l.Get("a")
+    l.Add("b", 2)
+    l.Add("c", 3)
+    l.Add("b", 20)
+    if s := l.Stats().ARC; s == nil || *s != (ARCStats{P: 1, T1: 1, T2: 1, B1: 0, B2: 1}) {
+        t.Fatalf("bad ARC stats: %+v", s)
+    }
+}
+
+func TestCAR_Stats(t *testing.T) {
+    l, err := NewCAROf[string, int](2, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testStats(t, l, 1)
+
+    l.Purge()
+    l.Add("a", 1)
+    l.Get("a")
+    l.Add("b", 2)
+    l.Add("c", 3)
+    l.Add("b", 20)
+    if s := l.Stats().CAR; s == nil || *s != (ARCStats{P: 2, T1: 0, T2: 2, B1: 1, B2: 0}) {
+        t.Fatalf("bad CAR stats: %+v", s)
+    }
+}
+
+func TestLIRS_Stats(t *testing.T) {
+    l, err := NewLIRSOf[string, int](2, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testStats(t, l, 0)
+
+    l.Purge()
+    l.Add("a", 1)
+    l.Add("b", 2)
+    l.Add("c", 3)
+    l.Add("b", 20)
+    if s := l.Stats().LIRS; s == nil || *s != (LIRSStats{LIR: 1, HIR: 1, Ghost: 0, Stack: 1}) {
+        t.Fatalf("bad LIRS stats: %+v", s)
+    }
+}
+
+func TestPolicyCache_Stats(t *testing.T) {
+    l, err := NewPolicyCacheOf[string, int](2, New2QPolicy[string](2), NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    testStats(t, l, 1)
+
+    l.Purge()
+    l.Add("a", 1)
+    l.Get("a")
+    l.Add("b", 2)
+    l.Add("c", 3)
+    if s := l.Stats().TwoQueue; s == nil || *s != (TwoQueueStats{Recent: 1, Frequent: 1, RecentEvict: 1}) {
+        t.Fatalf("bad 2Q stats: %+v", s)
+    }
+
+    // Policies from outside of the package only get the counters
+    l, err = NewPolicyCacheOf[string, int](2, &fifoPolicy{}, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    l.Add("a", 1)
+    if s := l.Stats(); s.Adds != 1 || s.Len != 1 || s.TwoQueue != nil || s.ARC != nil {
+        t.Fatalf("should not report policy internals: %+v", s)
+    }
+}
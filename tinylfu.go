@@ -0,0 +1,536 @@
+package go_lru
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "time"
+)
+
+const (
+    // DefaultTinyLFUWindowRatio is the ratio of the TinyLFU cache
+    // dedicated to the admission window.
+    DefaultTinyLFUWindowRatio = 0.01
+
+    // DefaultTinyLFUProtectedRatio is the ratio of the main TinyLFU
+    // segments dedicated to entries accessed more than once.
+    DefaultTinyLFUProtectedRatio = 0.80
+)
+
+// TinyLFUCache is a thread-safe fixed size W-TinyLFU cache. New entries
+// go through a small LRU admission window. When they leave it, a
+// count-min sketch of recent access frequencies decides whether they
+// are popular enough to replace the next victim of the main cache, a
+// segmented LRU of a probation and a protected segment. One-hit
+// wonders are thus dropped without pushing out hot entries, while the
+// window still gives bursts of new entries a chance. The size of the
+// cache bounds the total cost of its entries, one each unless added
+// with AddWithCost.
+type TinyLFUCache[K comparable, V any] struct {
+    windowSize    int64 // windowSize is the capacity of the window
+    mainSize      int64 // mainSize is the capacity of probation and protected together
+    protectedSize int64 // protectedSize is the capacity of protected
+
+    window    *BASELRU[K, V] // window admits the new entries
+    probation *BASELRU[K, V] // probation holds the main entries accessed once
+    protected *BASELRU[K, V] // protected holds the main entries accessed again
+    sketch    *cmSketch[K]
+
+    lock      sync.Mutex
+    janitor   *janitor
+    loads     loadGroup[K, V]
+    refresher refresher[K, V]
+    stats     *counters
+}
+
+// NewTinyLFU creates a TinyLFU cache of the given size with string keys
+// and untyped values.
+func NewTinyLFU(size int, defaultExpiration time.Duration) (*TinyLFUCache[string, interface{}], error) {
+    return NewTinyLFUOf[string, interface{}](size, defaultExpiration)
+}
+
+// NewTinyLFUWithEvict creates a TinyLFU cache of the given size with
+// string keys, untyped values and the given eviction callback.
+func NewTinyLFUWithEvict(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{})) (*TinyLFUCache[string, interface{}], error) {
+    return NewTinyLFUWithEvictOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewTinyLFUWithEvictReason creates a TinyLFU cache of the given size
+// with string keys, untyped values and an eviction callback that also
+// learns why an entry left the cache.
+func NewTinyLFUWithEvictReason(size int, defaultExpiration time.Duration, onEvicted func(key string, value interface{}, reason EvictReason)) (*TinyLFUCache[string, interface{}], error) {
+    return NewTinyLFUWithEvictReasonOf[string, interface{}](size, defaultExpiration, onEvicted)
+}
+
+// NewTinyLFUOf creates a TinyLFU cache of the given size for any
+// comparable key type and value type.
+func NewTinyLFUOf[K comparable, V any](size int, defaultExpiration time.Duration) (*TinyLFUCache[K, V], error) {
+    return newTinyLFU[K, V](size, nil, defaultExpiration)
+}
+
+// NewTinyLFUWithEvictOf creates a TinyLFU cache of the given size with
+// the given eviction callback. It is called for every entry leaving the
+// cache, including new entries refused by the admission policy, but
+// never for entries moving between segments.
+func NewTinyLFUWithEvictOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V)) (*TinyLFUCache[K, V], error) {
+    return newTinyLFU[K, V](size, EvictCallback[K, V](onEvicted).withReason(), defaultExpiration)
+}
+
+// NewTinyLFUWithEvictReasonOf creates a TinyLFU cache of the given size
+// whose eviction callback also learns why an entry left the cache,
+// including the old value of an updated entry.
+func NewTinyLFUWithEvictReasonOf[K comparable, V any](size int, defaultExpiration time.Duration, onEvicted func(key K, value V, reason EvictReason)) (*TinyLFUCache[K, V], error) {
+    return newTinyLFU[K, V](size, onEvicted, defaultExpiration)
+}
+
+// newTinyLFU creates a TinyLFU cache holding at most size entries.
+func newTinyLFU[K comparable, V any](size int, onEvict EvictReasonCallback[K, V], defaultExpiration time.Duration) (*TinyLFUCache[K, V], error) {
+    if size <= 0 {
+        return nil, errors.New("Must provide a positive size")
+    }
+
+    // Determine the sub-sizes, the window holds at least one entry
+    windowSize := int64(float64(size) * DefaultTinyLFUWindowRatio)
+    if windowSize < 1 {
+        windowSize = 1
+    }
+    mainSize := int64(size) - windowSize
+
+    // The segments are unbounded, the cache moves entries between them
+    c := &TinyLFUCache[K, V]{
+        windowSize:    windowSize,
+        mainSize:      mainSize,
+        protectedSize: int64(float64(mainSize) * DefaultTinyLFUProtectedRatio),
+        window:        newBaseLRU[K, V](0, 0, nil, onEvict, defaultExpiration),
+        probation:     newBaseLRU[K, V](0, 0, nil, onEvict, defaultExpiration),
+        protected:     newBaseLRU[K, V](0, 0, nil, onEvict, defaultExpiration),
+        sketch:        newCMSketch[K](size),
+        stats:         new(counters),
+    }
+    for _, seg := range c.segments() {
+        seg.stats = c.stats
+    }
+    return c, nil
+}
+
+// segments returns the segments, from the first to be evicted to the
+// last.
+func (c *TinyLFUCache[K, V]) segments() [3]*BASELRU[K, V] {
+    return [3]*BASELRU[K, V]{c.probation, c.window, c.protected}
+}
+
+// Get looks up a key's value from the cache, promoting an entry on
+// probation to the protected segment.
+func (c *TinyLFUCache[K, V]) Get(key K) (V, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    // Every access counts towards the popularity of the key
+    c.sketch.increment(key)
+
+    if ent, ok := c.window.getEntry(key); ok {
+        return c.hit(ent)
+    }
+    if ent, ok := c.protected.getEntry(key); ok {
+        return c.hit(ent)
+    }
+    if ent, ok := c.probation.peekEntry(key); ok {
+        if ent.Expired() {
+            c.probation.removeKey(key, EvictExpired)
+        } else {
+            c.probation.take(key)
+            c.protected.put(ent)
+            c.protected.slide(ent)
+            c.demote()
+            return c.hit(ent)
+        }
+    }
+
+    // No hit
+    c.stats.miss()
+    var empty V
+    return empty, false
+}
+
+// hit returns the value of a cache hit, starting a background refresh
+// if it is past its soft TTL.
+func (c *TinyLFUCache[K, V]) hit(ent *entry[K, V]) (V, bool) {
+    c.stats.hit()
+    if loader, ok := c.refresher.due(ent, time.Now().UnixNano()); ok {
+        go c.refresh(ent, loader)
+    }
+    return ent.value, true
+}
+
+// refresh reloads ent, dropping the result if the entry was updated or
+// removed in the meantime.
+func (c *TinyLFUCache[K, V]) refresh(ent *entry[K, V], loader LoaderFunc[K, V]) {
+    value, d, err := loader(context.Background(), ent.key)
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        if cur, ok := seg.peekEntry(ent.key); ok {
+            if cur != ent || !ent.refreshing {
+                return
+            }
+            if err != nil {
+                ent.refreshing = false
+                return
+            }
+            seg.refreshEntry(ent, value, d)
+            c.makeRoom()
+            return
+        }
+    }
+}
+
+// GetOrLoad looks up a key's value from the cache, or loads it with
+// loader and adds it with the duration the loader returns. Concurrent
+// calls for the same key share a single load; callers waiting on it
+// give up when their ctx is done, but the load carries on for the
+// others. A panic in the loader is raised again in each of them. The
+// cache is not locked while the loader runs.
+func (c *TinyLFUCache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V]) (V, error) {
+    return c.loads.load(ctx, c, key, loader)
+}
+
+// Add adds a value to the cache. Returns true if an eviction occurred,
+// which includes refusing an entry leaving the window.
+func (c *TinyLFUCache[K, V]) Add(key K, value V) bool {
+    return c.AddWithExpire(key, value, NoExpiration)
+}
+
+// AddWithExpire adds a value to the cache that expires after d.
+// Returns true if an eviction occurred.
+func (c *TinyLFUCache[K, V]) AddWithExpire(key K, value V, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.window.costOf(key, value), c.window.ttlOf(d))
+}
+
+// AddWithCost adds a value with an explicit cost that expires after d.
+// Returns true if an eviction occurred.
+func (c *TinyLFUCache[K, V]) AddWithCost(key K, value V, cost int64, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, cost, c.window.ttlOf(d))
+}
+
+// AddWithSliding adds a value that expires after d, each successful Get
+// pushing its deadline d further. A positive max caps its lifetime from
+// now regardless of accesses. Returns true if an eviction occurred.
+func (c *TinyLFUCache[K, V]) AddWithSliding(key K, value V, d time.Duration, max time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.window.costOf(key, value), c.window.slidingTTL(d, max))
+}
+
+// AddWithRefresh adds a value that expires after d. Once the soft TTL
+// refresh has passed, Get keeps returning it but also reloads it in the
+// background with the loader registered by SetRefreshLoader.
+// Returns true if an eviction occurred.
+func (c *TinyLFUCache[K, V]) AddWithRefresh(key K, value V, refresh time.Duration, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.window.costOf(key, value), ttl{expiration: c.window.expireAt(d), refresh: refresh})
+}
+
+// AddWithDeadline adds a value that expires at deadline, or never if it
+// is the zero time. Returns true if an eviction occurred.
+func (c *TinyLFUCache[K, V]) AddWithDeadline(key K, value V, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.add(key, value, c.window.costOf(key, value), deadlineTTL(deadline))
+}
+
+// SetRefreshLoader registers the loader used to refresh entries added
+// with AddWithRefresh. The duration it returns restarts the hard TTL.
+func (c *TinyLFUCache[K, V]) SetRefreshLoader(loader LoaderFunc[K, V]) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.refresher.loader = loader
+}
+
+// ContainsOrAdd checks if a key is in the cache without updating the
+// recent-ness or deleting it for being stale, and if not, adds the value.
+// Returns whether found and whether an eviction occurred.
+func (c *TinyLFUCache[K, V]) ContainsOrAdd(key K, value V) (ok, evict bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        if seg.Contains(key) {
+            return true, false
+        }
+    }
+    return false, c.add(key, value, c.window.costOf(key, value), c.window.ttlOf(NoExpiration))
+}
+
+// add does the work of AddWithCost, the caller must hold the lock.
+func (c *TinyLFUCache[K, V]) add(key K, value V, cost int64, t ttl) bool {
+    c.sketch.increment(key)
+
+    // Update the value in whichever segment holds the key
+    for _, seg := range c.segments() {
+        if _, ok := seg.peekEntry(key); ok {
+            seg.addWithCost(key, value, t, cost)
+            return c.makeRoom()
+        }
+    }
+
+    // New entries go through the window
+    c.window.addWithCost(key, value, t, cost)
+    return c.makeRoom()
+}
+
+// makeRoom brings every segment back within its capacity. Returns true
+// if an entry was evicted or refused.
+func (c *TinyLFUCache[K, V]) makeRoom() bool {
+    evicted := c.evict()
+    c.demote()
+    for c.probation.Cost()+c.protected.Cost() > c.mainSize {
+        victims := c.probation
+        if victims.Len() == 0 {
+            victims = c.protected
+        }
+        victims.removeOldestEntry(EvictCapacity)
+        evicted = true
+    }
+    return evicted
+}
+
+// demote moves the oldest protected entries back to probation until
+// protected fits its capacity.
+func (c *TinyLFUCache[K, V]) demote() {
+    for c.protected.Cost() > c.protectedSize {
+        old, _ := c.protected.takeOldest()
+        c.probation.put(old)
+    }
+}
+
+// evict moves the entries overflowing the window to probation, each
+// admitted only if the sketch finds it more popular than every entry it
+// would evict. Returns true if an entry was evicted or refused.
+func (c *TinyLFUCache[K, V]) evict() bool {
+    evicted := false
+    for c.window.Cost() > c.windowSize {
+        candidate, _ := c.window.takeOldest()
+        need := c.probation.Cost() + c.protected.Cost() + candidate.cost - c.mainSize
+        if need <= 0 {
+            c.probation.put(candidate)
+            continue
+        }
+        evicted = true
+
+        // Admission is decided before any victim is evicted, so that a
+        // refused candidate leaves them all in place
+        victims, admit := c.admit(candidate.key, need)
+        if !admit {
+            c.window.notify(candidate, EvictCapacity)
+            continue
+        }
+        for ; victims > 0; victims-- {
+            seg := c.probation
+            if seg.Len() == 0 {
+                seg = c.protected
+            }
+            seg.removeOldestEntry(EvictCapacity)
+        }
+        c.probation.put(candidate)
+    }
+    return evicted
+}
+
+// admit returns how many of the oldest entries of probation, then of
+// protected, must be evicted to free need for the candidate key, and
+// whether the sketch finds it more popular than each of them.
+func (c *TinyLFUCache[K, V]) admit(key K, need int64) (int, bool) {
+    freq := c.sketch.estimate(key)
+    victims := 0
+    for _, seg := range []*BASELRU[K, V]{c.probation, c.protected} {
+        for el := seg.evictList.Back(); el != nil && need > 0; el = el.Prev() {
+            victim := el.Value.(*entry[K, V])
+            if freq <= c.sketch.estimate(victim.key) {
+                return 0, false
+            }
+            need -= victim.cost
+            victims++
+        }
+    }
+    return victims, need <= 0
+}
+
+// RemoveExpired removes every expired entry from the cache, returning
+// how many were removed.
+func (c *TinyLFUCache[K, V]) RemoveExpired() int {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    removed := 0
+    for _, seg := range c.segments() {
+        removed += seg.RemoveExpired()
+    }
+    return removed
+}
+
+// StartJanitor starts a background goroutine that removes expired
+// entries every interval, replacing any janitor started before. Call
+// Close to stop it. A non-positive interval is ignored.
+func (c *TinyLFUCache[K, V]) StartJanitor(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+    j := startJanitor(interval, func() { c.RemoveExpired() })
+    c.lock.Lock()
+    old := c.janitor
+    c.janitor = j
+    c.lock.Unlock()
+    old.Stop()
+}
+
+// Close stops the janitor, if any. The cache remains usable.
+func (c *TinyLFUCache[K, V]) Close() {
+    c.lock.Lock()
+    j := c.janitor
+    c.janitor = nil
+    c.lock.Unlock()
+    j.Stop()
+}
+
+// Len returns the number of cached entries.
+func (c *TinyLFUCache[K, V]) Len() int {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.window.Len() + c.probation.Len() + c.protected.Len()
+}
+
+// Cost returns the total cost of the cached entries.
+func (c *TinyLFUCache[K, V]) Cost() int64 {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return c.window.Cost() + c.probation.Cost() + c.protected.Cost()
+}
+
+// Keys returns all the cached keys, roughly from the first to be evicted
+// to the last: probation, then the window, then protected, each from
+// oldest to newest.
+func (c *TinyLFUCache[K, V]) Keys() []K {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    var keys []K
+    for _, seg := range c.segments() {
+        keys = append(keys, seg.Keys()...)
+    }
+    return keys
+}
+
+// Remove removes the provided key from the cache, returning if the key
+// was contained. The sketch keeps counting its past accesses.
+func (c *TinyLFUCache[K, V]) Remove(key K) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        if seg.Remove(key) {
+            return true
+        }
+    }
+    return false
+}
+
+// Purge is used to clear the cache, forgetting access frequencies too.
+func (c *TinyLFUCache[K, V]) Purge() {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        seg.Purge()
+    }
+    c.sketch.clear()
+}
+
+// Contains is used to check if the cache contains a key without
+// updating recency or frequency.
+func (c *TinyLFUCache[K, V]) Contains(key K) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        if seg.Contains(key) {
+            return true
+        }
+    }
+    return false
+}
+
+// Peek is used to inspect the cache value of a key without updating
+// recency or frequency.
+func (c *TinyLFUCache[K, V]) Peek(key K) (V, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        if val, ok := seg.Peek(key); ok {
+            return val, ok
+        }
+    }
+    var empty V
+    return empty, false
+}
+
+// TTL returns how long the key has left before it expires, NoExpiration
+// if it never does. ok is false if the key is missing or stale.
+func (c *TinyLFUCache[K, V]) TTL(key K) (time.Duration, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        if d, ok := seg.TTL(key); ok {
+            return d, ok
+        }
+    }
+    return 0, false
+}
+
+// Touch makes the key expire d from now, or never if d is NoExpiration,
+// without updating its value, recency or frequency. Returns false if the
+// key is missing or stale.
+func (c *TinyLFUCache[K, V]) Touch(key K, d time.Duration) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        if seg.Touch(key, d) {
+            return true
+        }
+    }
+    return false
+}
+
+// SetExpiration makes the key expire at deadline, or never if it is the
+// zero time, without updating its value, recency or frequency. Returns
+// false if the key is missing or stale.
+func (c *TinyLFUCache[K, V]) SetExpiration(key K, deadline time.Time) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    for _, seg := range c.segments() {
+        if seg.SetExpiration(key, deadline) {
+            return true
+        }
+    }
+    return false
+}
+
+// Stats returns a snapshot of the statistics of the cache.
+func (c *TinyLFUCache[K, V]) Stats() Stats {
+    s := c.stats.snapshot()
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    s.TinyLFU = &TinyLFUStats{
+        Window:    c.window.Len(),
+        Probation: c.probation.Len(),
+        Protected: c.protected.Len(),
+    }
+    s.Len = s.TinyLFU.Window + s.TinyLFU.Probation + s.TinyLFU.Protected
+    s.Cost = c.window.Cost() + c.probation.Cost() + c.protected.Cost()
+    return s
+}
+
+// ResetStats zeroes the counters of the cache, to report statistics per
+// time window.
+func (c *TinyLFUCache[K, V]) ResetStats() {
+    c.stats.reset()
+}
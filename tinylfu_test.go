@@ -0,0 +1,305 @@
+package go_lru
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestTinyLFU(t *testing.T) {
+    l, err := NewTinyLFU(100, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    for i := 0; i < 100; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    if l.Len() != 100 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+    for i := 0; i < 100; i++ {
+        if v, ok := l.Get(fmt.Sprint(i)); !ok || v != i {
+            t.Fatalf("bad: %v, %v", v, ok)
+        }
+    }
+    s := l.Stats().TinyLFU
+    if s.Window != 1 || s.Protected != 79 || s.Probation != 20 {
+        t.Fatalf("bad segments: %+v", s)
+    }
+
+    // The cache never grows past its size
+    for i := 100; i < 300; i++ {
+        l.Add(fmt.Sprint(i), i)
+    }
+    if l.Len() != 100 || len(l.Keys()) != 100 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+
+    l.Add("0", 1000)
+    if v, ok := l.Peek("0"); !ok || v != 1000 {
+        t.Fatalf("update not visible: %v, %v", v, ok)
+    }
+    if !l.Remove("0") || l.Contains("0") {
+        t.Fatalf("should be removed")
+    }
+    l.Purge()
+    if l.Len() != 0 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+
+    if _, err := NewTinyLFU(0, NoExpiration); err == nil {
+        t.Fatalf("should fail with size 0")
+    }
+}
+
+// Test that a scan of one-hit wonders does not push out hot keys, as it
+// does in a plain LRU
+func TestTinyLFU_Admission(t *testing.T) {
+    l, err := NewTinyLFUOf[string, int](100, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    lru, _ := NewOf[string, int](100, NoExpiration)
+
+    for i := 0; i < 1000; i++ {
+        if i%200 == 0 {
+            for j := 0; j < 20; j++ {
+                for k := 0; k < 3; k++ {
+                    l.Get(fmt.Sprintf("hot%d", j))
+                    lru.Get(fmt.Sprintf("hot%d", j))
+                }
+                l.Add(fmt.Sprintf("hot%d", j), j)
+                lru.Add(fmt.Sprintf("hot%d", j), j)
+            }
+        }
+        l.Add(fmt.Sprintf("cold%d", i), i)
+        lru.Add(fmt.Sprintf("cold%d", i), i)
+    }
+
+    for i := 0; i < 20; i++ {
+        if !l.Contains(fmt.Sprintf("hot%d", i)) {
+            t.Fatalf("hot%d should be cached", i)
+        }
+        if lru.Contains(fmt.Sprintf("hot%d", i)) {
+            t.Fatalf("hot%d should have been scanned out of the LRU", i)
+        }
+    }
+    if l.Len() != 100 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+}
+
+func TestTinyLFU_EvictReason(t *testing.T) {
+    reasons := make(map[string]EvictReason)
+    onEvicted := func(k string, v int, reason EvictReason) {
+        reasons[fmt.Sprintf("%s=%d", k, v)] = reason
+    }
+    l, err := NewTinyLFUWithEvictReasonOf[string, int](2, NoExpiration, onEvicted)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // b leaves the window as popular as a, and is refused
+    l.Add("a", 1)
+    l.Add("b", 1)
+    if !l.Add("c", 1) {
+        t.Fatalf("should have an eviction")
+    }
+    // a loses to d, seen more often
+    l.Get("d")
+    l.Get("d")
+    l.AddWithExpire("d", 1, 10*time.Millisecond)
+    l.Add("e", 1)
+    l.Add("e", 2)
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.Get("d"); ok {
+        t.Fatalf("d should have expired")
+    }
+    l.Remove("e")
+    l.Add("f", 1)
+    l.Purge()
+
+    want := map[string]EvictReason{
+        "b=1": EvictCapacity,
+        "a=1": EvictCapacity,
+        "c=1": EvictCapacity,
+        "d=1": EvictExpired,
+        "e=1": EvictReplaced,
+        "e=2": EvictRemoved,
+        "f=1": EvictPurged,
+    }
+    if fmt.Sprint(reasons) != fmt.Sprint(want) {
+        t.Fatalf("bad reasons: %v want %v", reasons, want)
+    }
+}
+
+func TestTinyLFU_Expiration(t *testing.T) {
+    l, err := NewTinyLFUOf[string, int](100, 10*time.Millisecond)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+    for i := 0; i < 50; i++ {
+        l.AddWithExpire(fmt.Sprint(i), i, DefaultExpiration)
+        l.Get(fmt.Sprint(i))
+    }
+    l.Add("forever", 1)
+    time.Sleep(20 * time.Millisecond)
+    if l.Contains("0") {
+        t.Fatalf("should have expired")
+    }
+    if n := l.RemoveExpired(); n != 50 {
+        t.Fatalf("bad removed: %d", n)
+    }
+    if l.Len() != 1 {
+        t.Fatalf("bad len: %v", l.Len())
+    }
+}
+
+func TestTinyLFU_Cost(t *testing.T) {
+    l, err := NewTinyLFUOf[string, int](10, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // The window holds a single unit, the main segments 9
+    l.AddWithCost("big", 0, 5, NoExpiration)
+    for _, k := range []string{"a", "b", "c", "d", "e"} {
+        l.Add(k, 1)
+    }
+    if l.Cost() != 10 || l.Len() != 6 {
+        t.Fatalf("bad cost: %v len: %v", l.Cost(), l.Len())
+    }
+
+    // e is refused, as popular as big
+    if !l.Add("f", 1) || l.Contains("e") {
+        t.Fatalf("e should be refused")
+    }
+
+    // g is popular enough to evict big
+    for i := 0; i < 3; i++ {
+        l.Get("g")
+    }
+    l.Add("g", 1)
+    l.Add("h", 1)
+    if l.Contains("big") || !l.Contains("g") {
+        t.Fatalf("big should make room for g: %v", l.Keys())
+    }
+    if l.Cost() != 6 || l.Stats().Cost != 6 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+
+    // Updates change the cost
+    l.AddWithCost("a", 1, 3, NoExpiration)
+    if l.Cost() != 8 {
+        t.Fatalf("bad cost: %v", l.Cost())
+    }
+}
+
+// Test that a costly candidate is compared with every victim it needs
+// before any is evicted
+func TestTinyLFU_CostAdmission(t *testing.T) {
+    l, err := NewTinyLFUOf[string, int](10, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // c is more popular than x, a less
+    for i := 0; i < 4; i++ {
+        l.Get("c")
+    }
+    l.Get("x")
+    l.Get("x")
+    for _, k := range []string{"a", "c", "d", "e", "f", "g", "h", "i", "j", "k"} {
+        l.Add(k, 1)
+    }
+
+    // x beats a but not c, and neither is evicted
+    if !l.AddWithCost("x", 0, 2, NoExpiration) {
+        t.Fatalf("x should be refused")
+    }
+    if l.Contains("x") || !l.Contains("a") || !l.Contains("c") || l.Cost() != 9 {
+        t.Fatalf("x should leave a and c: %v", l.Keys())
+    }
+
+    // Once more popular than c, x evicts both
+    for i := 0; i < 4; i++ {
+        l.Get("x")
+    }
+    l.AddWithCost("x", 0, 2, NoExpiration)
+    if !l.Contains("x") || l.Contains("a") || l.Contains("c") || l.Cost() != 9 {
+        t.Fatalf("x should evict a and c: %v", l.Keys())
+    }
+}
+
+func TestTinyLFU_TTL(t *testing.T) {
+    l, err := NewTinyLFUOf[string, int](100, NoExpiration)
+    if err != nil {
+        t.Fatalf("err: %v", err)
+    }
+
+    // Entries on probation and in the window alike
+    l.AddWithExpire("a", 1, 10*time.Millisecond)
+    l.Add("b", 2)
+    if s := l.Stats().TinyLFU; s.Window != 1 || s.Probation != 1 {
+        t.Fatalf("bad segments: %+v", s)
+    }
+    if !l.Touch("a", time.Minute) || !l.SetExpiration("b", time.Now().Add(10*time.Millisecond)) {
+        t.Fatalf("should set expiration")
+    }
+    if d, ok := l.TTL("a"); !ok || d <= 59*time.Second {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    l.AddWithDeadline("c", 3, time.Now().Add(time.Hour))
+    time.Sleep(20 * time.Millisecond)
+    if _, ok := l.TTL("b"); ok {
+        t.Fatalf("stale key should have no ttl")
+    }
+    if d, ok := l.TTL("c"); !ok || d <= 59*time.Minute {
+        t.Fatalf("bad ttl: %v, %v", d, ok)
+    }
+    if l.Touch("missing", time.Minute) {
+        t.Fatalf("should not touch a missing key")
+    }
+
+    // Sliding entries are pushed forward by every hit
+    l.AddWithSliding("d", 4, 50*time.Millisecond, NoExpiration)
+    for i := 0; i < 3; i++ {
+        time.Sleep(30 * time.Millisecond)
+        if _, ok := l.Get("d"); !ok {
+            t.Fatalf("sliding entry should still be cached")
+        }
+    }
+    time.Sleep(60 * time.Millisecond)
+    if _, ok := l.Peek("d"); ok {
+        t.Fatalf("sliding entry should have expired")
+    }
+}
+
+func TestCMSketch(t *testing.T) {
+    s := newCMSketch[string](100)
+    for i := 0; i < 5; i++ {
+        s.increment("a")
+    }
+    s.increment("b")
+    if n := s.estimate("a"); n < 5 {
+        t.Fatalf("bad estimate: %d", n)
+    }
+    if s.estimate("a") <= s.estimate("b") {
+        t.Fatalf("a should be more popular than b")
+    }
+
+    // Counters are capped and halved periodically
+    for i := 0; i < 20; i++ {
+        s.increment("a")
+    }
+    if n := s.estimate("a"); n != sketchMaxCount {
+        t.Fatalf("bad estimate: %d", n)
+    }
+    for i := s.adds; i < s.resetAt; i++ {
+        s.increment(fmt.Sprint(i))
+    }
+    if n := s.estimate("a"); n > sketchMaxCount/2+1 {
+        t.Fatalf("should have aged: %d", n)
+    }
+}